@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/logging"
+)
+
+// Conn wraps a net.Conn carrying RTP and RTCP multiplexed on a single port
+// (RFC 5761), e.g. a connected UDP socket or a DTLS connection. Packets
+// written to a Conn are protected with the local keys, packets read from it
+// are authenticated and decrypted with the remote keys.
+// Each Read and Write carries a single RTP or RTCP packet. Received packets
+// which fail to decrypt, e.g. replays, are dropped and logged.
+//
+// ReadFrom and WriteTo, which make Conn a net.PacketConn, require the
+// underlying connection to be a net.PacketConn too, e.g. an unconnected UDP
+// socket of a relay. All peers then share the same local and remote Contexts:
+// they must use the same keys, and distinct SSRCs.
+type Conn struct {
+	localContextMutex, remoteContextMutex sync.Mutex
+
+	nextConn                    net.Conn
+	localContext, remoteContext *Context
+	log                         logging.LeveledLogger
+}
+
+// NewConn creates a Conn using conn as the underlying transport. Only Keys,
+// Profile, LoggerFactory, LocalOptions and RemoteOptions of config are used.
+// Replay protection is enabled on the remote context by default, like for
+// SessionSRTP and SessionSRTCP.
+func NewConn(conn net.Conn, config *Config) (*Conn, error) {
+	if config == nil {
+		return nil, errNoConfig
+	} else if conn == nil {
+		return nil, errNoConn
+	}
+
+	loggerFactory := config.LoggerFactory
+	if loggerFactory == nil {
+		loggerFactory = logging.NewDefaultLoggerFactory()
+	}
+
+	localContext, err := CreateContext(config.Keys.LocalMasterKey, config.Keys.LocalMasterSalt, config.Profile, config.LocalOptions...)
+	if err != nil {
+		return nil, err
+	}
+	remoteContext, err := CreateContext(config.Keys.RemoteMasterKey, config.Keys.RemoteMasterSalt, config.Profile, append(
+		[]ContextOption{
+			// Default options
+			SRTPReplayProtection(defaultSessionSRTPReplayProtectionWindow),
+			SRTCPReplayProtection(defaultSessionSRTCPReplayProtectionWindow),
+		},
+		config.RemoteOptions...,
+	)...)
+	if err != nil {
+		_ = localContext.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		nextConn:      conn,
+		localContext:  localContext,
+		remoteContext: remoteContext,
+		log:           loggerFactory.NewLogger("srtp"),
+	}, nil
+}
+
+// IsRTCP reports whether a packet received on a port shared by RTP and RTCP
+// is RTCP, i.e. its second octet, the RTCP packet type or the RTP marker bit
+// and payload type, is in the range 192-223. This holds for SRTP and SRTCP
+// packets, whose headers are not encrypted.
+//
+// https://tools.ietf.org/html/rfc5761#section-4
+func IsRTCP(buf []byte) bool {
+	return len(buf) >= 2 && buf[1] >= 192 && buf[1] <= 223
+}
+
+// Read reads the next packet which is successfully decrypted into b, and
+// returns the length of the decrypted packet.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadPacket(b)
+	return n, err
+}
+
+// ReadPacket reads the next packet which is successfully decrypted into b like
+// Read, and also reports whether it is an RTCP packet rather than an RTP one.
+func (c *Conn) ReadPacket(b []byte) (n int, rtcp bool, err error) {
+	n, rtcp, _, err = c.readPacket(b, func(b []byte) (int, net.Addr, error) {
+		n, err := c.nextConn.Read(b)
+		return n, nil, err
+	})
+	return n, rtcp, err
+}
+
+// ReadFrom reads the next packet which is successfully decrypted into b like
+// Read, and returns the address it was received from.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	packetConn, ok := c.nextConn.(net.PacketConn)
+	if !ok {
+		return 0, nil, errNotPacketConn
+	}
+	n, _, addr, err := c.readPacket(b, packetConn.ReadFrom)
+	return n, addr, err
+}
+
+func (c *Conn) readPacket(b []byte, read func([]byte) (int, net.Addr, error)) (n int, rtcp bool, addr net.Addr, err error) {
+	for {
+		n, addr, err = read(b)
+		if err != nil {
+			return 0, false, nil, err
+		}
+
+		rtcp = IsRTCP(b[:n])
+		c.remoteContextMutex.Lock()
+		var decrypted []byte
+		if rtcp {
+			decrypted, err = c.remoteContext.DecryptRTCP(b[:n], b[:n], nil)
+		} else {
+			decrypted, err = c.remoteContext.DecryptRTP(b[:n], b[:n], nil)
+		}
+		c.remoteContextMutex.Unlock()
+		if errors.Is(err, errContextClosed) {
+			return 0, false, nil, err
+		} else if err != nil {
+			c.log.Info(err.Error())
+			continue
+		}
+
+		return len(decrypted), rtcp, addr, nil
+	}
+}
+
+// Write encrypts the RTP or RTCP packet in b and writes it to the underlying
+// connection. It returns len(b) on success.
+func (c *Conn) Write(b []byte) (int, error) {
+	return c.write(b, c.nextConn.Write)
+}
+
+// WriteTo encrypts the RTP or RTCP packet in b like Write, and writes it to
+// addr.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	packetConn, ok := c.nextConn.(net.PacketConn)
+	if !ok {
+		return 0, errNotPacketConn
+	}
+	return c.write(b, func(encrypted []byte) (int, error) {
+		return packetConn.WriteTo(encrypted, addr)
+	})
+}
+
+func (c *Conn) write(b []byte, write func([]byte) (int, error)) (int, error) {
+	// The buffer can only be put back into the pool after nextConn.Write has
+	// returned, see SessionSRTP.writeRTP.
+	ibuf := bufferpool.Get()
+	defer bufferpool.Put(ibuf)
+
+	c.localContextMutex.Lock()
+	var encrypted []byte
+	var err error
+	if IsRTCP(b) {
+		encrypted, err = c.localContext.EncryptRTCP(ibuf.([]byte), b, nil)
+	} else {
+		encrypted, err = c.localContext.EncryptRTP(ibuf.([]byte), b, nil)
+	}
+	c.localContextMutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = write(encrypted); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the underlying connection and zeroes the key material of both
+// directions.
+func (c *Conn) Close() error {
+	err := c.nextConn.Close()
+
+	c.remoteContextMutex.Lock()
+	_ = c.remoteContext.Close()
+	c.remoteContextMutex.Unlock()
+	c.localContextMutex.Lock()
+	_ = c.localContext.Close()
+	c.localContextMutex.Unlock()
+	return err
+}
+
+// LocalAddr returns the local address of the underlying connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.nextConn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the underlying connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.nextConn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.nextConn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.nextConn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.nextConn.SetWriteDeadline(t)
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/v3/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ net.Conn       = (*Conn)(nil)
+	_ net.PacketConn = (*Conn)(nil)
+)
+
+func buildConnConfig() *Config {
+	return &Config{
+		Profile: ProtectionProfileAes128CmHmacSha1_80,
+		Keys: SessionKeys{
+			[]byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39},
+			[]byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6},
+			[]byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39},
+			[]byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6},
+		},
+	}
+}
+
+func TestIsRTCP(t *testing.T) {
+	for name, tc := range map[string]struct {
+		buf    []byte
+		isRTCP bool
+	}{
+		"RTP":           {buf: []byte{0x80, 0x60}},
+		"RTPMarker":     {buf: []byte{0x80, 0xe0}},
+		"SenderReport":  {buf: []byte{0x80, 0xc8}, isRTCP: true},
+		"LowestType":    {buf: []byte{0x80, 0xc0}, isRTCP: true},
+		"HighestType":   {buf: []byte{0x80, 0xdf}, isRTCP: true},
+		"RTPMarkerPT63": {buf: []byte{0x80, 0xbf}},
+		"Short":         {buf: []byte{0x80}},
+		"Empty":         {},
+	} {
+		if IsRTCP(tc.buf) != tc.isRTCP {
+			t.Errorf("%s: expected IsRTCP to be %v", name, tc.isRTCP)
+		}
+	}
+}
+
+func TestConnBadInit(t *testing.T) {
+	if _, err := NewConn(nil, nil); err == nil {
+		t.Fatal("NewConn should error if no config was provided")
+	} else if _, err := NewConn(nil, &Config{}); err == nil {
+		t.Fatal("NewConn should error if no conn was provided")
+	}
+
+	aPipe, bPipe := net.Pipe()
+	defer func() {
+		_ = aPipe.Close()
+		_ = bPipe.Close()
+	}()
+	if _, err := NewConn(aPipe, &Config{Profile: ProtectionProfileAes128CmHmacSha1_80}); err == nil {
+		t.Fatal("NewConn should error if no keys were provided")
+	}
+}
+
+func TestConn(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+
+	aPipe, bPipe := net.Pipe()
+	aConn, err := NewConn(aPipe, buildConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bConn, err := NewConn(bPipe, buildConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtpPacket, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 5000},
+		Payload: []byte{0x00, 0x01, 0x03, 0x04},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtcpPacket, err := (&rtcp.SenderReport{SSRC: 5000, NTPTime: 1, RTPTime: 2}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error)
+	go func() {
+		for _, pkt := range [][]byte{rtpPacket, rtcpPacket} {
+			if _, errWrite := aConn.Write(pkt); errWrite != nil {
+				errCh <- errWrite
+				return
+			}
+		}
+		errCh <- nil
+	}()
+
+	readBuffer := make([]byte, 1500)
+	for i, expected := range [][]byte{rtpPacket, rtcpPacket} {
+		n, isRTCP, errRead := bConn.ReadPacket(readBuffer)
+		assert.NoError(errRead)
+		assert.Equal(expected, readBuffer[:n])
+		assert.Equal(i == 1, isRTCP)
+	}
+	assert.NoError(<-errCh)
+
+	assert.NoError(aConn.Close())
+	assert.NoError(bConn.Close())
+	_, err = bConn.Read(readBuffer)
+	assert.Error(err)
+}
+
+func TestConnDropsInvalidPackets(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+
+	config := buildConnConfig()
+	aPipe, bPipe := net.Pipe()
+	bConn, err := NewConn(bPipe, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptContext, err := CreateContext(config.Keys.LocalMasterKey, config.Keys.LocalMasterSalt, config.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptRTP := func(seq uint16) []byte {
+		raw, errMarshal := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: seq, SSRC: 5000},
+			Payload: []byte{0x00, 0x01, 0x03, 0x04},
+		}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+
+	first := encryptRTP(1)
+	tampered := encryptRTP(2)
+	tampered[len(tampered)-1] ^= 0xff
+	third := encryptRTP(3)
+
+	errCh := make(chan error)
+	go func() {
+		// A tampered packet and a replay are dropped.
+		for _, pkt := range [][]byte{first, tampered, first, third} {
+			if _, errWrite := aPipe.Write(pkt); errWrite != nil {
+				errCh <- errWrite
+				return
+			}
+		}
+		errCh <- nil
+	}()
+
+	readBuffer := make([]byte, 1500)
+	header := &rtp.Header{}
+	for _, seq := range []uint16{1, 3} {
+		n, errRead := bConn.Read(readBuffer)
+		assert.NoError(errRead)
+		_, err = header.Unmarshal(readBuffer[:n])
+		assert.NoError(err)
+		assert.Equal(seq, header.SequenceNumber)
+	}
+	assert.NoError(<-errCh)
+
+	assert.NoError(aPipe.Close())
+	assert.NoError(bConn.Close())
+}
+
+func TestConnPacketConn(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+
+	// Unconnected UDP sockets, as used by a relay
+	aUDP, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bUDP, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aConn, err := NewConn(aUDP, buildConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bConn, err := NewConn(bUDP, buildConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtpPacket, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 5000},
+		Payload: []byte{0x00, 0x01, 0x03, 0x04},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtcpPacket, err := (&rtcp.SenderReport{SSRC: 5000, NTPTime: 1, RTPTime: 2}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readBuffer := make([]byte, 1500)
+	for _, expected := range [][]byte{rtpPacket, rtcpPacket} {
+		n, errWrite := aConn.WriteTo(expected, bUDP.LocalAddr())
+		assert.NoError(errWrite)
+		assert.Equal(len(expected), n)
+
+		n, addr, errRead := bConn.ReadFrom(readBuffer)
+		assert.NoError(errRead)
+		assert.Equal(expected, readBuffer[:n])
+		assert.Equal(aUDP.LocalAddr().String(), addr.String())
+	}
+
+	assert.NoError(aConn.Close())
+	assert.NoError(bConn.Close())
+
+	// The underlying connection must be a net.PacketConn.
+	aPipe, bPipe := net.Pipe()
+	pipeConn, err := NewConn(aPipe, buildConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pipeConn.WriteTo(rtpPacket, bUDP.LocalAddr())
+	assert.ErrorIs(err, errNotPacketConn)
+	_, _, err = pipeConn.ReadFrom(readBuffer)
+	assert.ErrorIs(err, errNotPacketConn)
+	assert.NoError(pipeConn.Close())
+	assert.NoError(bPipe.Close())
+}
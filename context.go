@@ -18,6 +18,9 @@ const (
 	labelSRTCPAuthenticationTag = 0x04
 	labelSRTCPSalt              = 0x05
 
+	labelSRTPHeaderEncryption = 0x06
+	labelSRTPHeaderSalt       = 0x07
+
 	maxSequenceNumber = 65535
 	maxROC            = (1 << 32) - 1
 
@@ -25,14 +28,59 @@ const (
 	seqNumMax    = 1 << 16
 
 	srtcpIndexSize = 4
+
+	// A master key must not protect more packets than this.
+	// https://www.rfc-editor.org/rfc/rfc3711#section-9.2
+	maxSRTPKeyLifetime  = 1 << 48
+	maxSRTCPKeyLifetime = 1 << 31
 )
 
+// A master key and the session keys derived from it
+type masterKeys struct {
+	// MKI identifying the master key, nil if MKI is disabled
+	mki []byte
+
+	// Session keys for (index DIV kdr) == 0
+	cipher srtpCipher
+
+	// Retained to derive session keys again when the key derivation rate is
+	// non-zero, and for MarshalBinary
+	masterKey, masterSalt []byte
+
+	// Number of packets protected with the master key, and whether the
+	// expiry callback has been called for it.
+	srtpPackets, srtcpPackets uint64
+	expiring                  bool
+}
+
+// Session keys derived for a single value of (index DIV kdr)
+type sessionKeys struct {
+	master       *masterKeys
+	cipher       srtpCipher
+	indexOverKdr uint64
+}
+
 // Encrypt/Decrypt state for a single SRTP SSRC
 type srtpSSRCState struct {
 	ssrc                 uint32
 	rolloverHasProcessed bool
 	index                uint64
 	replayDetector       replaydetector.ReplayDetector
+	replayWindow         *replayWindow
+	sessionKeys          sessionKeys
+
+	// Master key of the sender learned from EKT, nil to use the master key
+	// of the Context, and the epoch of the last full EKT field accepted by
+	// SPI.
+	ektKeys   *masterKeys
+	ektEpochs map[uint16]uint16
+
+	// Maximum number of sequence numbers a packet may be behind the highest
+	// one before its ROC is guessed to be the next one, 0 for the index
+	// estimation of RFC 3711 Appendix A.
+	maxDisorder uint16
+
+	stats StreamStats
 }
 
 // Encrypt/Decrypt state for a single SRTCP SSRC
@@ -40,6 +88,13 @@ type srtcpSSRCState struct {
 	srtcpIndex     uint32
 	ssrc           uint32
 	replayDetector replaydetector.ReplayDetector
+	replayWindow   *replayWindow
+	sessionKeys    sessionKeys
+
+	// Highest SRTCP index received, 0 if no packet has been decrypted
+	receivedIndex uint32
+
+	stats StreamStats
 }
 
 // Context represents a SRTP cryptographic context.
@@ -47,15 +102,74 @@ type srtcpSSRCState struct {
 // it must either used ONLY for encryption or ONLY for decryption.
 // Note that Context does not provide any concurrency protection:
 // access to a Context from multiple goroutines requires external
-// synchronization.
+// synchronization. SessionSRTP and SessionSRTCP use separate Contexts
+// for each direction and serialize access to them, so they are safe to use
+// from multiple goroutines.
 type Context struct {
-	cipher srtpCipher
+	// Master key used for encryption, and for decryption when MKI is disabled
+	keys   *masterKeys
+	closed bool
+
+	// Key derivation rate, 0 if the session keys are never re-derived.
+	kdr     uint64
+	profile ProtectionProfile
+
+	// Master keys by MKI, all MKIs have the same length.
+	// sendMKI is the MKI of keys, nil if MKI is disabled.
+	sendMKI []byte
+	mkis    map[string]*masterKeys
+
+	// IDs of the RTP header extensions encrypted as specified in RFC 6904.
+	encryptedHeaderExtensionIDs []uint8
+
+	// Set if the CSRCs and header extensions are encrypted as specified in
+	// RFC 9335 (cryptex).
+	cryptex bool
+
+	// Number of packets per SSRC, and their maximum payload size, for which
+	// AES-CM keystreams are precomputed. 0 to generate them on demand.
+	keystreamPackets, keystreamSize int
+
+	// Minimum size of the AES-CM payloads encrypted with cipher.NewCTR, 0 to
+	// always use ctrBuffers.
+	pipelinedCTRSize int
+
+	// Encrypted RTP payloads are padded to a multiple of rtpPaddingBlockSize
+	// if it is greater than 1.
+	rtpPaddingBlockSize uint8
+
+	// Copied to the maxDisorder of the SRTP SSRC states.
+	srtpMaxROCDisorder uint16
+
+	// Provides the SRTP indexes instead of estimating them, nil if not set.
+	sequenceOracle SequenceOracle
+
+	// Set if received packets are validated with validateRTP and
+	// validateRTCP before being processed.
+	strictValidation bool
+
+	// Maximum number of SRTP and SRTCP packets protected with a master key,
+	// 0 for the RFC 3711 maximum. onKeyExpiring is called once per master key
+	// when keyExpiryThreshold packets of its lifetime are left.
+	srtpKeyLifetime, srtcpKeyLifetime uint64
+	keyExpiryThreshold                uint64
+	onKeyExpiring                     func(mki []byte)
+
+	// EKT keys by SPI, nil if EKT (RFC 8870) is not used.
+	ektKeys map[uint16]*ektKey
+
+	// Notified of anomalies, nil if not set.
+	events EventHandler
 
 	srtpSSRCStates  map[uint32]*srtpSSRCState
 	srtcpSSRCStates map[uint32]*srtcpSSRCState
 
 	newSRTCPReplayDetector func() replaydetector.ReplayDetector
 	newSRTPReplayDetector  func() replaydetector.ReplayDetector
+
+	// Window sizes set by SRTPReplayProtection and SRTCPReplayProtection, 0
+	// without replay protection or with a custom replay detector
+	srtpReplayWindow, srtcpReplayWindow uint
 }
 
 // CreateContext creates a new SRTP Context.
@@ -66,52 +180,180 @@ type Context struct {
 //
 //	decCtx, err := srtp.CreateContext(key, salt, profile, srtp.SRTPReplayProtection(256))
 func CreateContext(masterKey, masterSalt []byte, profile ProtectionProfile, opts ...ContextOption) (c *Context, err error) {
-	keyLen, err := profile.keyLen()
+	c = &Context{
+		profile:         profile,
+		mkis:            map[string]*masterKeys{},
+		srtpSSRCStates:  map[uint32]*srtpSSRCState{},
+		srtcpSSRCStates: map[uint32]*srtcpSSRCState{},
+	}
+
+	for _, o := range append(
+		[]ContextOption{ // Default options
+			SRTPNoReplayProtection(),
+			SRTCPNoReplayProtection(),
+		},
+		opts..., // User specified options
+	) {
+		if errOpt := o(c); errOpt != nil {
+			return nil, errOpt
+		}
+	}
+
+	c.keys, err = c.newMasterKeys(masterKey, masterSalt, c.sendMKI)
+	if err != nil {
+		return nil, err
+	}
+	if c.sendMKI != nil {
+		c.mkis[string(c.sendMKI)] = c.keys
+	}
+
+	return c, nil
+}
+
+func (c *Context) newMasterKeys(masterKey, masterSalt, mki []byte) (*masterKeys, error) {
+	keyLen, err := c.profile.keyLen()
 	if err != nil {
 		return nil, err
 	}
 
-	saltLen, err := profile.saltLen()
+	saltLen, err := c.profile.saltLen()
 	if err != nil {
 		return nil, err
 	}
 
 	if masterKeyLen := len(masterKey); masterKeyLen != keyLen {
-		return c, fmt.Errorf("%w expected(%d) actual(%d)", errShortSrtpMasterKey, masterKey, keyLen)
+		return nil, fmt.Errorf("%w expected(%d) actual(%d)", errShortSrtpMasterKey, keyLen, masterKeyLen)
 	} else if masterSaltLen := len(masterSalt); masterSaltLen != saltLen {
-		return c, fmt.Errorf("%w expected(%d) actual(%d)", errShortSrtpMasterSalt, saltLen, masterSaltLen)
+		return nil, fmt.Errorf("%w expected(%d) actual(%d)", errShortSrtpMasterSalt, saltLen, masterSaltLen)
 	}
 
-	c = &Context{
-		srtpSSRCStates:  map[uint32]*srtpSSRCState{},
-		srtcpSSRCStates: map[uint32]*srtcpSSRCState{},
+	m := &masterKeys{mki: mki}
+	if m.cipher, err = c.newSrtpCipher(masterKey, masterSalt, mki, 0); err != nil {
+		return nil, err
 	}
 
-	switch profile {
+	m.masterKey = append([]byte{}, masterKey...)
+	m.masterSalt = append([]byte{}, masterSalt...)
+	return m, nil
+}
+
+func (m *masterKeys) zero() {
+	m.cipher.zero()
+	m.cipher = nil
+	zeroBytes(m.masterKey)
+	zeroBytes(m.masterSalt)
+	m.masterKey, m.masterSalt = nil, nil
+}
+
+// clone returns a copy of m without its packet counts.
+func (m *masterKeys) clone() *masterKeys {
+	return &masterKeys{
+		mki:        m.mki,
+		cipher:     m.cipher.clone(),
+		masterKey:  append([]byte{}, m.masterKey...),
+		masterSalt: append([]byte{}, m.masterSalt...),
+	}
+}
+
+func (c *Context) newSrtpCipher(masterKey, masterSalt, mki []byte, indexOverKdr uint64) (srtpCipher, error) {
+	if c.cryptex && len(c.encryptedHeaderExtensionIDs) != 0 {
+		return nil, errCryptexWithEncryptedHeaderExtensions
+	}
+
+	switch c.profile {
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
-		c.cipher, err = newSrtpCipherAeadAesGcm(profile, masterKey, masterSalt)
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80:
-		c.cipher, err = newSrtpCipherAesCmHmacSha1(profile, masterKey, masterSalt)
+		if len(c.encryptedHeaderExtensionIDs) != 0 {
+			return nil, fmt.Errorf("%w: %#v", errHeaderExtensionEncryptionNotSupported, c.profile)
+		}
+		s, err := newSrtpCipherAeadAesGcm(c.profile, masterKey, masterSalt, mki, indexOverKdr)
+		if err != nil {
+			return nil, err
+		}
+		s.cryptex = c.cryptex
+		return s, nil
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
+		s, err := newSrtpCipherAesCmHmacSha1(c.profile, masterKey, masterSalt, mki, indexOverKdr)
+		if err != nil {
+			return nil, err
+		}
+		s.cryptex = c.cryptex
+		s.ctr.pipelinedSize = c.pipelinedCTRSize
+		if len(c.encryptedHeaderExtensionIDs) != 0 {
+			s.headerExtensions, err = newHeaderExtensionCipher(c.encryptedHeaderExtensionIDs, masterKey, masterSalt, indexOverKdr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if c.keystreamPackets > 0 && s.encrypted {
+			s.keystreams = newKeystreamPrecomputer(s.srtpBlock, s.srtpSessionSalt, c.keystreamPackets, c.keystreamSize)
+		}
+		return s, nil
 	default:
-		return nil, fmt.Errorf("%w: %#v", errNoSuchSRTPProfile, profile)
+		return nil, fmt.Errorf("%w: %#v", errNoSuchSRTPProfile, c.profile)
 	}
+}
+
+// sessionCipher returns the cipher holding the session keys derived from m for
+// the given SRTP or SRTCP packet index. When a key derivation rate is set, the
+// session keys are derived again each time (index DIV kdr) changes.
+//
+// https://tools.ietf.org/html/rfc3711#section-4.3.1
+func (c *Context) sessionCipher(k *sessionKeys, m *masterKeys, index uint64) (srtpCipher, error) {
+	cipher, derived, err := c.deriveSessionCipher(k, m, index)
 	if err != nil {
 		return nil, err
 	}
+	if derived {
+		c.setSessionCipher(k, m, index, cipher)
+	}
+	return cipher, nil
+}
 
-	for _, o := range append(
-		[]ContextOption{ // Default options
-			SRTPNoReplayProtection(),
-			SRTCPNoReplayProtection(),
-		},
-		opts..., // User specified options
-	) {
-		if errOpt := o(c); errOpt != nil {
-			return nil, errOpt
-		}
+// deriveSessionCipher is sessionCipher without replacing the cached session
+// keys, so that a received packet must be authenticated before its index
+// replaces them, see setSessionCipher. derived is true if the returned cipher
+// is not cached.
+func (c *Context) deriveSessionCipher(k *sessionKeys, m *masterKeys, index uint64) (cipher srtpCipher, derived bool, err error) {
+	if c.kdr == 0 {
+		return m.cipher, false, nil
 	}
 
-	return c, nil
+	indexOverKdr := index / c.kdr
+	if k.cipher != nil && k.master == m && k.indexOverKdr == indexOverKdr {
+		return k.cipher, false, nil
+	}
+
+	if cipher, err = c.newSrtpCipher(m.masterKey, m.masterSalt, m.mki, indexOverKdr); err != nil {
+		return nil, false, err
+	}
+	return cipher, true, nil
+}
+
+// setSessionCipher caches a cipher returned by deriveSessionCipher.
+func (c *Context) setSessionCipher(k *sessionKeys, m *masterKeys, index uint64, cipher srtpCipher) {
+	if k.cipher != nil {
+		k.cipher.zero()
+	}
+	k.master = m
+	k.cipher = cipher
+	k.indexOverKdr = index / c.kdr
+}
+
+// decryptionKeys returns the master key used to protect a packet. When MKI is
+// enabled, the MKI is located right before the authentication tag.
+func (c *Context) decryptionKeys(packet []byte, authTagLen int) (*masterKeys, error) {
+	if c.sendMKI == nil {
+		return c.keys, nil
+	}
+
+	end := len(packet) - authTagLen
+	m, ok := c.mkis[string(packet[end-len(c.sendMKI):end])]
+	if !ok {
+		return nil, ErrMKINotFound
+	}
+	return m, nil
 }
 
 // https://tools.ietf.org/html/rfc3550#appendix-A.1
@@ -123,6 +365,10 @@ func (s *srtpSSRCState) nextRolloverCount(sequenceNumber uint16) (roc uint32, di
 	guessRoc := localRoc
 	var difference int32
 
+	if s.rolloverHasProcessed && s.maxDisorder != 0 {
+		return s.nextRolloverCountWithDisorder(sequenceNumber)
+	}
+
 	if s.rolloverHasProcessed {
 		// When localROC is equal to 0, and entering seq-localSeq > seqNumMedian
 		// judgment, it will cause guessRoc calculation error
@@ -153,15 +399,71 @@ func (s *srtpSSRCState) nextRolloverCount(sequenceNumber uint16) (roc uint32, di
 	return guessRoc, difference, (guessRoc == 0 && localRoc == maxROC)
 }
 
-func (s *srtpSSRCState) updateRolloverCount(sequenceNumber uint16, difference int32) {
+// nextRolloverCountWithDisorder guesses the ROC of a packet as being behind
+// the highest index if it is at most maxDisorder sequence numbers behind it,
+// and ahead of it otherwise.
+func (s *srtpSSRCState) nextRolloverCountWithDisorder(sequenceNumber uint16) (roc uint32, diff int32, overflow bool) {
+	localRoc := uint32(s.index >> 16)
+	localSeq := uint16(s.index)
+
+	if behind := localSeq - sequenceNumber; behind != 0 && behind <= s.maxDisorder && uint64(behind) <= s.index {
+		if sequenceNumber > localSeq {
+			return localRoc - 1, -int32(behind), false
+		}
+		return localRoc, -int32(behind), false
+	}
+
+	ahead := int32(sequenceNumber - localSeq)
+	if sequenceNumber < localSeq {
+		return localRoc + 1, ahead, localRoc == maxROC
+	}
+	return localRoc, ahead, false
+}
+
+// updateRolloverCount updates the highest index of the SSRC, and reports
+// whether the ROC has been incremented.
+func (s *srtpSSRCState) updateRolloverCount(sequenceNumber uint16, difference int32) bool {
 	if !s.rolloverHasProcessed {
 		s.index |= uint64(sequenceNumber)
 		s.rolloverHasProcessed = true
-		return
+		return false
 	}
 	if difference > 0 {
+		roc := s.index >> 16
 		s.index += uint64(difference)
+		if s.index>>16 != roc {
+			s.stats.ROCIncrements++
+			return true
+		}
 	}
+	return false
+}
+
+// countProtectedPacket counts an SRTP or SRTCP packet against the lifetime
+// of the master key used for encryption.
+func (c *Context) countProtectedPacket(isRTCP bool) error {
+	m := c.keys
+	count, lifetime := &m.srtpPackets, c.srtpKeyLifetime
+	if lifetime == 0 {
+		lifetime = maxSRTPKeyLifetime
+	}
+	if isRTCP {
+		count, lifetime = &m.srtcpPackets, c.srtcpKeyLifetime
+		if lifetime == 0 {
+			lifetime = maxSRTCPKeyLifetime
+		}
+	}
+
+	if *count >= lifetime {
+		return ErrKeyLifetimeExceeded
+	}
+	*count++
+
+	if c.onKeyExpiring != nil && !m.expiring && lifetime-*count <= c.keyExpiryThreshold {
+		m.expiring = true
+		c.onKeyExpiring(m.mki)
+	}
+	return nil
 }
 
 func (c *Context) getSRTPSSRCState(ssrc uint32) *srtpSSRCState {
@@ -173,6 +475,10 @@ func (c *Context) getSRTPSSRCState(ssrc uint32) *srtpSSRCState {
 	s = &srtpSSRCState{
 		ssrc:           ssrc,
 		replayDetector: c.newSRTPReplayDetector(),
+		maxDisorder:    c.srtpMaxROCDisorder,
+	}
+	if c.srtpReplayWindow != 0 {
+		s.replayWindow = newReplayWindow(c.srtpReplayWindow)
 	}
 	c.srtpSSRCStates[ssrc] = s
 	return s
@@ -188,10 +494,202 @@ func (c *Context) getSRTCPSSRCState(ssrc uint32) *srtcpSSRCState {
 		ssrc:           ssrc,
 		replayDetector: c.newSRTCPReplayDetector(),
 	}
+	if c.srtcpReplayWindow != 0 {
+		s.replayWindow = newReplayWindow(c.srtcpReplayWindow)
+	}
 	c.srtcpSSRCStates[ssrc] = s
 	return s
 }
 
+// Close overwrites the key material held by the Context.
+// The Context is unusable afterwards, encrypting or decrypting a packet with
+// a closed Context returns an error. The master key and salt passed to
+// CreateContext are not retained, so callers can zero them independently.
+func (c *Context) Close() error {
+	if c.closed {
+		return errContextClosed
+	}
+	c.closed = true
+
+	c.keys.zero()
+	for _, m := range c.mkis {
+		if m.cipher != nil {
+			m.zero()
+		}
+	}
+	c.zeroSSRCStates()
+	for _, k := range c.ektKeys {
+		zeroBytes(k.masterSalt)
+	}
+	c.ektKeys = nil
+	return nil
+}
+
+// zeroSSRCStates overwrites the session keys derived for each SSRC and the
+// master keys learned from EKT.
+func (c *Context) zeroSSRCStates() {
+	for _, s := range c.srtpSSRCStates {
+		if s.sessionKeys.cipher != nil {
+			s.sessionKeys.cipher.zero()
+			s.sessionKeys.cipher = nil
+		}
+		if s.ektKeys != nil {
+			s.ektKeys.zero()
+			s.ektKeys = nil
+		}
+	}
+	for _, s := range c.srtcpSSRCStates {
+		if s.sessionKeys.cipher != nil {
+			s.sessionKeys.cipher.zero()
+			s.sessionKeys.cipher = nil
+		}
+	}
+}
+
+// Clone returns a new Context with the options and master keys of c, but none
+// of its SSRC state, e.g. to protect other SSRCs or the other direction with
+// the same keys. The session keys are not derived again: the AES key
+// schedules are shared, and the session salts and authentication keys are
+// copied. Both Contexts can then be used and closed independently, from
+// different goroutines.
+// The numbers of packets protected with each master key, which are checked
+// against its lifetime, are counted per Context.
+func (c *Context) Clone() (*Context, error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+
+	clone := *c
+	clone.mkis = make(map[string]*masterKeys, len(c.mkis))
+	for mki, m := range c.mkis {
+		clone.mkis[mki] = m.clone()
+	}
+	if c.sendMKI != nil {
+		clone.keys = clone.mkis[string(c.sendMKI)]
+	} else {
+		clone.keys = c.keys.clone()
+	}
+	if c.ektKeys != nil {
+		clone.ektKeys = make(map[uint16]*ektKey, len(c.ektKeys))
+		for spi, k := range c.ektKeys {
+			clone.ektKeys[spi] = &ektKey{block: k.block, masterSalt: append([]byte{}, k.masterSalt...), epoch: k.epoch, epochKeys: k.epochKeys}
+			if k.epochKeys == c.keys {
+				clone.ektKeys[spi].epochKeys = clone.keys
+			}
+		}
+	}
+	clone.srtpSSRCStates = map[uint32]*srtpSSRCState{}
+	clone.srtcpSSRCStates = map[uint32]*srtcpSSRCState{}
+	return &clone, nil
+}
+
+// Reset drops the state of all SSRCs, i.e. their ROCs and SRTCP indexes,
+// replay windows, statistics and the master keys learned from EKT, so that the
+// Context can be reused for unrelated streams, e.g. from a pool. The master
+// keys and their packet counts are kept.
+func (c *Context) Reset() error {
+	if c.closed {
+		return errContextClosed
+	}
+
+	c.zeroSSRCStates()
+	c.srtpSSRCStates = map[uint32]*srtpSSRCState{}
+	c.srtcpSSRCStates = map[uint32]*srtcpSSRCState{}
+	return nil
+}
+
+// AddCipherForMKI adds a master key identified by mki, which can then be used
+// for encryption with SetSendMKI. When decrypting, the master key is selected
+// by the MKI carried by each packet.
+// The Context must have been created with the MasterKeyIndicator option, and
+// mki must have the same length as the MKI given to it.
+func (c *Context) AddCipherForMKI(mki, masterKey, masterSalt []byte) error {
+	if c.closed {
+		return errContextClosed
+	} else if c.sendMKI == nil {
+		return errMKIIsNotEnabled
+	} else if len(mki) != len(c.sendMKI) {
+		return fmt.Errorf("%w expected(%d) actual(%d)", errInvalidMKILength, len(c.sendMKI), len(mki))
+	} else if _, ok := c.mkis[string(mki)]; ok {
+		return errMKIAlreadyInUse
+	}
+
+	m, err := c.newMasterKeys(masterKey, masterSalt, append([]byte{}, mki...))
+	if err != nil {
+		return err
+	}
+	c.mkis[string(mki)] = m
+	return nil
+}
+
+// SetSendMKI switches the master key used for encryption to the one
+// identified by mki.
+func (c *Context) SetSendMKI(mki []byte) error {
+	if c.closed {
+		return errContextClosed
+	}
+
+	m, ok := c.mkis[string(mki)]
+	if !ok {
+		return ErrMKINotFound
+	}
+	c.keys = m
+	c.sendMKI = m.mki
+	return nil
+}
+
+// RemoveMKI removes the master key identified by mki. The master key
+// currently used for encryption cannot be removed.
+func (c *Context) RemoveMKI(mki []byte) error {
+	if c.closed {
+		return errContextClosed
+	}
+
+	m, ok := c.mkis[string(mki)]
+	if !ok {
+		return ErrMKINotFound
+	} else if m == c.keys {
+		return errMKIAlreadyInUse
+	}
+
+	delete(c.mkis, string(mki))
+	m.zero()
+	return nil
+}
+
+// UpdateMasterKey replaces the master key used for encryption, and for
+// decryption when MKI is disabled, e.g. after a DTLS renegotiation or an SDES
+// re-INVITE. Session keys are derived again from the new master key, while
+// rollover counters, SRTCP indexes and replay windows are kept.
+// When MKI is enabled the master key of the current send MKI is replaced, use
+// AddCipherForMKI and SetSendMKI instead to keep both keys during a
+// transition.
+func (c *Context) UpdateMasterKey(masterKey, masterSalt []byte) error {
+	if c.closed {
+		return errContextClosed
+	}
+
+	m, err := c.newMasterKeys(masterKey, masterSalt, c.sendMKI)
+	if err != nil {
+		return err
+	}
+	c.setMasterKeys(m)
+	return nil
+}
+
+// setMasterKeys replaces the current master key with m, which must have been
+// created by newMasterKeys with the current send MKI.
+func (c *Context) setMasterKeys(m *masterKeys) {
+	old := c.keys
+	c.keys = m
+	if c.sendMKI != nil {
+		c.mkis[string(c.sendMKI)] = m
+	}
+	// Cached session keys still refer to old, and are derived again from m
+	// on their next use.
+	old.zero()
+}
+
 // ROC returns SRTP rollover counter value of specified SSRC.
 func (c *Context) ROC(ssrc uint32) (uint32, bool) {
 	s, ok := c.srtpSSRCStates[ssrc]
@@ -201,7 +699,25 @@ func (c *Context) ROC(ssrc uint32) (uint32, bool) {
 	return uint32(s.index >> 16), true
 }
 
+// EstimateIndex returns the SRTP packet index with which a packet of ssrc with
+// the given sequence number would be decrypted, without updating the state of
+// the SSRC. Before the first packet of the SSRC, the index is based on the ROC
+// set with SetROC, or 0.
+func (c *Context) EstimateIndex(ssrc uint32, sequenceNumber uint16) (uint64, error) {
+	s, ok := c.srtpSSRCStates[ssrc]
+	if !ok {
+		s = &srtpSSRCState{ssrc: ssrc, maxDisorder: c.srtpMaxROCDisorder}
+	}
+	roc, _, _, err := c.rolloverCount(s, sequenceNumber)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(roc)<<16 | uint64(sequenceNumber), nil
+}
+
 // SetROC sets SRTP rollover counter value of specified SSRC.
+// The next packet of the SSRC is processed with the given ROC, which allows a
+// receiver joining mid-stream to synchronize with a ROC learned out of band.
 func (c *Context) SetROC(ssrc uint32, roc uint32) {
 	s := c.getSRTPSSRCState(ssrc)
 	s.index = uint64(roc) << 16
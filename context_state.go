@@ -0,0 +1,377 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/pion/transport/v3/replaydetector"
+)
+
+const (
+	contextStateVersion = 1
+
+	contextStateFlagCryptex = 0x01
+)
+
+// MarshalBinary returns the state of the Context, so that it can be restored
+// with RestoreContext or UnmarshalBinary, e.g. to resume a session after a
+// restart. The state holds the master keys and the number of packets they
+// protected, the rollover counters, SRTCP indexes and replay windows of every
+// SSRC, and the key derivation rate, MKI, encrypted header extension, cryptex
+// and replay protection settings.
+//
+// The returned data contains the master keys in the clear, and must be
+// protected accordingly.
+func (c *Context) MarshalBinary() ([]byte, error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+
+	b := []byte{contextStateVersion}
+	b = binary.BigEndian.AppendUint16(b, uint16(c.profile))
+	b = binary.BigEndian.AppendUint64(b, c.kdr)
+
+	b = append(b, byte(len(c.encryptedHeaderExtensionIDs)))
+	b = append(b, c.encryptedHeaderExtensionIDs...)
+	if c.cryptex {
+		b = append(b, contextStateFlagCryptex)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(c.srtpReplayWindow))
+	b = binary.BigEndian.AppendUint32(b, uint32(c.srtcpReplayWindow))
+
+	// The first master key is the one used for encryption.
+	keys := []*masterKeys{c.keys}
+	mkis := make([]string, 0, len(c.mkis))
+	for mki := range c.mkis {
+		if mki != string(c.sendMKI) {
+			mkis = append(mkis, mki)
+		}
+	}
+	sort.Strings(mkis)
+	for _, mki := range mkis {
+		keys = append(keys, c.mkis[mki])
+	}
+
+	b = append(b, byte(len(c.sendMKI)))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(keys)))
+	for _, m := range keys {
+		b = append(b, m.mki...)
+		b = append(b, m.masterKey...)
+		b = append(b, m.masterSalt...)
+		b = binary.BigEndian.AppendUint64(b, m.srtpPackets)
+		b = binary.BigEndian.AppendUint64(b, m.srtcpPackets)
+	}
+
+	srtpSSRCs := make([]uint32, 0, len(c.srtpSSRCStates))
+	for ssrc := range c.srtpSSRCStates {
+		srtpSSRCs = append(srtpSSRCs, ssrc)
+	}
+	sort.Slice(srtpSSRCs, func(i, j int) bool { return srtpSSRCs[i] < srtpSSRCs[j] })
+	b = binary.BigEndian.AppendUint32(b, uint32(len(srtpSSRCs)))
+	for _, ssrc := range srtpSSRCs {
+		s := c.srtpSSRCStates[ssrc]
+		b = binary.BigEndian.AppendUint32(b, ssrc)
+		b = binary.BigEndian.AppendUint64(b, s.index)
+		if s.rolloverHasProcessed {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		b = s.replayWindow.appendTo(b)
+	}
+
+	srtcpSSRCs := make([]uint32, 0, len(c.srtcpSSRCStates))
+	for ssrc := range c.srtcpSSRCStates {
+		srtcpSSRCs = append(srtcpSSRCs, ssrc)
+	}
+	sort.Slice(srtcpSSRCs, func(i, j int) bool { return srtcpSSRCs[i] < srtcpSSRCs[j] })
+	b = binary.BigEndian.AppendUint32(b, uint32(len(srtcpSSRCs)))
+	for _, ssrc := range srtcpSSRCs {
+		s := c.srtcpSSRCStates[ssrc]
+		b = binary.BigEndian.AppendUint32(b, ssrc)
+		b = binary.BigEndian.AppendUint32(b, s.srtcpIndex)
+		b = binary.BigEndian.AppendUint32(b, s.receivedIndex)
+		b = s.replayWindow.appendTo(b)
+	}
+
+	return b, nil
+}
+
+// RestoreContext creates a Context from the state returned by MarshalBinary.
+// Options that are not part of the state, such as a SequenceOracle, must be
+// passed again. Replay protection is restored with the window sizes of the
+// state unless replay options are passed, the indexes received in the saved
+// windows are rejected as duplicates in either case.
+func RestoreContext(data []byte, opts ...ContextOption) (*Context, error) {
+	c := &Context{}
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UnmarshalBinary restores the state returned by MarshalBinary into c, which
+// must not have been used yet. Replay protection is set up like in
+// RestoreContext.
+func (c *Context) UnmarshalBinary(data []byte) error {
+	if c.keys != nil || c.closed {
+		return errContextAlreadyInUse
+	}
+	c.mkis = map[string]*masterKeys{}
+	c.srtpSSRCStates = map[uint32]*srtpSSRCState{}
+	c.srtcpSSRCStates = map[uint32]*srtcpSSRCState{}
+
+	r := &contextStateReader{data: data}
+	if version := r.byte(); version != contextStateVersion {
+		return fmt.Errorf("%w: version %d", errInvalidContextState, version)
+	}
+	c.profile = ProtectionProfile(r.uint16())
+	c.kdr = r.uint64()
+	if c.kdr != 0 && (c.kdr&(c.kdr-1) != 0 || c.kdr > 1<<24) {
+		return fmt.Errorf("%w: %d", errInvalidKDR, c.kdr)
+	}
+	if ids := r.bytes(int(r.byte())); len(ids) != 0 {
+		c.encryptedHeaderExtensionIDs = append([]uint8{}, ids...)
+	}
+	c.cryptex = r.byte()&contextStateFlagCryptex != 0
+	srtpReplayWindow, srtcpReplayWindow := uint(r.uint32()), uint(r.uint32())
+	if r.err != nil {
+		return r.err
+	}
+	if c.newSRTPReplayDetector == nil {
+		if srtpReplayWindow != 0 {
+			_ = SRTPReplayProtection(srtpReplayWindow)(c)
+		} else {
+			_ = SRTPNoReplayProtection()(c)
+		}
+	}
+	if c.newSRTCPReplayDetector == nil {
+		if srtcpReplayWindow != 0 {
+			_ = SRTCPReplayProtection(srtcpReplayWindow)(c)
+		} else {
+			_ = SRTCPNoReplayProtection()(c)
+		}
+	}
+
+	keyLen, err := c.profile.keyLen()
+	if err != nil {
+		return err
+	}
+	saltLen, err := c.profile.saltLen()
+	if err != nil {
+		return err
+	}
+	mkiLen := int(r.byte())
+	keyCount := int(r.uint16())
+	if keyCount == 0 || (mkiLen == 0 && keyCount != 1) {
+		return fmt.Errorf("%w: %d master keys", errInvalidContextState, keyCount)
+	}
+	for i := 0; i < keyCount && r.err == nil; i++ {
+		var mki []byte
+		if mkiLen != 0 {
+			mki = append([]byte{}, r.bytes(mkiLen)...)
+		}
+		masterKey, masterSalt := r.bytes(keyLen), r.bytes(saltLen)
+		srtpPackets, srtcpPackets := r.uint64(), r.uint64()
+		if r.err != nil {
+			break
+		}
+
+		m, err := c.newMasterKeys(masterKey, masterSalt, mki)
+		if err != nil {
+			c.zeroKeys()
+			return err
+		}
+		m.srtpPackets, m.srtcpPackets = srtpPackets, srtcpPackets
+		if i == 0 {
+			c.keys = m
+			c.sendMKI = mki
+		}
+		if mki != nil {
+			c.mkis[string(mki)] = m
+		}
+	}
+
+	for i := r.uint32(); i > 0 && r.err == nil; i-- {
+		ssrc, index, rolloverHasProcessed := r.uint32(), r.uint64(), r.byte() != 0
+		s := c.getSRTPSSRCState(ssrc)
+		s.index = index
+		s.rolloverHasProcessed = rolloverHasProcessed
+		r.replayWindow(s.replayDetector, s.replayWindow)
+	}
+
+	for i := r.uint32(); i > 0 && r.err == nil; i-- {
+		ssrc, srtcpIndex, receivedIndex := r.uint32(), r.uint32(), r.uint32()
+		s := c.getSRTCPSSRCState(ssrc)
+		s.srtcpIndex = srtcpIndex
+		s.receivedIndex = receivedIndex
+		r.replayWindow(s.replayDetector, s.replayWindow)
+	}
+
+	if r.err == nil && len(r.data) != 0 {
+		r.err = fmt.Errorf("%w: %d trailing bytes", errInvalidContextState, len(r.data))
+	}
+	if r.err != nil {
+		c.zeroKeys()
+		return r.err
+	}
+	return nil
+}
+
+// zeroKeys zeroes and drops the master keys of a partially restored Context.
+func (c *Context) zeroKeys() {
+	for _, m := range c.mkis {
+		m.zero()
+	}
+	if c.keys != nil && c.sendMKI == nil {
+		c.keys.zero()
+	}
+	c.keys, c.sendMKI = nil, nil
+	c.mkis = map[string]*masterKeys{}
+}
+
+// replayWindow records the indexes accepted by the replay detector of an
+// SSRC, which can not be read back from it, so that MarshalBinary can save
+// them. Bit i of mask is set if latest-i has been accepted.
+type replayWindow struct {
+	latest uint64
+	mask   []uint64
+	size   uint
+}
+
+func newReplayWindow(size uint) *replayWindow {
+	return &replayWindow{mask: make([]uint64, (size+63)/64), size: size}
+}
+
+func (w *replayWindow) accept(index uint64) {
+	if index > w.latest {
+		w.shift(index - w.latest)
+		w.latest = index
+	}
+	if d := w.latest - index; d < uint64(w.size) {
+		w.mask[d/64] |= 1 << (d % 64)
+	}
+}
+
+// shift moves the window n indexes ahead.
+func (w *replayWindow) shift(n uint64) {
+	if n >= uint64(w.size) {
+		for i := range w.mask {
+			w.mask[i] = 0
+		}
+		return
+	}
+	words, bits := int(n/64), n%64
+	for i := len(w.mask) - 1; i >= 0; i-- {
+		var v uint64
+		if j := i - words; j >= 0 {
+			v = w.mask[j] << bits
+			if bits != 0 && j > 0 {
+				v |= w.mask[j-1] >> (64 - bits)
+			}
+		}
+		w.mask[i] = v
+	}
+	if r := w.size % 64; r != 0 {
+		w.mask[len(w.mask)-1] &= 1<<r - 1
+	}
+}
+
+// appendTo appends the window to b, as a word count followed by latest and
+// the words of mask. A nil window has no words.
+func (w *replayWindow) appendTo(b []byte) []byte {
+	if w == nil {
+		return binary.BigEndian.AppendUint16(b, 0)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(w.mask)))
+	b = binary.BigEndian.AppendUint64(b, w.latest)
+	for _, word := range w.mask {
+		b = binary.BigEndian.AppendUint64(b, word)
+	}
+	return b
+}
+
+// contextStateReader reads the big-endian fields written by MarshalBinary.
+// After the data is exhausted, err is set and zero values are returned.
+type contextStateReader struct {
+	data []byte
+	err  error
+}
+
+// replayWindow reads a window written by replayWindow.appendTo, and accepts
+// its indexes in order with d and w, which may be nil.
+func (r *contextStateReader) replayWindow(d replaydetector.ReplayDetector, w *replayWindow) {
+	words := int(r.uint16())
+	if words == 0 {
+		return
+	}
+	latest := r.uint64()
+	mask := make([]uint64, words)
+	for i := range mask {
+		mask[i] = r.uint64()
+	}
+	for i := uint64(words)*64 - 1; r.err == nil; i-- {
+		if mask[i/64]&(1<<(i%64)) != 0 && i <= latest {
+			if accept, ok := d.Check(latest - i); ok {
+				accept()
+			}
+			if w != nil {
+				w.accept(latest - i)
+			}
+		}
+		if i == 0 {
+			break
+		}
+	}
+}
+
+func (r *contextStateReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	} else if len(r.data) < n {
+		r.err = fmt.Errorf("%w: truncated", errInvalidContextState)
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *contextStateReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *contextStateReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *contextStateReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *contextStateReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
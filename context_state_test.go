@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testContextMarshalBinary(t *testing.T, profile ProtectionProfile, opts ...ContextOption) {
+	assert := assert.New(t)
+	const ssrc = 0xcafebabe
+
+	sender, err := buildTestContext(profile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverOpts := append([]ContextOption{SRTPReplayProtection(64), SRTCPReplayProtection(64)}, opts...)
+	receiver, err := buildTestContext(profile, receiverOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptRTP := func(seq uint16) []byte {
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: ssrc, SequenceNumber: seq}}
+		if err = pkt.Header.SetExtension(1, []byte{0x01, 0x02}); err != nil {
+			t.Fatal(err)
+		}
+		raw, errMarshal := pkt.Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := sender.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+	encryptRTCP := func() []byte {
+		encrypted, errEnc := sender.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+
+	var beforeRTP, beforeRTCP [][]byte
+	for seq := 65530; seq < 65536+5; seq++ {
+		beforeRTP = append(beforeRTP, encryptRTP(uint16(seq)))
+		beforeRTCP = append(beforeRTCP, encryptRTCP())
+	}
+	for i := range beforeRTP {
+		_, err = receiver.DecryptRTP(nil, beforeRTP[i], nil)
+		assert.NoError(err)
+		_, err = receiver.DecryptRTCP(nil, beforeRTCP[i], nil)
+		assert.NoError(err)
+	}
+
+	senderState, err := sender.MarshalBinary()
+	assert.NoError(err)
+	receiverState, err := receiver.MarshalBinary()
+	assert.NoError(err)
+	assert.NoError(sender.Close())
+	assert.NoError(receiver.Close())
+
+	if sender, err = RestoreContext(senderState); err != nil {
+		t.Fatal(err)
+	}
+	if receiver, err = RestoreContext(receiverState, SRTPReplayProtection(64), SRTCPReplayProtection(64)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restored state marshals the same way.
+	state, err := receiver.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(receiverState, state)
+
+	// Packets received before the restart are rejected.
+	for i := range beforeRTP {
+		_, err = receiver.DecryptRTP(nil, beforeRTP[i], nil)
+		assert.ErrorIs(err, ErrDuplicated)
+		_, err = receiver.DecryptRTCP(nil, beforeRTCP[i], nil)
+		assert.ErrorIs(err, ErrDuplicated)
+	}
+
+	// The restored sender continues with the same ROC and SRTCP index.
+	for seq := uint16(5); seq < 10; seq++ {
+		_, err = receiver.DecryptRTP(nil, encryptRTP(seq), nil)
+		assert.NoError(err, "seq=%d", seq)
+		_, err = receiver.DecryptRTCP(nil, encryptRTCP(), nil)
+		assert.NoError(err, "seq=%d", seq)
+	}
+	roc, _ := receiver.ROC(ssrc)
+	assert.Equal(uint32(1), roc)
+}
+
+func TestContextMarshalBinary(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testContextMarshalBinary(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testContextMarshalBinary(t, profileGCM) })
+	t.Run("KDR", func(t *testing.T) { testContextMarshalBinary(t, profileCTR, KeyDerivationRate(2)) })
+	t.Run("MKI", func(t *testing.T) { testContextMarshalBinary(t, profileCTR, MasterKeyIndicator([]byte{0x01, 0x02})) })
+	t.Run("HeaderExtensions", func(t *testing.T) { testContextMarshalBinary(t, profileCTR, SRTPEncryptedHeaderExtensions(1)) })
+	t.Run("Cryptex", func(t *testing.T) { testContextMarshalBinary(t, profileGCM, SRTPCryptex()) })
+}
+
+func TestContextMarshalBinaryReplayWindow(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 0xcafebabe
+
+	sender, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := buildTestContext(profileCTR, SRTPReplayProtection(100), SRTCPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rtpPackets, rtcpPackets [][]byte
+	for seq := uint16(0); seq < 80; seq++ {
+		raw, errMarshal := (&rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: ssrc, SequenceNumber: seq}}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := sender.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		rtpPackets = append(rtpPackets, encrypted)
+		if encrypted, errEnc = sender.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}, nil); errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		rtcpPackets = append(rtcpPackets, encrypted)
+	}
+
+	// Packets 5 and 70 are still in flight when the state is saved.
+	for i := range rtpPackets {
+		if i == 5 || i == 70 {
+			continue
+		}
+		_, err = receiver.DecryptRTP(nil, rtpPackets[i], nil)
+		assert.NoError(err)
+		_, err = receiver.DecryptRTCP(nil, rtcpPackets[i], nil)
+		assert.NoError(err)
+	}
+	state, err := receiver.MarshalBinary()
+	assert.NoError(err)
+	assert.NoError(receiver.Close())
+
+	// Replay protection is restored from the state.
+	if receiver, err = RestoreContext(state); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{5, 70} {
+		_, err = receiver.DecryptRTP(nil, rtpPackets[i], nil)
+		assert.NoError(err, "seq=%d", i)
+		_, err = receiver.DecryptRTCP(nil, rtcpPackets[i], nil)
+		if i < 80-64 {
+			// Outside of the SRTCP window
+			assert.ErrorIs(err, ErrDuplicated, "index=%d", i)
+		} else {
+			assert.NoError(err, "index=%d", i)
+		}
+	}
+	for _, i := range []int{4, 5, 6, 69, 70, 79} {
+		_, err = receiver.DecryptRTP(nil, rtpPackets[i], nil)
+		assert.ErrorIs(err, ErrDuplicated, "seq=%d", i)
+		_, err = receiver.DecryptRTCP(nil, rtcpPackets[i], nil)
+		assert.ErrorIs(err, ErrDuplicated, "index=%d", i)
+	}
+}
+
+func TestContextMarshalBinaryMKI(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := buildTestContext(profileCTR, MasterKeyIndicator([]byte{0x01}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(c.AddCipherForMKI([]byte{0x02}, make([]byte, 16), make([]byte, 14)))
+	assert.NoError(c.AddCipherForMKI([]byte{0x03}, make([]byte, 16), make([]byte, 14)))
+	assert.NoError(c.SetSendMKI([]byte{0x02}))
+
+	state, err := c.MarshalBinary()
+	assert.NoError(err)
+	restored, err := RestoreContext(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]byte{0x02}, restored.sendMKI)
+	assert.Len(restored.mkis, 3)
+	assert.Same(restored.mkis["\x02"], restored.keys)
+}
+
+func TestContextUnmarshalBinaryInvalid(t *testing.T) {
+	c, err := buildTestContext(profileCTR, MasterKeyIndicator([]byte{0x01}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.EncryptRTP(nil, []byte{0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}, nil); err != nil {
+		t.Fatal(err)
+	}
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(state); i++ {
+		if _, err = RestoreContext(state[:i]); !errors.Is(err, errInvalidContextState) {
+			t.Errorf("Expected error '%v' for %d bytes, got '%v'", errInvalidContextState, i, err)
+		}
+	}
+	_, err = RestoreContext(append(append([]byte{}, state...), 0x00))
+	assert.ErrorIs(t, err, errInvalidContextState)
+
+	invalid := append([]byte{}, state...)
+	invalid[0] = 0xff
+	_, err = RestoreContext(invalid)
+	assert.ErrorIs(t, err, errInvalidContextState)
+
+	invalid = append([]byte{}, state...)
+	invalid[1], invalid[2] = 0x12, 0x34
+	_, err = RestoreContext(invalid)
+	assert.ErrorIs(t, err, errNoSuchSRTPProfile)
+
+	assert.ErrorIs(t, c.UnmarshalBinary(state), errContextAlreadyInUse)
+
+	assert.NoError(t, c.Close())
+	_, err = c.MarshalBinary()
+	assert.ErrorIs(t, err, errContextClosed)
+}
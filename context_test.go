@@ -4,6 +4,8 @@
 package srtp
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -26,6 +28,41 @@ func TestContextROC(t *testing.T) {
 	}
 }
 
+func TestContextEstimateIndex(t *testing.T) {
+	c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, err := c.EstimateIndex(123, 10); err != nil || index != 10 {
+		t.Errorf("EstimateIndex of unused SSRC returned %d, %v", index, err)
+	}
+	if _, ok := c.ROC(123); ok {
+		t.Error("EstimateIndex must not create the state of an SSRC")
+	}
+
+	c.SetROC(123, 5)
+	if index, err := c.EstimateIndex(123, 10); err != nil || index != 5<<16|10 {
+		t.Errorf("EstimateIndex after SetROC returned %d, %v", index, err)
+	}
+
+	if _, err = c.EncryptRTP(nil, []byte{0x80, 0x00, 0xff, 0xf0, 0, 0, 0, 0, 0, 0, 0, 123}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for seq, expected := range map[uint16]uint64{
+		0xfff0: 5<<16 | 0xfff0,
+		0x0005: 6<<16 | 0x0005,
+		0x8000: 5<<16 | 0x8000,
+	} {
+		if index, err := c.EstimateIndex(123, seq); err != nil || index != expected {
+			t.Errorf("EstimateIndex(%#x) returned %d, %v, expected %d", seq, index, err, expected)
+		}
+	}
+	if roc, _ := c.ROC(123); roc != 5 {
+		t.Errorf("EstimateIndex must not update the ROC, got %d", roc)
+	}
+}
+
 func TestContextIndex(t *testing.T) {
 	c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR)
 	if err != nil {
@@ -44,3 +81,467 @@ func TestContextIndex(t *testing.T) {
 		t.Errorf("Index is set to 100, but returned %d", index)
 	}
 }
+
+func testContextClose(t *testing.T, profile ProtectionProfile, opts ...ContextOption) {
+	c, err := buildTestContext(profile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtpPacket := append([]byte{0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}, rtpTestCaseDecrypted()...)
+	if _, err = c.EncryptRTP(nil, rtpPacket, nil); err != nil {
+		t.Fatal(err)
+	}
+	rtcpPacket := []byte{0x81, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}
+	if _, err = c.EncryptRTCP(nil, rtcpPacket, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+
+	if _, err = c.EncryptRTP(nil, rtpPacket, nil); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+	if _, err = c.DecryptRTP(nil, rtpPacket, nil); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+	if _, err = c.EncryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+	if _, err = c.DecryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+}
+
+func TestContextClose(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testContextClose(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testContextClose(t, profileGCM) })
+	t.Run("KDR", func(t *testing.T) { testContextClose(t, profileCTR, KeyDerivationRate(4)) })
+}
+
+func TestContextCloseZeroesSalts(t *testing.T) {
+	c, err := buildTestContext(profileCTR, KeyDerivationRate(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, ok := c.keys.cipher.(*srtpCipherAesCmHmacSha1)
+	if !ok {
+		t.Fatal("unexpected cipher type")
+	}
+	salts := [][]byte{
+		cipher.srtpSessionSalt, cipher.srtcpSessionSalt, cipher.srtpSessionAuthKey, cipher.srtcpSessionAuthKey,
+		c.keys.masterKey, c.keys.masterSalt,
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, salt := range salts {
+		if !bytes.Equal(salt, make([]byte, len(salt))) {
+			t.Errorf("Key material is not zeroed: %x", salt)
+		}
+	}
+}
+
+func testContextClone(t *testing.T, profile ProtectionProfile, opts ...ContextOption) {
+	c, err := buildTestContext(profile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference, err := buildTestContext(profile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtpPacket := func(seq uint16) []byte {
+		return append([]byte{
+			0x90, 0x0f, byte(seq >> 8), byte(seq), 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x01,
+			0xBE, 0xDE, 0x00, 0x01, 0x10, 0xAA, 0x00, 0x00,
+		}, rtpTestCaseDecrypted()...)
+	}
+	rtcpPacket := []byte{0x81, 0xc8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}
+	if _, err = c.EncryptRTP(nil, rtpPacket(1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.EncryptRTCP(nil, rtcpPacket, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := c.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clone.ROC(1); ok {
+		t.Error("The SSRC state was cloned")
+	}
+
+	// The clone keeps working once the original Context is closed, and
+	// protects packets like a new Context.
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint16(1); seq <= 2; seq++ {
+		expected, errEnc := reference.EncryptRTP(nil, rtpPacket(seq), nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		actual, errEnc := clone.EncryptRTP(nil, rtpPacket(seq), nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("SRTP packet %d: expected %x, got %x", seq, expected, actual)
+		}
+
+		expected, errEnc = reference.EncryptRTCP(nil, rtcpPacket, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		actual, errEnc = clone.EncryptRTCP(nil, rtcpPacket, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("SRTCP packet %d: expected %x, got %x", seq, expected, actual)
+		}
+	}
+
+	if err = clone.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = reference.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = clone.Clone(); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected cloning a closed Context to fail, got %v", err)
+	}
+}
+
+func TestContextClone(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testContextClone(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testContextClone(t, profileGCM) })
+	t.Run("KDR", func(t *testing.T) { testContextClone(t, profileCTR, KeyDerivationRate(1)) })
+	t.Run("MKI", func(t *testing.T) { testContextClone(t, profileCTR, MasterKeyIndicator([]byte{0x01})) })
+	t.Run("HeaderExtensions", func(t *testing.T) { testContextClone(t, profileCTR, SRTPEncryptedHeaderExtensions(1)) })
+	t.Run("Keystreams", func(t *testing.T) { testContextClone(t, profileCTR, SRTPKeystreamPrecomputation(2, 100)) })
+}
+
+func TestContextReset(t *testing.T) {
+	encryptContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := buildTestContext(profileCTR, SRTPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptContext.EncryptRTP(nil, append([]byte{
+		0x80, 0x0f, 0x00, 0x01, 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x01,
+	}, rtpTestCaseDecrypted()...), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.DecryptRTP(nil, encrypted, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.DecryptRTP(nil, encrypted, nil); !errors.Is(err, ErrDuplicated) {
+		t.Fatalf("Expected a replay, got %v", err)
+	}
+
+	// The replay window and the ROC are dropped, the keys kept.
+	if err = c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.ROC(1); ok {
+		t.Error("The SSRC state was not reset")
+	}
+	if _, err = c.DecryptRTP(nil, encrypted, nil); err != nil {
+		t.Errorf("Failed to decrypt after a reset: %v", err)
+	}
+	if stats := c.Stats(); stats.SRTP[1].Packets != 1 {
+		t.Errorf("Expected the statistics to be reset, got %v", stats.SRTP[1])
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Reset(); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected resetting a closed Context to fail, got %v", err)
+	}
+}
+
+func TestContextMKI(t *testing.T) {
+	mki := []byte{0x01, 0x02}
+
+	c, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AddCipherForMKI(mki, make([]byte, 16), make([]byte, 14)); !errors.Is(err, errMKIIsNotEnabled) {
+		t.Errorf("Expected error '%v', got '%v'", errMKIIsNotEnabled, err)
+	}
+
+	if _, err = buildTestContext(profileCTR, MasterKeyIndicator(nil)); !errors.Is(err, errInvalidMKILength) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidMKILength, err)
+	}
+
+	c, err = buildTestContext(profileCTR, MasterKeyIndicator(mki))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AddCipherForMKI([]byte{0x01}, make([]byte, 16), make([]byte, 14)); !errors.Is(err, errInvalidMKILength) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidMKILength, err)
+	}
+	if err = c.AddCipherForMKI(mki, make([]byte, 16), make([]byte, 14)); !errors.Is(err, errMKIAlreadyInUse) {
+		t.Errorf("Expected error '%v', got '%v'", errMKIAlreadyInUse, err)
+	}
+	if err = c.AddCipherForMKI([]byte{0x03, 0x04}, make([]byte, 15), make([]byte, 14)); !errors.Is(err, errShortSrtpMasterKey) {
+		t.Errorf("Expected error '%v', got '%v'", errShortSrtpMasterKey, err)
+	}
+	if err = c.SetSendMKI([]byte{0x03, 0x04}); !errors.Is(err, ErrMKINotFound) {
+		t.Errorf("Expected error '%v', got '%v'", ErrMKINotFound, err)
+	}
+	if err = c.RemoveMKI(mki); !errors.Is(err, errMKIAlreadyInUse) {
+		t.Errorf("Expected error '%v', got '%v'", errMKIAlreadyInUse, err)
+	}
+	if err = c.RemoveMKI([]byte{0x03, 0x04}); !errors.Is(err, ErrMKINotFound) {
+		t.Errorf("Expected error '%v', got '%v'", ErrMKINotFound, err)
+	}
+}
+
+func testContextUpdateMasterKey(t *testing.T, profile ProtectionProfile, opts ...ContextOption) {
+	keyLen, err := profile.keyLen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saltLen, err := profile.saltLen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterKey2, masterSalt2 := bytes.Repeat([]byte{0x02}, keyLen), bytes.Repeat([]byte{0x02}, saltLen)
+
+	encryptContext, err := CreateContext(make([]byte, keyLen), make([]byte, saltLen), profile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := CreateContext(make([]byte, keyLen), make([]byte, saltLen), profile, append(opts, SRTPReplayProtection(64))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt := func(seq uint16) []byte {
+		pkt := []byte{0x80, 0x0f, byte(seq >> 8), byte(seq), 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, pkt, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+
+	oldKey := encrypt(65534)
+	if _, err = decryptContext.DecryptRTP(nil, oldKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	late := encrypt(65535)
+
+	if err = encryptContext.UpdateMasterKey(masterKey2, masterSalt2); err != nil {
+		t.Fatal(err)
+	}
+	if err = decryptContext.UpdateMasterKey(masterKey2, masterSalt2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rollover counter is kept across the update.
+	if _, err = decryptContext.DecryptRTP(nil, encrypt(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if roc, _ := decryptContext.ROC(0xcafebabe); roc != 1 {
+		t.Errorf("ROC must be kept by UpdateMasterKey, got %d", roc)
+	}
+
+	// Packets protected with the old master key can no longer be decrypted,
+	// and the replay window is kept.
+	if _, err = decryptContext.DecryptRTP(nil, late, nil); !errors.Is(err, ErrFailedToVerifyAuthTag) {
+		t.Errorf("Expected error '%v', got '%v'", ErrFailedToVerifyAuthTag, err)
+	}
+	if _, err = decryptContext.DecryptRTP(nil, oldKey, nil); !errors.Is(err, ErrDuplicated) {
+		t.Errorf("Expected error '%v', got '%v'", ErrDuplicated, err)
+	}
+
+	if err = decryptContext.UpdateMasterKey(masterKey2[1:], masterSalt2); !errors.Is(err, errShortSrtpMasterKey) {
+		t.Errorf("Expected error '%v', got '%v'", errShortSrtpMasterKey, err)
+	}
+	if _, err = decryptContext.DecryptRTP(nil, encrypt(1), nil); err != nil {
+		t.Errorf("Failed update must keep the master key: %v", err)
+	}
+
+	if err = decryptContext.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = decryptContext.UpdateMasterKey(masterKey2, masterSalt2); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+}
+
+func TestContextUpdateMasterKey(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testContextUpdateMasterKey(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testContextUpdateMasterKey(t, profileGCM) })
+	t.Run("KDR", func(t *testing.T) { testContextUpdateMasterKey(t, profileCTR, KeyDerivationRate(1)) })
+	t.Run("MKI", func(t *testing.T) { testContextUpdateMasterKey(t, profileCTR, MasterKeyIndicator([]byte{0x01})) })
+}
+
+func TestContextStateTransfer(t *testing.T) {
+	const ssrc = 0xcafebabe
+	newContext := func(opts ...ContextOption) *Context {
+		c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	oldSender := newContext()
+	receiver := newContext(SRTPReplayProtection(64), SRTCPReplayProtection(64))
+
+	send := func(c *Context, seq uint16) {
+		pkt := []byte{0x80, 0x0f, byte(seq >> 8), byte(seq), 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}
+		encrypted, err := c.EncryptRTP(nil, pkt, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = receiver.DecryptRTP(nil, encrypted, nil); err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+
+		rtcpPkt := []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}
+		if encrypted, err = c.EncryptRTCP(nil, rtcpPkt, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = receiver.DecryptRTCP(nil, encrypted, nil); err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+	}
+
+	for seq := 65530; seq < 65536+5; seq++ {
+		send(oldSender, uint16(seq))
+	}
+
+	// Migrate the sender to a new Context, e.g. on another server.
+	roc, ok := oldSender.ROC(ssrc)
+	if !ok || roc != 1 {
+		t.Fatalf("Expected ROC 1, got %d", roc)
+	}
+	index, ok := oldSender.Index(ssrc)
+	if !ok {
+		t.Fatal("Index must return true for used SSRC")
+	}
+	newSender := newContext()
+	newSender.SetROC(ssrc, roc)
+	newSender.SetIndex(ssrc, index)
+
+	for seq := 5; seq < 10; seq++ {
+		send(newSender, uint16(seq))
+	}
+	if roc, _ = receiver.ROC(ssrc); roc != 1 {
+		t.Errorf("Expected receiver ROC 1, got %d", roc)
+	}
+}
+
+func TestContextMasterKeyLifetime(t *testing.T) {
+	var expiring [][]byte
+	c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR,
+		MasterKeyIndicator([]byte{0x01}),
+		MasterKeyLifetime(3, 2),
+		MasterKeyExpiry(1, func(mki []byte) { expiring = append(expiring, mki) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptRTP := func(seq uint16) error {
+		_, err := c.EncryptRTP(nil, []byte{0x80, 0x0f, byte(seq >> 8), byte(seq), 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}, nil)
+		return err
+	}
+	encryptRTCP := func() error {
+		_, err := c.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}, nil)
+		return err
+	}
+
+	for seq := uint16(1); seq <= 3; seq++ {
+		if err = encryptRTP(seq); err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+	}
+	if len(expiring) != 1 || !bytes.Equal(expiring[0], []byte{0x01}) {
+		t.Errorf("Expected one expiry callback for MKI 0x01, got %v", expiring)
+	}
+	if err = encryptRTP(4); !errors.Is(err, ErrKeyLifetimeExceeded) {
+		t.Errorf("Expected error '%v', got '%v'", ErrKeyLifetimeExceeded, err)
+	}
+
+	// SRTCP packets are counted separately.
+	for i := 0; i < 2; i++ {
+		if err = encryptRTCP(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = encryptRTCP(); !errors.Is(err, ErrKeyLifetimeExceeded) {
+		t.Errorf("Expected error '%v', got '%v'", ErrKeyLifetimeExceeded, err)
+	}
+	if len(expiring) != 1 {
+		t.Errorf("Expected the expiry callback to be called once per master key, got %v", expiring)
+	}
+
+	// The count survives a restart.
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreContext(state, MasterKeyLifetime(3, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = restored.EncryptRTP(nil, []byte{0x80, 0x0f, 0x00, 0x05, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}, nil); !errors.Is(err, ErrKeyLifetimeExceeded) {
+		t.Errorf("Expected error '%v', got '%v'", ErrKeyLifetimeExceeded, err)
+	}
+
+	// A new master key has a new lifetime.
+	if err = c.AddCipherForMKI([]byte{0x02}, make([]byte, 16), make([]byte, 14)); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.SetSendMKI([]byte{0x02}); err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint16(4); seq <= 5; seq++ {
+		if err = encryptRTP(seq); err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+	}
+	if len(expiring) != 2 || !bytes.Equal(expiring[1], []byte{0x02}) {
+		t.Errorf("Expected an expiry callback for MKI 0x02, got %v", expiring)
+	}
+	if err = encryptRTCP(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextMasterKeyLifetimeDefault(t *testing.T) {
+	c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR, MasterKeyLifetime(1<<62, 1<<40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.srtpKeyLifetime != 0 || c.srtcpKeyLifetime != 0 {
+		t.Errorf("Expected lifetimes above the RFC 3711 maximum to select it, got %d and %d", c.srtpKeyLifetime, c.srtcpKeyLifetime)
+	}
+
+	c.keys.srtcpPackets = maxSRTCPKeyLifetime
+	if _, err = c.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}, nil); !errors.Is(err, ErrKeyLifetimeExceeded) {
+		t.Errorf("Expected error '%v', got '%v'", ErrKeyLifetimeExceeded, err)
+	}
+}
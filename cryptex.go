@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+
+	"github.com/pion/rtp"
+)
+
+// Extension profiles replacing 0xBEDE and 0x100X in packets protected with
+// cryptex.
+const (
+	cryptexProfileOneByte = 0xC0DE
+	cryptexProfileTwoByte = 0xC2DE
+)
+
+// With cryptex, the CSRCs, the header extension data and the payload are
+// encrypted as one block, and only the fixed header and the 4-octet extension
+// header stay in the clear. The extension header is moved right after the
+// fixed header while the packet is encrypted or decrypted, so that the
+// encrypted portion starts at cryptexEncryptedOffset, and the fixed header and
+// the extension header are the AAD of the AEAD profiles.
+//
+// https://tools.ietf.org/html/rfc9335#section-5
+const cryptexEncryptedOffset = 16
+
+// cryptexApplies reports whether a packet with the given header is protected
+// with cryptex: it must carry CSRCs or a one-byte or two-byte header extension
+// (RFC 8285). Packets with other extension profiles are left untouched.
+func cryptexApplies(header *rtp.Header) bool {
+	if !header.Extension {
+		return len(header.CSRC) != 0
+	}
+	return header.ExtensionProfile == headerExtensionProfileOneByte ||
+		header.ExtensionProfile&0xFFF0 == headerExtensionProfileTwoByte
+}
+
+// cryptexMarshalSize returns the size of the header marshaled by
+// marshalCryptexHeader.
+func cryptexMarshalSize(header *rtp.Header) int {
+	if !header.Extension {
+		return header.MarshalSize() + 4
+	}
+	return header.MarshalSize()
+}
+
+// marshalCryptexHeader marshals the header with the cryptex extension profile.
+// A packet with CSRCs but without header extension gets an empty one, so that
+// the receiver knows the CSRCs are encrypted.
+func marshalCryptexHeader(buf []byte, header *rtp.Header) (int, error) {
+	n, err := header.MarshalTo(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	if !header.Extension {
+		buf[0] |= 0x10
+		binary.BigEndian.PutUint16(buf[n:], cryptexProfileOneByte)
+		binary.BigEndian.PutUint16(buf[n+2:], 0)
+		return n + 4, nil
+	}
+
+	offset := 12 + 4*len(header.CSRC)
+	if header.ExtensionProfile == headerExtensionProfileOneByte {
+		binary.BigEndian.PutUint16(buf[offset:], cryptexProfileOneByte)
+	} else {
+		// The appbits of the two-byte profile are not carried.
+		binary.BigEndian.PutUint16(buf[offset:], cryptexProfileTwoByte)
+	}
+	return n, nil
+}
+
+// isCryptexHeader reports whether the marshaled header in buf uses a cryptex
+// extension profile.
+func isCryptexHeader(buf []byte) bool {
+	if len(buf) < 12 || buf[0]&0x10 == 0 {
+		return false
+	}
+	offset := 12 + 4*int(buf[0]&0x0f)
+	if len(buf) < offset+4 {
+		return false
+	}
+	profile := binary.BigEndian.Uint16(buf[offset:])
+	return profile == cryptexProfileOneByte || profile == cryptexProfileTwoByte
+}
+
+// restoreCryptexProfile replaces the cryptex extension profile of the
+// decrypted header in buf with the RFC 8285 one.
+func restoreCryptexProfile(buf []byte) {
+	offset := 12 + 4*int(buf[0]&0x0f)
+	if binary.BigEndian.Uint16(buf[offset:]) == cryptexProfileOneByte {
+		binary.BigEndian.PutUint16(buf[offset:], headerExtensionProfileOneByte)
+	} else {
+		binary.BigEndian.PutUint16(buf[offset:], headerExtensionProfileTwoByte)
+	}
+}
+
+// moveCryptexExtensionHeader moves the extension header of the marshaled
+// header in buf in front of the CSRCs, or back after them.
+func moveCryptexExtensionHeader(buf []byte, toFront bool) {
+	csrcLen := 4 * int(buf[0]&0x0f)
+	var extensionHeader [4]byte
+	if toFront {
+		copy(extensionHeader[:], buf[12+csrcLen:])
+		copy(buf[16:], buf[12:12+csrcLen])
+		copy(buf[12:], extensionHeader[:])
+	} else {
+		copy(extensionHeader[:], buf[12:])
+		copy(buf[12:], buf[16:16+csrcLen])
+		copy(buf[12+csrcLen:], extensionHeader[:])
+	}
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRTPCryptex(t *testing.T, profile ProtectionProfile, csrc []uint32, extensionProfile uint16) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profile, SRTPCryptex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profile, SRTPCryptex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := buildHeaderExtensionPacket(t, extensionProfile, csrc...)
+	plainHeader := &rtp.Header{}
+	if _, err = plainHeader.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fixed header stays in the clear, the extension profile tells the
+	// receiver that cryptex is used.
+	assert.Equal(raw[1:12], encrypted[1:12])
+	assert.True(isCryptexHeader(encrypted))
+	encryptedHeader := &rtp.Header{}
+	if _, err = encryptedHeader.Unmarshal(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(encryptedHeader.CSRC, len(csrc))
+	if len(csrc) != 0 {
+		assert.NotEqual(csrc, encryptedHeader.CSRC)
+	}
+	if extensionProfile == headerExtensionProfileOneByte || extensionProfile == 0 {
+		assert.Equal(uint16(cryptexProfileOneByte), encryptedHeader.ExtensionProfile)
+	} else {
+		assert.Equal(uint16(cryptexProfileTwoByte), encryptedHeader.ExtensionProfile)
+	}
+	if extensionProfile != 0 {
+		offset := 12 + 4*len(csrc) + 4
+		assert.NotEqual(raw[offset:offset+8], encrypted[offset:offset+8])
+	}
+
+	decryptedHeader := &rtp.Header{}
+	decrypted, err := decryptContext.DecryptRTP(nil, encrypted, decryptedHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(plainHeader.CSRC, decryptedHeader.CSRC)
+	assert.Equal(rtpTestCaseDecrypted(), decrypted[len(decrypted)-len(rtpTestCaseDecrypted()):])
+	if extensionProfile != 0 {
+		assert.Equal(raw, decrypted)
+		assert.Equal([]byte{0x10, 0x11, 0x12}, decryptedHeader.GetExtension(1))
+		assert.Equal([]byte{0x20, 0x21, 0x22, 0x23, 0x24}, decryptedHeader.GetExtension(2))
+	} else {
+		// An empty one-byte header extension has been added to signal cryptex.
+		assert.True(decryptedHeader.Extension)
+		assert.Equal(uint16(headerExtensionProfileOneByte), decryptedHeader.ExtensionProfile)
+		assert.Len(decrypted, len(raw)+4)
+	}
+
+	// The extension header is authenticated.
+	tampered := append([]byte{}, encrypted...)
+	tampered[12+4*len(csrc)+3] ^= 0x01
+	_, err = decryptContext.DecryptRTP(nil, tampered, nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+}
+
+func TestRTPCryptex(t *testing.T) {
+	csrc := []uint32{0xaabbccdd, 0x01020304}
+	for name, profile := range map[string]ProtectionProfile{"CTR": profileCTR, "GCM": profileGCM} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			t.Run("CSRC", func(t *testing.T) { testRTPCryptex(t, profile, csrc, 0) })
+			t.Run("OneByte", func(t *testing.T) { testRTPCryptex(t, profile, nil, headerExtensionProfileOneByte) })
+			t.Run("TwoByte", func(t *testing.T) { testRTPCryptex(t, profile, nil, headerExtensionProfileTwoByte) })
+			t.Run("CSRCOneByte", func(t *testing.T) { testRTPCryptex(t, profile, csrc, headerExtensionProfileOneByte) })
+			t.Run("CSRCTwoByte", func(t *testing.T) { testRTPCryptex(t, profile, csrc, headerExtensionProfileTwoByte) })
+		})
+	}
+}
+
+func TestRTPCryptexKeystream(t *testing.T) {
+	assert := assert.New(t)
+
+	cryptexContext, err := buildTestContext(profileCTR, SRTPCryptex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CSRCs, the extension data and the payload are encrypted as one
+	// block, the same way as a payload consisting of the three.
+	raw := buildHeaderExtensionPacket(t, headerExtensionProfileOneByte, 0xaabbccdd)
+	encrypted, err := cryptexContext.EncryptRTP(nil, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := append(append([]byte{}, raw[12:16]...), raw[20:]...)
+	pkt := &rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 0x11223344, SequenceNumber: 5000}, Payload: block}
+	plain, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := plainContext.EncryptRTP(nil, plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(expected[12:16], encrypted[12:16])
+	assert.Equal(uint16(cryptexProfileOneByte), binary.BigEndian.Uint16(encrypted[16:]))
+	assert.Equal(raw[18:20], encrypted[18:20])
+	assert.Equal(expected[16:12+len(block)], encrypted[20:len(raw)])
+}
+
+func TestRTPCryptexNotApplied(t *testing.T) {
+	for _, profile := range []ProtectionProfile{profileCTR, profileGCM} {
+		assert := assert.New(t)
+
+		cryptexContext, err := buildTestContext(profile, SRTPCryptex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		plainContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Packets without CSRCs and header extensions are not changed.
+		raw := buildHeaderExtensionPacket(t, 0)
+		encrypted, err := cryptexContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		expected, err := plainContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		assert.Equal(expected, encrypted)
+
+		// Packets sent without cryptex are accepted.
+		raw = buildHeaderExtensionPacket(t, headerExtensionProfileOneByte, 0xaabbccdd)
+		encrypted, err = plainContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		decrypted, err := cryptexContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err)
+		assert.Equal(raw, decrypted)
+	}
+}
+
+func TestRTPCryptexWithEncryptedHeaderExtensions(t *testing.T) {
+	if _, err := buildTestContext(profileCTR, SRTPCryptex(), SRTPEncryptedHeaderExtensions(1)); !errors.Is(err, errCryptexWithEncryptedHeaderExtensions) {
+		t.Errorf("Expected error '%v', got '%v'", errCryptexWithEncryptedHeaderExtensions, err)
+	}
+}
@@ -6,6 +6,7 @@ package srtp
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"fmt"
 	"math/rand"
 	"testing"
 
@@ -46,6 +47,11 @@ func TestXorBytesCTR(t *testing.T) {
 			assert.NoError(t, xorBytesCTR(block, iv, dst, dst))
 			xorBytesCTRReference(block, iv, reference, reference)
 			require.Equal(t, dst, reference)
+
+			// test cipher.NewCTR above the pipelined size
+			assert.NoError(t, (&ctrBuffers{pipelinedSize: 64}).xorBytesCTR(block, iv, dst, src))
+			xorBytesCTRReference(block, iv, reference, src)
+			require.Equal(t, dst, reference)
 		}
 	}
 }
@@ -65,3 +71,54 @@ func TestXorBytesCTRInvalidIvLength(t *testing.T) {
 	test(make([]byte, block.BlockSize()-1))
 	test(make([]byte, block.BlockSize()+1))
 }
+
+// Test vectors from https://tools.ietf.org/html/rfc3711#appendix-B.2
+func TestXorBytesCTRKeyStream(t *testing.T) {
+	sessionKey := []byte{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
+	sessionSalt := []byte{0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd}
+	expectedKeyStream := []byte{
+		0xe0, 0x3e, 0xad, 0x09, 0x35, 0xc9, 0x5e, 0x80, 0xe1, 0x66, 0xb1, 0x6d, 0xd9, 0x2b, 0x4e, 0xb4,
+		0xd2, 0x35, 0x13, 0x16, 0x2b, 0x02, 0xd0, 0xf7, 0x2a, 0x43, 0xa2, 0xfe, 0x4a, 0x5f, 0x97, 0xab,
+		0x41, 0xe9, 0x5b, 0x3b, 0xb0, 0xa2, 0xe8, 0xdd, 0x47, 0x79, 0x01, 0xe4, 0xfc, 0xa8, 0x94, 0xc0,
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	require.NoError(t, err)
+
+	counter := generateCounter(0, 0, 0, sessionSalt)
+	assert.Equal(t, []byte{
+		0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0x00, 0x00,
+	}, counter[:])
+
+	keyStream := make([]byte, len(expectedKeyStream))
+	require.NoError(t, xorBytesCTR(block, counter[:], keyStream, keyStream))
+	assert.Equal(t, expectedKeyStream, keyStream)
+}
+
+// Payload sizes from audio packets to full MTU video packets
+var benchmarkPayloadSizes = []int{60, 160, 500, 1200, 1500}
+
+func BenchmarkXorBytesCTR(b *testing.B) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	require.NoError(b, err)
+	iv := make([]byte, aes.BlockSize)
+
+	for _, size := range benchmarkPayloadSizes {
+		buf := make([]byte, size)
+		for name, ctr := range map[string]*ctrBuffers{
+			"Batched":   {},
+			"Pipelined": {pipelinedSize: 1},
+		} {
+			ctr := ctr
+			b.Run(fmt.Sprintf("%s-%d", name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					if err := ctr.xorBytesCTR(block, iv, buf, buf); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
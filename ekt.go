@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ektMsgTypeShort = 0x00
+	ektMsgTypeFull  = 0x02
+
+	// SPI, Epoch, EKTMsgLength and EKTMsgType following the EKTCiphertext
+	ektFullFieldTrailerSize = 7
+)
+
+// EKTKey is a key of Encrypted Key Transport (EKT), e.g. received in the
+// EKTKey message of DTLS-SRTP. It protects the SRTP master keys which the
+// senders of a conference carry in their packets, so that each sender can
+// use its own master key.
+//
+// https://tools.ietf.org/html/rfc8870
+type EKTKey struct {
+	// SPI identifies the key in full EKT fields.
+	SPI uint16
+
+	// Key is the 16-byte key of the AESKW128 EKT cipher or the 32-byte
+	// key of AESKW256.
+	Key []byte
+
+	// MasterSalt is the SRTP master salt used with every master key sent
+	// under this key.
+	MasterSalt []byte
+}
+
+type ektKey struct {
+	block      cipher.Block
+	masterSalt []byte
+
+	// Epoch of the full EKT fields sent, incremented each time they carry a
+	// new master key. epochKeys is only compared with the current master key
+	// of the Context.
+	epoch     uint16
+	epochKeys *masterKeys
+}
+
+// A new master key learned from a full EKT field
+type ektField struct {
+	keys       *masterKeys
+	roc        uint32
+	spi, epoch uint16
+}
+
+// AddEKTKey adds an EKT key. On a receiving Context, every SRTP packet must
+// then carry an EKT field. The master key of a sender learned from a full
+// EKT field is used for the SRTP and SRTCP packets of its SSRC once a packet
+// has been authenticated with it, and the ROC of the EKT field is used for an
+// SSRC which has not been received before.
+// On a sending Context, the master salt given to CreateContext must be the
+// one of the key, see AppendEKTField.
+// EKT can not be combined with MKI.
+func (c *Context) AddEKTKey(key EKTKey) error {
+	if c.closed {
+		return errContextClosed
+	} else if c.sendMKI != nil {
+		return errEKTWithMKI
+	}
+
+	saltLen, err := c.profile.saltLen()
+	if err != nil {
+		return err
+	}
+	if len(key.Key) != 16 && len(key.Key) != 32 {
+		return fmt.Errorf("%w: key length %d", errInvalidEKTKey, len(key.Key))
+	} else if len(key.MasterSalt) != saltLen {
+		return fmt.Errorf("%w expected(%d) actual(%d)", errShortSrtpMasterSalt, saltLen, len(key.MasterSalt))
+	}
+
+	block, err := aes.NewCipher(key.Key)
+	if err != nil {
+		return err
+	}
+	if c.ektKeys == nil {
+		c.ektKeys = map[uint16]*ektKey{}
+	}
+	c.ektKeys[key.SPI] = &ektKey{block: block, masterSalt: append([]byte{}, key.MasterSalt...)}
+	return nil
+}
+
+// AppendEKTField appends an EKT field to an SRTP packet encrypted by c for
+// ssrc. A full EKT field carries the current master key and ROC, encrypted
+// with the EKT key identified by spi, and an epoch incremented each time the
+// master key changes. A short EKT field is a single octet; full fields are
+// usually sent with the first packets of a stream and periodically
+// afterwards.
+//
+// https://tools.ietf.org/html/rfc8870#section-4.1
+func (c *Context) AppendEKTField(dst []byte, ssrc uint32, spi uint16, full bool) ([]byte, error) {
+	if c.closed {
+		return nil, errContextClosed
+	} else if !full {
+		return append(dst, ektMsgTypeShort), nil
+	}
+
+	k, ok := c.ektKeys[spi]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown SPI %d", errInvalidEKTKey, spi)
+	} else if subtle.ConstantTimeCompare(k.masterSalt, c.keys.masterSalt) != 1 {
+		return nil, fmt.Errorf("%w: master salt differs", errInvalidEKTKey)
+	}
+
+	// EKTPlaintext = SRTPMasterKeyLength SRTPMasterKey SSRC ROC
+	roc, _ := c.ROC(ssrc)
+	plaintext := make([]byte, 0, 1+len(c.keys.masterKey)+8)
+	plaintext = append(plaintext, byte(len(c.keys.masterKey)))
+	plaintext = append(plaintext, c.keys.masterKey...)
+	plaintext = binary.BigEndian.AppendUint32(plaintext, ssrc)
+	plaintext = binary.BigEndian.AppendUint32(plaintext, roc)
+
+	ciphertext := aesKeyWrapWithPadding(k.block, plaintext)
+	zeroBytes(plaintext)
+
+	if k.epochKeys != c.keys {
+		if k.epochKeys != nil {
+			k.epoch++
+		}
+		k.epochKeys = c.keys
+	}
+
+	dst = append(dst, ciphertext...)
+	dst = binary.BigEndian.AppendUint16(dst, spi)
+	dst = binary.BigEndian.AppendUint16(dst, k.epoch)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(ciphertext)+ektFullFieldTrailerSize))
+	return append(dst, ektMsgTypeFull), nil
+}
+
+// processEKTField removes the EKT field from the end of an SRTP packet. For a
+// full EKT field with a known SPI, it returns the master key it carries if it
+// differs from the one of the SSRC. A full EKT field whose epoch is older than
+// the last one accepted for the SPI, or which carries a different master key
+// without a newer epoch, is rejected so that an old key can not be replayed.
+func (c *Context) processEKTField(packet []byte, s *srtpSSRCState) ([]byte, *ektField, error) {
+	if len(packet) == 0 {
+		return nil, nil, fmt.Errorf("%w: empty packet", errInvalidEKTField)
+	}
+
+	switch packet[len(packet)-1] {
+	case ektMsgTypeShort:
+		return packet[:len(packet)-1], nil, nil
+	case ektMsgTypeFull:
+	default:
+		return nil, nil, fmt.Errorf("%w: message type %d", errInvalidEKTField, packet[len(packet)-1])
+	}
+
+	if len(packet) < ektFullFieldTrailerSize {
+		return nil, nil, fmt.Errorf("%w: too short", errInvalidEKTField)
+	}
+	trailer := packet[len(packet)-ektFullFieldTrailerSize:]
+	spi := binary.BigEndian.Uint16(trailer)
+	epoch := binary.BigEndian.Uint16(trailer[2:])
+	fieldLen := int(binary.BigEndian.Uint16(trailer[4:]))
+	if fieldLen <= ektFullFieldTrailerSize || fieldLen > len(packet) {
+		return nil, nil, fmt.Errorf("%w: length %d", errInvalidEKTField, fieldLen)
+	}
+	srtpPacket := packet[:len(packet)-fieldLen]
+
+	// Packets can be processed with the current keys if the SPI is unknown.
+	k, ok := c.ektKeys[spi]
+	if !ok {
+		return srtpPacket, nil, nil
+	}
+	// Epochs are compared like serial numbers, so that they can wrap.
+	lastEpoch, seen := s.ektEpochs[spi]
+	if seen && int16(epoch-lastEpoch) < 0 {
+		return nil, nil, fmt.Errorf("%w: epoch %d is older than %d", errInvalidEKTField, epoch, lastEpoch)
+	}
+
+	plaintext, err := aesKeyUnwrapWithPadding(k.block, packet[len(srtpPacket):len(packet)-ektFullFieldTrailerSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroBytes(plaintext)
+
+	if len(plaintext) < 1 || len(plaintext) != 1+int(plaintext[0])+8 {
+		return nil, nil, fmt.Errorf("%w: plaintext length %d", errInvalidEKTField, len(plaintext))
+	}
+	masterKey := plaintext[1 : 1+plaintext[0]]
+	ssrc := binary.BigEndian.Uint32(plaintext[1+len(masterKey):])
+	roc := binary.BigEndian.Uint32(plaintext[5+len(masterKey):])
+	if ssrc != s.ssrc {
+		return nil, nil, fmt.Errorf("%w: SSRC %d instead of %d", errInvalidEKTField, ssrc, s.ssrc)
+	}
+
+	if s.ektKeys != nil && subtle.ConstantTimeCompare(s.ektKeys.masterKey, masterKey) == 1 &&
+		subtle.ConstantTimeCompare(s.ektKeys.masterSalt, k.masterSalt) == 1 {
+		return srtpPacket, nil, nil
+	} else if seen && epoch == lastEpoch {
+		return nil, nil, fmt.Errorf("%w: new master key without a newer epoch than %d", errInvalidEKTField, lastEpoch)
+	}
+	m, err := c.newMasterKeys(masterKey, k.masterSalt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return srtpPacket, &ektField{keys: m, roc: roc, spi: spi, epoch: epoch}, nil
+}
+
+var aesKeyWrapWithPaddingIV = [4]byte{0xA6, 0x59, 0x59, 0xA6} //nolint:gochecknoglobals
+
+// aesKeyWrapWithPadding implements the AES key wrap with padding algorithm
+// used by the AESKW128 and AESKW256 EKT ciphers.
+//
+// https://tools.ietf.org/html/rfc5649#section-4.1
+func aesKeyWrapWithPadding(block cipher.Block, plaintext []byte) []byte {
+	n := (len(plaintext) + 7) / 8
+	out := make([]byte, 8+8*n)
+	copy(out, aesKeyWrapWithPaddingIV[:])
+	binary.BigEndian.PutUint32(out[4:], uint32(len(plaintext)))
+	copy(out[8:], plaintext)
+
+	if n == 1 {
+		block.Encrypt(out, out)
+		return out
+	}
+
+	var b [16]byte
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+			binary.BigEndian.PutUint64(out, binary.BigEndian.Uint64(b[:8])^uint64(n*j+i))
+			copy(out[8*i:], b[8:])
+		}
+	}
+	zeroBytes(b[:])
+	return out
+}
+
+// aesKeyUnwrapWithPadding reverses aesKeyWrapWithPadding and checks the
+// integrity of the result.
+//
+// https://tools.ietf.org/html/rfc5649#section-4.2
+func aesKeyUnwrapWithPadding(block cipher.Block, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 16 || len(ciphertext)%8 != 0 {
+		return nil, fmt.Errorf("%w: ciphertext length %d", errInvalidEKTField, len(ciphertext))
+	}
+	n := len(ciphertext)/8 - 1
+	out := make([]byte, len(ciphertext))
+	copy(out, ciphertext)
+
+	if n == 1 {
+		block.Decrypt(out, out)
+	} else {
+		var b [16]byte
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(out[:8])^uint64(n*j+i))
+				copy(b[8:], out[8*i:8*i+8])
+				block.Decrypt(b[:], b[:])
+				copy(out[:8], b[:8])
+				copy(out[8*i:], b[8:])
+			}
+		}
+		zeroBytes(b[:])
+	}
+
+	length := int(binary.BigEndian.Uint32(out[4:]))
+	valid := subtle.ConstantTimeCompare(out[:4], aesKeyWrapWithPaddingIV[:]) == 1 &&
+		length > 8*(n-1) && length <= 8*n
+	if valid {
+		for _, p := range out[8+length:] {
+			valid = valid && p == 0
+		}
+	}
+	if !valid {
+		zeroBytes(out)
+		return nil, fmt.Errorf("%w: integrity check failed", errInvalidEKTField)
+	}
+	return out[8 : 8+length], nil
+}
@@ -0,0 +1,273 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"crypto/aes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAESKeyWrapWithPadding(t *testing.T) {
+	// https://tools.ietf.org/html/rfc5649#section-6
+	kek := []byte{
+		0x58, 0x40, 0xdf, 0x6e, 0x29, 0xb0, 0x2a, 0xf1, 0xab, 0x49, 0x3b, 0x70,
+		0x5b, 0xf1, 0x6e, 0xa1, 0xae, 0x83, 0x38, 0xf4, 0xdc, 0xc1, 0x76, 0xa8,
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key, wrapped []byte
+	}{
+		{
+			key: []byte{
+				0xc3, 0x7b, 0x7e, 0x64, 0x92, 0x58, 0x43, 0x40, 0xbe, 0xd1,
+				0x22, 0x07, 0x80, 0x89, 0x41, 0x15, 0x50, 0x68, 0xf7, 0x38,
+			},
+			wrapped: []byte{
+				0x13, 0x8b, 0xde, 0xaa, 0x9b, 0x8f, 0xa7, 0xfc, 0x61, 0xf9, 0x77, 0x42, 0xe7, 0x22, 0x48, 0xee,
+				0x5a, 0xe6, 0xae, 0x53, 0x60, 0xd1, 0xae, 0x6a, 0x5f, 0x54, 0xf3, 0x73, 0xfa, 0x54, 0x3b, 0x6a,
+			},
+		},
+		{
+			key:     []byte{0x46, 0x6f, 0x72, 0x50, 0x61, 0x73, 0x69},
+			wrapped: []byte{0xaf, 0xbe, 0xb0, 0xf0, 0x7d, 0xfb, 0xf5, 0x41, 0x92, 0x00, 0xf2, 0xcc, 0xb5, 0x0b, 0xb2, 0x4f},
+		},
+	} {
+		assert.Equal(t, tc.wrapped, aesKeyWrapWithPadding(block, tc.key))
+
+		unwrapped, err := aesKeyUnwrapWithPadding(block, tc.wrapped)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.key, unwrapped)
+
+		tampered := append([]byte{}, tc.wrapped...)
+		tampered[len(tampered)-1] ^= 0x01
+		_, err = aesKeyUnwrapWithPadding(block, tampered)
+		assert.ErrorIs(t, err, errInvalidEKTField)
+	}
+}
+
+func TestContextEKT(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 0xcafebabe
+
+	ektKey := EKTKey{SPI: 7, Key: make([]byte, 16), MasterSalt: make([]byte, 14)}
+	for i := range ektKey.Key {
+		ektKey.Key[i] = byte(i)
+	}
+	senderKey := []byte{
+		0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39,
+	}
+
+	sender, err := CreateContext(senderKey, ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(sender.AddEKTKey(ektKey))
+	sender.SetROC(ssrc, 5)
+
+	// The receiver does not know the master key of the sender.
+	receiver, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR, SRTPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(receiver.AddEKTKey(ektKey))
+
+	encryptRTP := func(seq uint16, full bool) []byte {
+		pkt := []byte{0x80, 0x0f, byte(seq >> 8), byte(seq), 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}
+		encrypted, errEnc := sender.EncryptRTP(nil, pkt, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		if encrypted, errEnc = sender.AppendEKTField(encrypted, ssrc, ektKey.SPI, full); errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+
+	// Without a full EKT field, the packet can not be authenticated.
+	_, err = receiver.DecryptRTP(nil, encryptRTP(1, false), nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+
+	// A tampered full EKT field is rejected.
+	tampered := encryptRTP(2, true)
+	tampered[len(tampered)-ektFullFieldTrailerSize-1] ^= 0x01
+	_, err = receiver.DecryptRTP(nil, tampered, nil)
+	assert.ErrorIs(err, errInvalidEKTField)
+
+	// The master key and the ROC are learned from the full EKT field.
+	full := encryptRTP(3, true)
+	decrypted, err := receiver.DecryptRTP(nil, full, nil)
+	assert.NoError(err)
+	assert.Equal([]byte{0xab, 0xab}, decrypted[12:])
+	roc, _ := receiver.ROC(ssrc)
+	assert.Equal(uint32(5), roc)
+
+	// And used for the following packets.
+	_, err = receiver.DecryptRTP(nil, encryptRTP(4, false), nil)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, encryptRTP(5, true), nil)
+	assert.NoError(err)
+
+	// As well as for SRTCP.
+	encrypted, err := sender.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}, nil)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTCP(nil, encrypted, nil)
+	assert.NoError(err)
+
+	// A full EKT field for another SSRC is rejected.
+	other := encryptRTP(6, false)
+	other, err = sender.AppendEKTField(other[:len(other)-1], 0x01020304, ektKey.SPI, true)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, other, nil)
+	assert.ErrorIs(err, errInvalidEKTField)
+
+	// A key which fails to authenticate the packet is not kept.
+	attacker, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(attacker.AddEKTKey(ektKey))
+	attacker.ektKeys[ektKey.SPI].epoch = 1
+	forged, err := attacker.EncryptRTP(nil, []byte{0x80, 0x0f, 0x00, 0x07, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}, nil)
+	assert.NoError(err)
+	forged = append(forged[:len(forged)-1], forged[len(forged)-1]^0x01)
+	forged, err = attacker.AppendEKTField(forged, ssrc, ektKey.SPI, true)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, forged, nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+	_, err = receiver.DecryptRTP(nil, encryptRTP(8, false), nil)
+	assert.NoError(err)
+
+	// Unknown message types are rejected.
+	invalid := encryptRTP(9, false)
+	invalid[len(invalid)-1] = 0x01
+	_, err = receiver.DecryptRTP(nil, invalid, nil)
+	assert.ErrorIs(err, errInvalidEKTField)
+
+	// A new master key is sent with a newer epoch, after which full EKT
+	// fields carrying the older key are rejected.
+	old, err := sender.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey := append([]byte{}, senderKey...)
+	newKey[0] ^= 0xff
+	assert.NoError(sender.UpdateMasterKey(newKey, ektKey.MasterSalt))
+	_, err = receiver.DecryptRTP(nil, encryptRTP(10, true), nil)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, encryptRTP(11, false), nil)
+	assert.NoError(err)
+
+	replayed, err := old.EncryptRTP(nil, []byte{0x80, 0x0f, 0x00, 0x0c, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}, nil)
+	assert.NoError(err)
+	replayed, err = old.AppendEKTField(replayed, ssrc, ektKey.SPI, true)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, replayed, nil)
+	assert.ErrorIs(err, errInvalidEKTField)
+	_, err = receiver.DecryptRTP(nil, encryptRTP(13, false), nil)
+	assert.NoError(err)
+}
+
+func TestContextEKTUnauthenticated(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 0xcafebabe
+
+	ektKey := EKTKey{SPI: 7, Key: make([]byte, 16), MasterSalt: make([]byte, 14)}
+	sender, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(sender.AddEKTKey(ektKey))
+	sender.SetROC(ssrc, 5)
+
+	receiver, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(receiver.AddEKTKey(ektKey))
+	receiver.SetROC(ssrc, 9)
+
+	// The ROC of a valid EKT field is not kept if the packet fails to
+	// authenticate.
+	encrypted, err := sender.EncryptRTP(nil, []byte{0x80, 0x0f, 0x00, 0x01, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}, nil)
+	assert.NoError(err)
+	encrypted[len(encrypted)-1] ^= 0x01
+	encrypted, err = sender.AppendEKTField(encrypted, ssrc, ektKey.SPI, true)
+	assert.NoError(err)
+	_, err = receiver.DecryptRTP(nil, encrypted, nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+	roc, ok := receiver.ROC(ssrc)
+	assert.True(ok)
+	assert.Equal(uint32(9), roc)
+}
+
+func TestContextEKTEpochWraparound(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 0xcafebabe
+
+	ektKey := EKTKey{SPI: 7, Key: make([]byte, 16), MasterSalt: make([]byte, 14)}
+	sender, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(sender.AddEKTKey(ektKey))
+	sender.ektKeys[ektKey.SPI].epoch = 0xffff
+
+	receiver, err := CreateContext(make([]byte, 16), ektKey.MasterSalt, profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(receiver.AddEKTKey(ektKey))
+
+	for seq := uint16(1); seq <= 3; seq++ {
+		encrypted, errEnc := sender.EncryptRTP(nil, []byte{0x80, 0x0f, 0x00, byte(seq), 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab}, nil)
+		assert.NoError(errEnc)
+		encrypted, errEnc = sender.AppendEKTField(encrypted, ssrc, ektKey.SPI, true)
+		assert.NoError(errEnc)
+		_, err = receiver.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err, "seq=%d", seq)
+
+		// The epoch wraps from 0xffff to 0 with the next master key.
+		masterKey := make([]byte, 16)
+		masterKey[0] = byte(seq)
+		assert.NoError(sender.UpdateMasterKey(masterKey, ektKey.MasterSalt))
+	}
+	assert.Equal(uint16(1), receiver.srtpSSRCStates[ssrc].ektEpochs[ektKey.SPI])
+}
+
+func TestContextAddEKTKey(t *testing.T) {
+	c, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AddEKTKey(EKTKey{Key: make([]byte, 24), MasterSalt: make([]byte, 14)}); !errors.Is(err, errInvalidEKTKey) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidEKTKey, err)
+	}
+	if err = c.AddEKTKey(EKTKey{Key: make([]byte, 32), MasterSalt: make([]byte, 12)}); !errors.Is(err, errShortSrtpMasterSalt) {
+		t.Errorf("Expected error '%v', got '%v'", errShortSrtpMasterSalt, err)
+	}
+	if _, err = c.AppendEKTField(nil, 1, 1, true); !errors.Is(err, errInvalidEKTKey) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidEKTKey, err)
+	}
+	if err = c.AddEKTKey(EKTKey{SPI: 1, Key: make([]byte, 32), MasterSalt: []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.AppendEKTField(nil, 1, 1, true); !errors.Is(err, errInvalidEKTKey) {
+		t.Errorf("Expected error '%v' for a different master salt, got '%v'", errInvalidEKTKey, err)
+	}
+
+	withMKI, err := CreateContext(make([]byte, 16), make([]byte, 14), profileCTR, MasterKeyIndicator([]byte{0x01}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = withMKI.AddEKTKey(EKTKey{Key: make([]byte, 16), MasterSalt: make([]byte, 14)}); !errors.Is(err, errEKTWithMKI) {
+		t.Errorf("Expected error '%v', got '%v'", errEKTWithMKI, err)
+	}
+}
@@ -9,25 +9,64 @@ import (
 )
 
 var (
-	errDuplicated                    = errors.New("duplicated packet")
-	errShortSrtpMasterKey            = errors.New("SRTP master key is not long enough")
-	errShortSrtpMasterSalt           = errors.New("SRTP master salt is not long enough")
-	errNoSuchSRTPProfile             = errors.New("no such SRTP Profile")
-	errNonZeroKDRNotSupported        = errors.New("indexOverKdr > 0 is not supported yet")
-	errExporterWrongLabel            = errors.New("exporter called with wrong label")
-	errNoConfig                      = errors.New("no config provided")
-	errNoConn                        = errors.New("no conn provided")
-	errFailedToVerifyAuthTag         = errors.New("failed to verify auth tag")
-	errTooShortRTCP                  = errors.New("packet is too short to be rtcp packet")
-	errPayloadDiffers                = errors.New("payload differs")
-	errStartedChannelUsedIncorrectly = errors.New("started channel used incorrectly, should only be closed")
-	errBadIVLength                   = errors.New("bad iv length in xorBytesCTR")
-	errExceededMaxPackets            = errors.New("exceeded the maximum number of packets")
+	// ErrFailedToVerifyAuthTag is returned when decryption fails due to invalid authentication tag
+	ErrFailedToVerifyAuthTag = errors.New("failed to verify auth tag")
+	// ErrDuplicated is returned when a packet is rejected by the replay detector
+	ErrDuplicated = errors.New("duplicated packet")
+	// ErrTooShortRTP is returned when a packet is too short to hold the RTP header and authentication tag
+	ErrTooShortRTP = errors.New("packet is too short to be rtp packet")
+	// ErrTooShortRTCP is returned when a packet is too short to hold the RTCP header and SRTCP trailer
+	ErrTooShortRTCP = errors.New("packet is too short to be rtcp packet")
+	// ErrMKINotFound is returned when a packet carries an MKI that no master key is registered for
+	ErrMKINotFound = errors.New("MKI not found")
+	// ErrKeyLifetimeExceeded is returned when the master key used for encryption has protected its maximum number of packets
+	ErrKeyLifetimeExceeded = errors.New("master key lifetime exceeded")
+	// ErrKnownAnswerTest is returned by RunKnownAnswerTests when a result does not match its known answer
+	ErrKnownAnswerTest = errors.New("known answer test failed")
+)
+
+var (
+	errShortSrtpMasterKey                    = errors.New("SRTP master key is not long enough")
+	errShortSrtpMasterSalt                   = errors.New("SRTP master salt is not long enough")
+	errNoSuchSRTPProfile                     = errors.New("no such SRTP Profile")
+	errInvalidKDR                            = errors.New("key derivation rate must be between 2^0 and 2^24")
+	errHeaderExtensionEncryptionNotSupported = errors.New("header extension encryption is not supported by the SRTP profile")
+	errInvalidHeaderExtensionID              = errors.New("invalid header extension ID")
+	errInvalidRTPHeaderExtension             = errors.New("invalid RTP header extension")
+	errInvalidRTPPadding                     = errors.New("invalid RTP padding")
+	errInvalidRTXPacket                      = errors.New("invalid RTX packet")
+	errInvalidRTPVersion                     = errors.New("invalid RTP version")
+	errInvalidRTCPLength                     = errors.New("RTCP length exceeds the packet")
+	errInvalidOracleIndex                    = errors.New("invalid SRTP index from the sequence oracle")
+	errCryptexWithEncryptedHeaderExtensions  = errors.New("cryptex can not be combined with header extension encryption")
+	errExporterWrongLabel                    = errors.New("exporter called with wrong label")
+	errShortKeyingMaterial                   = errors.New("exported keying material is not long enough")
+	errNoConfig                              = errors.New("no config provided")
+	errNoConn                                = errors.New("no conn provided")
+	errNotPacketConn                         = errors.New("underlying conn is not a net.PacketConn")
+	errPayloadDiffers                        = errors.New("payload differs")
+	errStartedChannelUsedIncorrectly         = errors.New("started channel used incorrectly, should only be closed")
+	errBadIVLength                           = errors.New("bad iv length in xorBytesCTR")
+	errExceededMaxPackets                    = errors.New("exceeded the maximum number of packets")
+	errContextClosed                         = errors.New("context is closed")
+	errMKIAlreadyInUse                       = errors.New("MKI already in use")
+	errMKIIsNotEnabled                       = errors.New("MKI is not enabled")
+	errInvalidMKILength                      = errors.New("invalid MKI length")
+	errInvalidCryptoAttribute                = errors.New("invalid SDES crypto attribute")
+	errInvalidContextState                   = errors.New("invalid context state")
+	errContextAlreadyInUse                   = errors.New("context is already in use")
+	errInvalidEKTKey                         = errors.New("invalid EKT key")
+	errInvalidEKTField                       = errors.New("invalid EKT field")
+	errEKTWithMKI                            = errors.New("EKT can not be combined with MKI")
 
 	errStreamNotInited     = errors.New("stream has not been inited, unable to close")
 	errStreamAlreadyClosed = errors.New("stream is already closed")
+	errSSRCInUse           = errors.New("SSRC is already in use in the session")
 	errStreamAlreadyInited = errors.New("stream is already inited")
 	errFailedTypeAssertion = errors.New("failed to cast child")
+	errKeysNotSet          = errors.New("session keys have not been set")
+	errKeysAlreadySet      = errors.New("session keys are already set")
+	errSessionClosed       = errors.New("session is closed")
 )
 
 type duplicatedError struct {
@@ -37,9 +76,9 @@ type duplicatedError struct {
 }
 
 func (e *duplicatedError) Error() string {
-	return fmt.Sprintf("%s ssrc=%d index=%d: %v", e.Proto, e.SSRC, e.Index, errDuplicated)
+	return fmt.Sprintf("%s ssrc=%d index=%d: %v", e.Proto, e.SSRC, e.Index, ErrDuplicated)
 }
 
 func (e *duplicatedError) Unwrap() error {
-	return errDuplicated
+	return ErrDuplicated
 }
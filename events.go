@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+// PacketInfo describes the packet an event is reported for.
+type PacketInfo struct {
+	// RTCP is set for SRTCP packets.
+	RTCP bool
+	SSRC uint32
+
+	// Index is the SRTP packet index (2^16 * ROC + SEQ) or the SRTCP index.
+	Index uint64
+}
+
+// EventHandler is notified by a Context of anomalies while processing
+// packets, so that they can be logged or correlated with a peer. See the
+// Events option.
+// Handlers are called synchronously from the encrypting or decrypting
+// goroutine. They must not use the Context that calls them: sessions hold a
+// lock on it meanwhile. This also applies to the MasterKeyExpiry callback.
+type EventHandler interface {
+	// OnAuthFailure is called when a received packet fails to authenticate.
+	OnAuthFailure(p PacketInfo, err error)
+
+	// OnReplay is called when a received packet is rejected by the replay
+	// detector.
+	OnReplay(p PacketInfo)
+
+	// OnROCChange is called when the rollover counter of an SRTP stream is
+	// incremented.
+	OnROCChange(ssrc, roc uint32)
+
+	// OnUnknownSSRC is called for a received packet of an SSRC the Context
+	// has no state for, before the packet is authenticated.
+	OnUnknownSSRC(p PacketInfo)
+}
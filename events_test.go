@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEventHandler struct {
+	events []string
+}
+
+func (h *recordingEventHandler) OnAuthFailure(p PacketInfo, err error) {
+	h.events = append(h.events, fmt.Sprintf("auth %v %d %d %v", p.RTCP, p.SSRC, p.Index, err != nil))
+}
+
+func (h *recordingEventHandler) OnReplay(p PacketInfo) {
+	h.events = append(h.events, fmt.Sprintf("replay %v %d %d", p.RTCP, p.SSRC, p.Index))
+}
+
+func (h *recordingEventHandler) OnROCChange(ssrc, roc uint32) {
+	h.events = append(h.events, fmt.Sprintf("roc %d %d", ssrc, roc))
+}
+
+func (h *recordingEventHandler) OnUnknownSSRC(p PacketInfo) {
+	h.events = append(h.events, fmt.Sprintf("unknown %v %d %d", p.RTCP, p.SSRC, p.Index))
+}
+
+func TestContextEvents(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 5000
+
+	encryptEvents, decryptEvents := &recordingEventHandler{}, &recordingEventHandler{}
+	encryptContext, err := buildTestContext(profileCTR, Events(encryptEvents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, Events(decryptEvents), SRTPReplayProtection(64), SRTCPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, seq := range []uint16{65535, 0} {
+		raw, errMarshal := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: ssrc, SequenceNumber: seq}, Payload: rtpTestCaseDecrypted()}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(tampered)-1] ^= 0x01
+		_, err = decryptContext.DecryptRTP(nil, tampered, nil)
+		assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+		_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err)
+		_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.ErrorIs(err, ErrDuplicated)
+	}
+
+	encrypted, err := encryptContext.EncryptRTCP(nil, []byte{0x80, 0xc8, 0x00, 0x01, 0x00, 0x00, 0x13, 0x88}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+	assert.NoError(err)
+	_, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+	assert.ErrorIs(err, ErrDuplicated)
+
+	assert.Equal([]string{"roc 5000 1"}, encryptEvents.events)
+	assert.Equal([]string{
+		"unknown false 5000 65535",
+		"auth false 5000 65535 true",
+		"replay false 5000 65535",
+		"auth false 5000 65536 true",
+		"roc 5000 1",
+		"replay false 5000 65536",
+		"unknown true 5000 1",
+		"replay true 5000 1",
+	}, decryptEvents.events)
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+const (
+	headerExtensionProfileOneByte = 0xBEDE
+	headerExtensionProfileTwoByte = 0x1000
+)
+
+// headerExtensionCipher encrypts the data of selected RTP header extension
+// elements as specified in RFC 6904.
+// Only the one-byte and two-byte header extension formats of RFC 8285 are
+// supported, other extension profiles are left untouched.
+type headerExtensionCipher struct {
+	ids         [256]bool
+	block       cipher.Block
+	sessionSalt []byte
+
+	// Scratch space reused for each packet
+	ctr       ctrBuffers
+	keystream []byte
+}
+
+func newHeaderExtensionCipher(ids []uint8, masterKey, masterSalt []byte, indexOverKdr uint64) (*headerExtensionCipher, error) {
+	h := &headerExtensionCipher{}
+	for _, id := range ids {
+		h.ids[id] = true
+	}
+
+	sessionKey, err := aesCmKeyDerivation(labelSRTPHeaderEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
+	if err != nil {
+		return nil, err
+	} else if h.block, err = aes.NewCipher(sessionKey); err != nil {
+		return nil, err
+	}
+	zeroBytes(sessionKey)
+
+	if h.sessionSalt, err = aesCmKeyDerivation(labelSRTPHeaderSalt, masterKey, masterSalt, indexOverKdr, len(masterSalt)); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *headerExtensionCipher) zero() {
+	zeroBytes(h.sessionSalt)
+	zeroBytes(h.keystream)
+	h.block = nil
+}
+
+func (h *headerExtensionCipher) clone() *headerExtensionCipher {
+	return &headerExtensionCipher{
+		ids:         h.ids,
+		block:       h.block,
+		sessionSalt: append([]byte{}, h.sessionSalt...),
+	}
+}
+
+// xorHeaderExtensions encrypts or decrypts the header extension of the
+// marshaled RTP header in buf. The keystream is generated the same way as for
+// the payload, using the header encryption key and salt, and is aligned to
+// the first octet after the extension length field. Only the data octets of
+// the selected elements are xored, IDs, lengths and padding stay in the clear.
+//
+// https://tools.ietf.org/html/rfc6904#section-4
+func (h *headerExtensionCipher) xorHeaderExtensions(buf []byte, ssrc uint32, sequenceNumber uint16, roc uint32) error {
+	if len(buf) < 12 || buf[0]&0x10 == 0 {
+		return nil
+	}
+
+	offset := 12 + 4*int(buf[0]&0x0f)
+	if len(buf) < offset+4 {
+		return nil
+	}
+
+	profile := binary.BigEndian.Uint16(buf[offset:])
+	extensionLen := 4 * int(binary.BigEndian.Uint16(buf[offset+2:]))
+	offset += 4
+	if len(buf) < offset+extensionLen {
+		extensionLen = len(buf) - offset
+	}
+	elements := buf[offset : offset+extensionLen]
+
+	var oneByte bool
+	switch {
+	case profile == headerExtensionProfileOneByte:
+		oneByte = true
+	case profile&0xFFF0 == headerExtensionProfileTwoByte:
+	default:
+		return nil
+	}
+
+	h.keystream = growBufferSize(h.keystream, len(elements))
+	keystream := h.keystream
+	zeroBytes(keystream)
+	counter := generateCounter(sequenceNumber, roc, ssrc, h.sessionSalt)
+	if err := h.ctr.xorBytesCTR(h.block, counter[:], keystream, keystream); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(elements); {
+		if elements[i] == 0x00 { // padding
+			i++
+			continue
+		}
+
+		var id uint8
+		var dataLen int
+		if oneByte {
+			id = elements[i] >> 4
+			if id == 15 { // reserved, stop processing
+				break
+			}
+			dataLen = int(elements[i]&0x0f) + 1
+			i++
+		} else {
+			if i+1 >= len(elements) {
+				break
+			}
+			id = elements[i]
+			dataLen = int(elements[i+1])
+			i += 2
+		}
+
+		end := i + dataLen
+		if end > len(elements) {
+			end = len(elements)
+		}
+		if h.ids[id] {
+			for j := i; j < end; j++ {
+				elements[j] ^= keystream[j]
+			}
+		}
+		i = end
+	}
+
+	return nil
+}
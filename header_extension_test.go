@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildHeaderExtensionPacket returns an RTP packet with two header extensions
+// of extensionProfile, or none if it is 0, and the given CSRCs.
+func buildHeaderExtensionPacket(t *testing.T, extensionProfile uint16, csrc ...uint32) []byte {
+	t.Helper()
+
+	pkt := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			SSRC:           0x11223344,
+			SequenceNumber: 5000,
+			CSRC:           csrc,
+		},
+		Payload: rtpTestCaseDecrypted(),
+	}
+	if extensionProfile != 0 {
+		pkt.Header.Extension = true
+		pkt.Header.ExtensionProfile = extensionProfile
+		if err := pkt.Header.SetExtension(1, []byte{0x10, 0x11, 0x12}); err != nil {
+			t.Fatal(err)
+		}
+		if err := pkt.Header.SetExtension(2, []byte{0x20, 0x21, 0x22, 0x23, 0x24}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func testRTPHeaderExtensionAuthenticated(t *testing.T, profile ProtectionProfile, extensionProfile uint16) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := buildHeaderExtensionPacket(t, extensionProfile)
+	header := &rtp.Header{}
+	headerLen, err := header.Unmarshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Header extensions are sent in the clear unless negotiated otherwise.
+	assert.Equal(raw[:headerLen], encrypted[:headerLen])
+
+	// But they are covered by the authentication tag.
+	tampered := append([]byte{}, encrypted...)
+	tampered[headerLen-1] ^= 0xff
+	if _, err = decryptContext.DecryptRTP(nil, tampered, nil); !errors.Is(err, ErrFailedToVerifyAuthTag) {
+		t.Fatalf("Expected error '%v', got '%v'", ErrFailedToVerifyAuthTag, err)
+	}
+
+	decryptedHeader := &rtp.Header{}
+	decrypted, err := decryptContext.DecryptRTP(nil, encrypted, decryptedHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(raw, decrypted)
+	assert.Equal([]byte{0x10, 0x11, 0x12}, decryptedHeader.GetExtension(1))
+	assert.Equal([]byte{0x20, 0x21, 0x22, 0x23, 0x24}, decryptedHeader.GetExtension(2))
+}
+
+func TestRTPHeaderExtensionAuthenticated(t *testing.T) {
+	for name, extensionProfile := range map[string]uint16{
+		"OneByte": headerExtensionProfileOneByte,
+		"TwoByte": headerExtensionProfileTwoByte,
+	} {
+		extensionProfile := extensionProfile
+		t.Run(name, func(t *testing.T) {
+			t.Run("CTR", func(t *testing.T) { testRTPHeaderExtensionAuthenticated(t, profileCTR, extensionProfile) })
+			t.Run("GCM", func(t *testing.T) { testRTPHeaderExtensionAuthenticated(t, profileGCM, extensionProfile) })
+		})
+	}
+}
+
+func TestRTPEncryptedHeaderExtensions(t *testing.T) {
+	for name, extensionProfile := range map[string]uint16{
+		"OneByte": headerExtensionProfileOneByte,
+		"TwoByte": headerExtensionProfileTwoByte,
+	} {
+		extensionProfile := extensionProfile
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			encryptContext, err := buildTestContext(profileCTR, SRTPEncryptedHeaderExtensions(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptContext, err := buildTestContext(profileCTR, SRTPEncryptedHeaderExtensions(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plainContext, err := buildTestContext(profileCTR)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw := buildHeaderExtensionPacket(t, extensionProfile)
+			encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Only the data of extension 2 is encrypted.
+			encryptedHeader := &rtp.Header{}
+			if _, err = encryptedHeader.Unmarshal(encrypted); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal([]byte{0x10, 0x11, 0x12}, encryptedHeader.GetExtension(1))
+			assert.Len(encryptedHeader.GetExtension(2), 5)
+			assert.NotEqual([]byte{0x20, 0x21, 0x22, 0x23, 0x24}, encryptedHeader.GetExtension(2))
+
+			decryptedHeader := &rtp.Header{}
+			decrypted, err := decryptContext.DecryptRTP(nil, encrypted, decryptedHeader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(raw, decrypted)
+			assert.Equal([]byte{0x10, 0x11, 0x12}, decryptedHeader.GetExtension(1))
+			assert.Equal([]byte{0x20, 0x21, 0x22, 0x23, 0x24}, decryptedHeader.GetExtension(2))
+
+			// A receiver which has not negotiated header extension encryption
+			// still authenticates the packet, but sees the encrypted data.
+			decrypted, err = plainContext.DecryptRTP(nil, encrypted, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.False(bytes.Equal(raw, decrypted))
+			assert.Equal(raw[len(raw)-len(rtpTestCaseDecrypted()):], decrypted[len(decrypted)-len(rtpTestCaseDecrypted()):])
+		})
+	}
+}
+
+func TestRTPEncryptedHeaderExtensionsGCM(t *testing.T) {
+	if _, err := buildTestContext(profileGCM, SRTPEncryptedHeaderExtensions(1)); !errors.Is(err, errHeaderExtensionEncryptionNotSupported) {
+		t.Errorf("Expected error '%v', got '%v'", errHeaderExtensionEncryptionNotSupported, err)
+	}
+}
+
+func TestRTPEncryptedHeaderExtensionsInvalidID(t *testing.T) {
+	if _, err := buildTestContext(profileCTR, SRTPEncryptedHeaderExtensions(1, 0)); !errors.Is(err, errInvalidHeaderExtensionID) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidHeaderExtensionID, err)
+	}
+}
+
+func TestRTPEncryptedHeaderExtensionsKeystream(t *testing.T) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profileCTR, SRTPEncryptedHeaderExtensions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, SRTPEncryptedHeaderExtensions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keystream depends on the packet index, so the same extension data
+	// is encrypted differently in each packet, including after a rollover.
+	seen := map[string]bool{}
+	for _, seq := range []uint16{65534, 65535, 0, 1} {
+		pkt := &rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 0x11223344, SequenceNumber: seq}, Payload: rtpTestCaseDecrypted()}
+		if err = pkt.Header.SetExtension(2, []byte{0x20, 0x21, 0x22, 0x23, 0x24}); err != nil {
+			t.Fatal(err)
+		}
+		raw, errMarshal := pkt.Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+
+		encryptedHeader := &rtp.Header{}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, encryptedHeader)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		if _, err = encryptedHeader.Unmarshal(encrypted); err != nil {
+			t.Fatal(err)
+		}
+		ext := string(encryptedHeader.GetExtension(2))
+		assert.False(seen[ext], "seq=%d reuses the keystream", seq)
+		seen[ext] = true
+
+		decrypted, errDec := decryptContext.DecryptRTP(nil, encrypted, nil)
+		if errDec != nil {
+			t.Fatal(errDec)
+		}
+		assert.Equal(raw, decrypted)
+	}
+}
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package inspect dissects SRTP and SRTCP packets and compares keys, in the
+// manner of srtpdump, to debug authentication failures with other
+// implementations. Nothing in this package modifies the state of a
+// srtp.Context.
+package inspect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/srtp/v3"
+	"github.com/pion/srtp/v3/internal/sessionkeys"
+)
+
+const srtcpIndexSize = 4
+
+var (
+	errPacketTooShort     = errors.New("packet too short")
+	errUnsupportedProfile = errors.New("unsupported protection profile")
+)
+
+// authTagLens returns the lengths of the SRTP and SRTCP authentication tags of
+// profile, and of the AEAD authentication tag at the end of the ciphertext.
+func authTagLens(profile srtp.ProtectionProfile) (rtpLen, rtcpLen, aeadLen int, err error) {
+	switch profile {
+	case srtp.ProtectionProfileAes128CmHmacSha1_80, srtp.ProtectionProfileAes256CmHmacSha1_80,
+		srtp.ProtectionProfileNullHmacSha1_80:
+		return 10, 10, 0, nil
+	case srtp.ProtectionProfileAes128CmHmacSha1_32, srtp.ProtectionProfileAes256CmHmacSha1_32,
+		srtp.ProtectionProfileNullHmacSha1_32:
+		return 4, 10, 0, nil
+	case srtp.ProtectionProfileAeadAes128Gcm, srtp.ProtectionProfileAeadAes256Gcm:
+		return 0, 0, 16, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: %#v", errUnsupportedProfile, profile)
+	}
+}
+
+// RTPPacket is a dissected SRTP packet. The slices point into the packet.
+type RTPPacket struct {
+	Header    rtp.Header
+	HeaderLen int
+
+	// SRTP packet index (2^16 * ROC + SEQ), as estimated by the receiving
+	// Context, or with a ROC of 0 if there is none.
+	Index uint64
+
+	// Encrypted payload, including the AEAD authentication tag
+	Payload []byte
+	MKI     []byte
+	AuthTag []byte
+}
+
+// RTP dissects an SRTP packet protected with profile and an MKI of mkiLen
+// bytes, 0 if MKI is disabled. If c is not nil, the packet index is estimated
+// from the state of c, see srtp.Context.EstimateIndex.
+func RTP(packet []byte, profile srtp.ProtectionProfile, mkiLen int, c *srtp.Context) (*RTPPacket, error) {
+	authTagLen, _, aeadAuthTagLen, err := authTagLens(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RTPPacket{}
+	if p.HeaderLen, err = p.Header.Unmarshal(packet); err != nil {
+		return nil, err
+	}
+	end := len(packet) - authTagLen - mkiLen
+	if end-p.HeaderLen < aeadAuthTagLen {
+		return nil, fmt.Errorf("%w: %d bytes", errPacketTooShort, len(packet))
+	}
+	p.Payload = packet[p.HeaderLen:end]
+	p.MKI = packet[end : end+mkiLen]
+	p.AuthTag = packet[end+mkiLen:]
+	if aeadAuthTagLen != 0 {
+		p.AuthTag = p.Payload[len(p.Payload)-aeadAuthTagLen:]
+	}
+
+	p.Index = uint64(p.Header.SequenceNumber)
+	if c != nil {
+		if p.Index, err = c.EstimateIndex(p.Header.SSRC, p.Header.SequenceNumber); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// String returns a multi-line description of the packet.
+func (p *RTPPacket) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SRTP packet\n")
+	fmt.Fprintf(&b, "\tVersion: %d\n", p.Header.Version)
+	fmt.Fprintf(&b, "\tPadding: %t\n", p.Header.Padding)
+	fmt.Fprintf(&b, "\tExtension: %t\n", p.Header.Extension)
+	fmt.Fprintf(&b, "\tMarker: %t\n", p.Header.Marker)
+	fmt.Fprintf(&b, "\tPayload Type: %d\n", p.Header.PayloadType)
+	fmt.Fprintf(&b, "\tSequence Number: %d\n", p.Header.SequenceNumber)
+	fmt.Fprintf(&b, "\tTimestamp: %d\n", p.Header.Timestamp)
+	fmt.Fprintf(&b, "\tSSRC: %#08x\n", p.Header.SSRC)
+	for _, csrc := range p.Header.CSRC {
+		fmt.Fprintf(&b, "\tCSRC: %#08x\n", csrc)
+	}
+	if p.Header.Extension {
+		fmt.Fprintf(&b, "\tExtension Profile: %#04x\n", p.Header.ExtensionProfile)
+		for _, id := range p.Header.GetExtensionIDs() {
+			fmt.Fprintf(&b, "\tExtension %d: % x\n", id, p.Header.GetExtension(id))
+		}
+	}
+	fmt.Fprintf(&b, "\tHeader Length: %d\n", p.HeaderLen)
+	fmt.Fprintf(&b, "\tIndex: %d (ROC %d)\n", p.Index, p.Index>>16)
+	fmt.Fprintf(&b, "\tEncrypted Payload: %d bytes\n", len(p.Payload))
+	if len(p.MKI) != 0 {
+		fmt.Fprintf(&b, "\tMKI: % x\n", p.MKI)
+	}
+	fmt.Fprintf(&b, "\tAuth Tag: % x\n", p.AuthTag)
+	return b.String()
+}
+
+// RTCPPacket is a dissected SRTCP packet. The slices point into the packet.
+type RTCPPacket struct {
+	Header rtcp.Header
+
+	// SSRC of the sender of the first report
+	SSRC uint32
+
+	// E flag and SRTCP index of the ESRTCP word
+	Encrypted bool
+	Index     uint32
+
+	// Encrypted part of the compound packet following the first 8 bytes,
+	// including the AEAD authentication tag
+	Payload []byte
+	MKI     []byte
+	AuthTag []byte
+}
+
+// RTCP dissects an SRTCP packet protected with profile and an MKI of mkiLen
+// bytes, 0 if MKI is disabled.
+func RTCP(packet []byte, profile srtp.ProtectionProfile, mkiLen int) (*RTCPPacket, error) {
+	_, authTagLen, aeadAuthTagLen, err := authTagLens(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RTCPPacket{}
+	if err = p.Header.Unmarshal(packet); err != nil {
+		return nil, err
+	}
+	tailOffset := len(packet) - authTagLen - mkiLen - srtcpIndexSize
+	if tailOffset < 8+aeadAuthTagLen {
+		return nil, fmt.Errorf("%w: %d bytes", errPacketTooShort, len(packet))
+	}
+	p.SSRC = binary.BigEndian.Uint32(packet[4:])
+	esrtcp := binary.BigEndian.Uint32(packet[tailOffset:])
+	p.Encrypted = esrtcp>>31 != 0
+	p.Index = esrtcp &^ (1 << 31)
+	p.Payload = packet[8:tailOffset]
+	p.MKI = packet[tailOffset+srtcpIndexSize : tailOffset+srtcpIndexSize+mkiLen]
+	p.AuthTag = packet[tailOffset+srtcpIndexSize+mkiLen:]
+	if aeadAuthTagLen != 0 {
+		p.AuthTag = p.Payload[len(p.Payload)-aeadAuthTagLen:]
+	}
+	return p, nil
+}
+
+// String returns a multi-line description of the packet.
+func (p *RTCPPacket) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SRTCP packet\n")
+	fmt.Fprintf(&b, "\tPadding: %t\n", p.Header.Padding)
+	fmt.Fprintf(&b, "\tCount: %d\n", p.Header.Count)
+	fmt.Fprintf(&b, "\tType: %s (%d)\n", p.Header.Type, uint8(p.Header.Type))
+	fmt.Fprintf(&b, "\tLength: %d\n", p.Header.Length)
+	fmt.Fprintf(&b, "\tSSRC: %#08x\n", p.SSRC)
+	fmt.Fprintf(&b, "\tEncrypted: %t\n", p.Encrypted)
+	fmt.Fprintf(&b, "\tIndex: %d\n", p.Index)
+	fmt.Fprintf(&b, "\tEncrypted Payload: %d bytes\n", len(p.Payload))
+	if len(p.MKI) != 0 {
+		fmt.Fprintf(&b, "\tMKI: % x\n", p.MKI)
+	}
+	fmt.Fprintf(&b, "\tAuth Tag: % x\n", p.AuthTag)
+	return b.String()
+}
+
+// VerifyRTP authenticates an SRTP packet with the given master key and ROC in
+// a Context of its own, and returns the error of its decryption, nil if the
+// authentication tag is valid. The packet is not modified. opts must include
+// srtp.MasterKeyIndicator if the packet carries an MKI.
+func VerifyRTP(packet []byte, profile srtp.ProtectionProfile, masterKey, masterSalt []byte, roc uint32, opts ...srtp.ContextOption) error {
+	c, err := srtp.CreateContext(masterKey, masterSalt, profile, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	var header rtp.Header
+	if _, err = header.Unmarshal(packet); err != nil {
+		return err
+	}
+	c.SetROC(header.SSRC, roc)
+	_, err = c.DecryptRTP(nil, packet, &header)
+	return err
+}
+
+// VerifyRTCP authenticates an SRTCP packet with the given master key like
+// VerifyRTP.
+func VerifyRTCP(packet []byte, profile srtp.ProtectionProfile, masterKey, masterSalt []byte, opts ...srtp.ContextOption) error {
+	c, err := srtp.CreateContext(masterKey, masterSalt, profile, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.DecryptRTCP(nil, packet, nil)
+	return err
+}
+
+// KeyDifference is a session key which differs between two Contexts.
+type KeyDifference struct {
+	Name string
+	A, B []byte
+}
+
+// String returns the name of the key and both values in hexadecimal.
+func (d KeyDifference) String() string {
+	return fmt.Sprintf("%s: % x != % x", d.Name, d.A, d.B)
+}
+
+// DiffSessionKeys compares the session keys of the master keys used for
+// encryption by a and b for the given SRTP or SRTCP packet index, and returns
+// the ones which differ. Typically a is the receiving Context, and b one
+// created with the keys the other implementation reports using.
+func DiffSessionKeys(a, b *srtp.Context, index uint64) ([]KeyDifference, error) {
+	keysA, err := sessionkeys.Derive(a, nil, index)
+	if err != nil {
+		return nil, err
+	}
+	keysB, err := sessionkeys.Derive(b, nil, index)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []KeyDifference
+	for _, k := range []struct {
+		name string
+		a, b []byte
+	}{
+		{"SRTP encryption key", keysA.SRTPEncryptionKey, keysB.SRTPEncryptionKey},
+		{"SRTP authentication key", keysA.SRTPAuthenticationKey, keysB.SRTPAuthenticationKey},
+		{"SRTP salt", keysA.SRTPSalt, keysB.SRTPSalt},
+		{"SRTCP encryption key", keysA.SRTCPEncryptionKey, keysB.SRTCPEncryptionKey},
+		{"SRTCP authentication key", keysA.SRTCPAuthenticationKey, keysB.SRTCPAuthenticationKey},
+		{"SRTCP salt", keysA.SRTCPSalt, keysB.SRTCPSalt},
+	} {
+		if !bytes.Equal(k.a, k.b) {
+			diffs = append(diffs, KeyDifference{k.name, k.a, k.b})
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package inspect
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/srtp/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey  = bytes.Repeat([]byte{0x01}, 16)
+	testSalt = bytes.Repeat([]byte{0x02}, 14)
+
+	testRTP  = []byte{0x80, 0x60, 0x12, 0x34, 0, 0, 0, 1, 0, 0, 0x13, 0x88, 0xaa, 0xbb, 0xcc}
+	testRTCP = []byte{0x81, 0xc8, 0x00, 0x01, 0, 0, 0x13, 0x88}
+)
+
+func TestRTP(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, tc := range map[string]struct {
+		profile      srtp.ProtectionProfile
+		salt         []byte
+		mki          []byte
+		tagLen       int
+		tagInPayload bool
+	}{
+		"CTR":    {profile: srtp.ProtectionProfileAes128CmHmacSha1_80, salt: testSalt, tagLen: 10},
+		"CTRMKI": {profile: srtp.ProtectionProfileAes128CmHmacSha1_32, salt: testSalt, mki: []byte{9, 8}, tagLen: 4},
+		"GCMMKI": {profile: srtp.ProtectionProfileAeadAes128Gcm, salt: testSalt[:12], mki: []byte{7}, tagLen: 16, tagInPayload: true},
+	} {
+		tc := tc
+		t.Run(name, func(*testing.T) {
+			var opts []srtp.ContextOption
+			if tc.mki != nil {
+				opts = append(opts, srtp.MasterKeyIndicator(tc.mki))
+			}
+			sender, err := srtp.CreateContext(testKey, tc.salt, tc.profile, opts...)
+			assert.NoError(err)
+			sender.SetROC(5000, 3)
+			encrypted, err := sender.EncryptRTP(nil, testRTP, nil)
+			assert.NoError(err)
+			original := append([]byte{}, encrypted...)
+
+			p, err := RTP(encrypted, tc.profile, len(tc.mki), sender)
+			assert.NoError(err)
+			assert.Equal(uint16(0x1234), p.Header.SequenceNumber)
+			assert.Equal(uint32(5000), p.Header.SSRC)
+			assert.Equal(12, p.HeaderLen)
+			assert.Equal(uint64(3<<16|0x1234), p.Index)
+			assert.Equal(tc.mki, nilIfEmpty(p.MKI))
+			assert.Len(p.AuthTag, tc.tagLen)
+			payloadLen := 3
+			if tc.tagInPayload {
+				payloadLen += tc.tagLen
+			}
+			assert.Len(p.Payload, payloadLen)
+			assert.Contains(p.String(), "Index: 201268 (ROC 3)")
+			assert.Contains(p.String(), "SSRC: 0x00001388")
+
+			// Without a Context the ROC is taken as 0.
+			p, err = RTP(encrypted, tc.profile, len(tc.mki), nil)
+			assert.NoError(err)
+			assert.Equal(uint64(0x1234), p.Index)
+
+			assert.NoError(VerifyRTP(encrypted, tc.profile, testKey, tc.salt, 3, opts...))
+			assert.ErrorIs(VerifyRTP(encrypted, tc.profile, testKey, tc.salt, 4, opts...), srtp.ErrFailedToVerifyAuthTag)
+			assert.Equal(original, encrypted, "VerifyRTP must not modify the packet")
+
+			_, err = RTP(encrypted[:13], tc.profile, len(tc.mki), nil)
+			assert.ErrorIs(err, errPacketTooShort)
+		})
+	}
+}
+
+func TestRTCP(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []srtp.ContextOption{srtp.MasterKeyIndicator([]byte{1, 2, 3, 4})}
+	sender, err := srtp.CreateContext(testKey, testSalt, srtp.ProtectionProfileAes128CmHmacSha1_80, opts...)
+	assert.NoError(err)
+	sender.SetIndex(5000, 41)
+	encrypted, err := sender.EncryptRTCP(nil, testRTCP, nil)
+	assert.NoError(err)
+
+	p, err := RTCP(encrypted, srtp.ProtectionProfileAes128CmHmacSha1_80, 4)
+	assert.NoError(err)
+	assert.Equal(uint32(5000), p.SSRC)
+	assert.True(p.Encrypted)
+	assert.Equal(uint32(42), p.Index)
+	assert.Equal([]byte{1, 2, 3, 4}, p.MKI)
+	assert.Len(p.AuthTag, 10)
+	assert.Empty(p.Payload)
+	assert.Contains(p.String(), "Type: SR (200)")
+
+	assert.NoError(VerifyRTCP(encrypted, srtp.ProtectionProfileAes128CmHmacSha1_80, testKey, testSalt, opts...))
+	otherKey := bytes.Repeat([]byte{0x03}, 16)
+	assert.ErrorIs(VerifyRTCP(encrypted, srtp.ProtectionProfileAes128CmHmacSha1_80, otherKey, testSalt, opts...), srtp.ErrFailedToVerifyAuthTag)
+
+	_, err = RTCP(encrypted[:20], srtp.ProtectionProfileAes128CmHmacSha1_80, 4)
+	assert.ErrorIs(err, errPacketTooShort)
+}
+
+func TestDiffSessionKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := srtp.CreateContext(testKey, testSalt, srtp.ProtectionProfileAes128CmHmacSha1_80)
+	assert.NoError(err)
+	b, err := srtp.CreateContext(testKey, testSalt, srtp.ProtectionProfileAes128CmHmacSha1_32)
+	assert.NoError(err)
+	diffs, err := DiffSessionKeys(a, b, 0)
+	assert.NoError(err)
+	assert.Empty(diffs)
+
+	otherSalt := append([]byte{}, testSalt...)
+	otherSalt[13] ^= 1
+	c, err := srtp.CreateContext(testKey, otherSalt, srtp.ProtectionProfileAes128CmHmacSha1_80)
+	assert.NoError(err)
+	diffs, err = DiffSessionKeys(a, c, 0)
+	assert.NoError(err)
+	names := make([]string, len(diffs))
+	for i, d := range diffs {
+		names[i] = d.Name
+	}
+	assert.Equal([]string{
+		"SRTP encryption key", "SRTP authentication key", "SRTP salt",
+		"SRTCP encryption key", "SRTCP authentication key", "SRTCP salt",
+	}, names)
+	assert.Contains(diffs[2].String(), "SRTP salt: ")
+}
+
+func nilIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
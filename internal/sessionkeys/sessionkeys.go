@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package sessionkeys lets the inspect package derive the session keys of a
+// srtp.Context, which the srtp package does not export.
+package sessionkeys
+
+// Keys are the session keys and salts derived from a master key. The
+// authentication keys are nil for the AEAD profiles.
+type Keys struct {
+	SRTPEncryptionKey, SRTPAuthenticationKey, SRTPSalt    []byte
+	SRTCPEncryptionKey, SRTCPAuthenticationKey, SRTCPSalt []byte
+}
+
+// Derive derives the session keys of the master key of the *srtp.Context c
+// identified by mki, or of the master key used for encryption if mki is nil,
+// for the given SRTP or SRTCP packet index. It is set by the srtp package.
+var Derive func(c interface{}, mki []byte, index uint64) (*Keys, error) //nolint:gochecknoglobals
@@ -6,20 +6,16 @@ package srtp
 import (
 	"crypto/aes"
 	"encoding/binary"
-)
 
-func aesCmKeyDerivation(label byte, masterKey, masterSalt []byte, indexOverKdr int, outLen int) ([]byte, error) {
-	if indexOverKdr != 0 {
-		// 24-bit "index DIV kdr" must be xored to prf input.
-		return nil, errNonZeroKDRNotSupported
-	}
+	"github.com/pion/srtp/v3/internal/sessionkeys"
+)
 
+func aesCmKeyDerivation(label byte, masterKey, masterSalt []byte, indexOverKdr uint64, outLen int) ([]byte, error) {
 	// https://tools.ietf.org/html/rfc3711#appendix-B.3
 	// The input block for AES-CM is generated by exclusive-oring the master salt with the
 	// concatenation of the encryption key label 0x00 with (index DIV kdr),
-	// - index is 'rollover count' and DIV is 'divided by'
+	// - index is the SRTP or SRTCP packet index and DIV is 'divided by'
 
-	nMasterKey := len(masterKey)
 	nMasterSalt := len(masterSalt)
 
 	prfIn := make([]byte, 16)
@@ -27,17 +23,28 @@ func aesCmKeyDerivation(label byte, masterKey, masterSalt []byte, indexOverKdr i
 
 	prfIn[7] ^= label
 
+	// The 48-bit (index DIV kdr) follows the label
+	prfIn[8] ^= byte(indexOverKdr >> 40)
+	prfIn[9] ^= byte(indexOverKdr >> 32)
+	prfIn[10] ^= byte(indexOverKdr >> 24)
+	prfIn[11] ^= byte(indexOverKdr >> 16)
+	prfIn[12] ^= byte(indexOverKdr >> 8)
+	prfIn[13] ^= byte(indexOverKdr)
+
 	// The resulting value is then AES encrypted using the master key to get the cipher key.
 	block, err := aes.NewCipher(masterKey)
 	if err != nil {
 		return nil, err
 	}
 
-	out := make([]byte, ((outLen+nMasterKey)/nMasterKey)*nMasterKey)
+	// The output is generated one AES block at a time, so round it up to a
+	// whole number of blocks. This is independent of the master key length.
+	blockSize := block.BlockSize()
+	out := make([]byte, ((outLen+blockSize-1)/blockSize)*blockSize)
 	var i uint16
-	for n := 0; n < outLen; n += block.BlockSize() {
+	for n := 0; n < outLen; n += blockSize {
 		binary.BigEndian.PutUint16(prfIn[len(prfIn)-2:], i)
-		block.Encrypt(out[n:n+nMasterKey], prfIn)
+		block.Encrypt(out[n:n+blockSize], prfIn)
 		i++
 	}
 	return out[:outLen], nil
@@ -67,3 +74,59 @@ func generateCounter(sequenceNumber uint16, rolloverCounter uint32, ssrc uint32,
 
 	return counter
 }
+
+func init() { //nolint:gochecknoinits
+	sessionkeys.Derive = func(c interface{}, mki []byte, index uint64) (*sessionkeys.Keys, error) {
+		return c.(*Context).derivedSessionKeys(mki, index)
+	}
+}
+
+// derivedSessionKeys derives the session keys of the master key identified by
+// mki, or of the master key used for encryption if mki is nil, for the given
+// SRTP or SRTCP packet index. It is only exposed to the inspect package, to
+// compare the keys of two implementations when debugging interoperability
+// issues.
+func (c *Context) derivedSessionKeys(mki []byte, index uint64) (*sessionkeys.Keys, error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+
+	m := c.keys
+	if mki != nil {
+		var ok bool
+		if m, ok = c.mkis[string(mki)]; !ok {
+			return nil, ErrMKINotFound
+		}
+	}
+
+	var indexOverKdr uint64
+	if c.kdr != 0 {
+		indexOverKdr = index / c.kdr
+	}
+	authKeyLen, err := c.profile.authKeyLen()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &sessionkeys.Keys{}
+	for _, k := range []struct {
+		key   *[]byte
+		label byte
+		len   int
+	}{
+		{&keys.SRTPEncryptionKey, labelSRTPEncryption, len(m.masterKey)},
+		{&keys.SRTPAuthenticationKey, labelSRTPAuthenticationTag, authKeyLen},
+		{&keys.SRTPSalt, labelSRTPSalt, len(m.masterSalt)},
+		{&keys.SRTCPEncryptionKey, labelSRTCPEncryption, len(m.masterKey)},
+		{&keys.SRTCPAuthenticationKey, labelSRTCPAuthenticationTag, authKeyLen},
+		{&keys.SRTCPSalt, labelSRTCPSalt, len(m.masterSalt)},
+	} {
+		if k.len == 0 {
+			continue
+		}
+		if *k.key, err = aesCmKeyDerivation(k.label, m.masterKey, m.masterSalt, indexOverKdr, k.len); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
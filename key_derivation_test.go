@@ -43,11 +43,112 @@ func TestValidSessionKeys(t *testing.T) {
 	}
 }
 
-// This test asserts that calling aesCmKeyDerivation with a non-zero indexOverKdr fails
-// Currently this isn't supported, but the API makes sure we can add this in the future
+func TestContextDerivedSessionKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 3711 Appendix B.3, as in TestValidSessionKeys
+	masterKey := []byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39}
+	masterSalt := []byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6}
+	c, err := CreateContext(masterKey, masterSalt, ProtectionProfileAes128CmHmacSha1_80, MasterKeyIndicator([]byte{1}))
+	assert.NoError(err)
+
+	keys, err := c.derivedSessionKeys(nil, 0)
+	assert.NoError(err)
+	assert.Equal([]byte{0xC6, 0x1E, 0x7A, 0x93, 0x74, 0x4F, 0x39, 0xEE, 0x10, 0x73, 0x4A, 0xFE, 0x3F, 0xF7, 0xA0, 0x87}, keys.SRTPEncryptionKey)
+	assert.Equal([]byte{0x30, 0xCB, 0xBC, 0x08, 0x86, 0x3D, 0x8C, 0x85, 0xD4, 0x9D, 0xB3, 0x4A, 0x9A, 0xE1}, keys.SRTPSalt)
+	assert.Equal([]byte{
+		0xCE, 0xBE, 0x32, 0x1F, 0x6F, 0xF7, 0x71, 0x6B, 0x6F, 0xD4,
+		0xAB, 0x49, 0xAF, 0x25, 0x6A, 0x15, 0x6D, 0x38, 0xBA, 0xA4,
+	}, keys.SRTPAuthenticationKey)
+	assert.Len(keys.SRTCPEncryptionKey, 16)
+	assert.Len(keys.SRTCPSalt, 14)
+	assert.Len(keys.SRTCPAuthenticationKey, 20)
+
+	byMKI, err := c.derivedSessionKeys([]byte{1}, 0)
+	assert.NoError(err)
+	assert.Equal(keys, byMKI)
+	_, err = c.derivedSessionKeys([]byte{2}, 0)
+	assert.ErrorIs(err, ErrMKINotFound)
+
+	// The session keys depend on the index with a key derivation rate.
+	kdr, err := CreateContext(masterKey, masterSalt, ProtectionProfileAes128CmHmacSha1_80, KeyDerivationRate(16))
+	assert.NoError(err)
+	kdrKeys, err := kdr.derivedSessionKeys(nil, 1<<16-1)
+	assert.NoError(err)
+	assert.Equal(keys, kdrKeys)
+	kdrKeys, err = kdr.derivedSessionKeys(nil, 1<<16)
+	assert.NoError(err)
+	assert.NotEqual(keys.SRTPEncryptionKey, kdrKeys.SRTPEncryptionKey)
+
+	gcm, err := CreateContext(make([]byte, 16), make([]byte, 12), ProtectionProfileAeadAes128Gcm)
+	assert.NoError(err)
+	gcmKeys, err := gcm.derivedSessionKeys(nil, 0)
+	assert.NoError(err)
+	assert.Nil(gcmKeys.SRTPAuthenticationKey)
+	assert.Nil(gcmKeys.SRTCPAuthenticationKey)
+	assert.Len(gcmKeys.SRTPSalt, 12)
+
+	assert.NoError(c.Close())
+	_, err = c.derivedSessionKeys(nil, 0)
+	assert.ErrorIs(err, errContextClosed)
+}
+
+// Test vectors from https://tools.ietf.org/html/rfc6188#section-7
+func TestValidSessionKeysAes256(t *testing.T) {
+	masterKey := []byte{
+		0xf0, 0xf0, 0x49, 0x14, 0xb5, 0x13, 0xf2, 0x76, 0x3a, 0x1b, 0x1f, 0xa1, 0x30, 0xf1, 0x0e, 0x29,
+		0x98, 0xf6, 0xf6, 0xe4, 0x3e, 0x43, 0x09, 0xd1, 0xe6, 0x22, 0xa0, 0xe3, 0x32, 0xb9, 0xf1, 0xb6,
+	}
+	masterSalt := []byte{0x3b, 0x04, 0x80, 0x3d, 0xe5, 0x1e, 0xe7, 0xc9, 0x64, 0x23, 0xab, 0x5b, 0x78, 0xd2}
+
+	expectedSessionKey := []byte{
+		0x5b, 0xa1, 0x06, 0x4e, 0x30, 0xec, 0x51, 0x61, 0x3c, 0xad, 0x92, 0x6c, 0x5a, 0x28, 0xef, 0x73,
+		0x1e, 0xc7, 0xfb, 0x39, 0x7f, 0x70, 0xa9, 0x60, 0x65, 0x3c, 0xaf, 0x06, 0x55, 0x4c, 0xd8, 0xc4,
+	}
+	expectedSessionSalt := []byte{0xfa, 0x31, 0x79, 0x16, 0x85, 0xca, 0x44, 0x4a, 0x9e, 0x07, 0xc6, 0xc6, 0x4e, 0x93}
+	expectedSessionAuthTag := []byte{
+		0xfd, 0x9c, 0x32, 0xd3, 0x9e, 0xd5, 0xfb, 0xb5, 0xa9, 0xdc,
+		0x96, 0xb3, 0x08, 0x18, 0x45, 0x4d, 0x13, 0x13, 0xdc, 0x05,
+	}
+
+	sessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, 0, len(masterKey))
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSessionKey, sessionKey, "Session Key")
+
+	sessionSalt, err := aesCmKeyDerivation(labelSRTPSalt, masterKey, masterSalt, 0, len(masterSalt))
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSessionSalt, sessionSalt, "Session Salt")
+
+	authKeyLen, err := ProtectionProfileAes256CmHmacSha1_80.authKeyLen()
+	assert.NoError(t, err)
+
+	sessionAuthTag, err := aesCmKeyDerivation(labelSRTPAuthenticationTag, masterKey, masterSalt, 0, authKeyLen)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSessionAuthTag, sessionAuthTag, "Session Auth Tag")
+}
+
+// This test asserts that (index DIV kdr) is xored into the PRF input
+// right after the label, as if it was a part of the master salt.
 func TestIndexOverKDR(t *testing.T) {
-	_, err := aesCmKeyDerivation(labelSRTPAuthenticationTag, []byte{}, []byte{}, 1, 0)
-	assert.Error(t, err)
+	masterKey := []byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39}
+	masterSalt := []byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6}
+	indexOverKdr := uint64(0x010203040506)
+
+	xoredSalt := append([]byte{}, masterSalt...)
+	for i, b := range []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06} {
+		xoredSalt[8+i] ^= b
+	}
+
+	sessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
+	assert.NoError(t, err)
+
+	expectedSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, xoredSalt, 0, len(masterKey))
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSessionKey, sessionKey)
+
+	initialSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, 0, len(masterKey))
+	assert.NoError(t, err)
+	assert.NotEqual(t, initialSessionKey, sessionKey)
 }
 
 func BenchmarkGenerateCounter(b *testing.B) {
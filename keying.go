@@ -3,6 +3,8 @@
 
 package srtp
 
+import "fmt"
+
 const labelExtractorDtlsSrtp = "EXTRACTOR-dtls_srtp"
 
 // KeyingMaterialExporter allows package SRTP to extract keying material
@@ -24,9 +26,12 @@ func (c *Config) ExtractSessionKeysFromDTLS(exporter KeyingMaterialExporter, isC
 		return err
 	}
 
-	keyingMaterial, err := exporter.ExportKeyingMaterial(labelExtractorDtlsSrtp, nil, (keyLen*2)+(saltLen*2))
+	materialLen := (keyLen * 2) + (saltLen * 2)
+	keyingMaterial, err := exporter.ExportKeyingMaterial(labelExtractorDtlsSrtp, nil, materialLen)
 	if err != nil {
 		return err
+	} else if len(keyingMaterial) < materialLen {
+		return fmt.Errorf("%w: expected(%d) actual(%d)", errShortKeyingMaterial, materialLen, len(keyingMaterial))
 	}
 
 	offset := 0
@@ -55,3 +60,29 @@ func (c *Config) ExtractSessionKeysFromDTLS(exporter KeyingMaterialExporter, isC
 	c.Keys.RemoteMasterSalt = clientWriteKey[keyLen:]
 	return nil
 }
+
+// CreateContexts creates the Contexts for the keys in the Config: local
+// protects outgoing packets and remote verifies incoming ones.
+// LocalOptions and RemoteOptions are applied to them, and the remote Context
+// has SRTP and SRTCP replay protection enabled by default like sessions do.
+func (c *Config) CreateContexts() (local, remote *Context, err error) {
+	local, err = CreateContext(c.Keys.LocalMasterKey, c.Keys.LocalMasterSalt, c.Profile, c.LocalOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remoteOpts := append(
+		[]ContextOption{
+			// Default options
+			SRTPReplayProtection(defaultSessionSRTPReplayProtectionWindow),
+			SRTCPReplayProtection(defaultSessionSRTCPReplayProtectionWindow),
+		},
+		c.RemoteOptions...,
+	)
+	remote, err = CreateContext(c.Keys.RemoteMasterKey, c.Keys.RemoteMasterSalt, c.Profile, remoteOpts...)
+	if err != nil {
+		_ = local.Close()
+		return nil, nil, err
+	}
+	return local, remote, nil
+}
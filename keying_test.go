@@ -6,12 +6,14 @@ package srtp
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"testing"
 )
 
 type mockKeyingMaterialExporter struct {
 	exported []byte
+	truncate int
 }
 
 func (m *mockKeyingMaterialExporter) ExportKeyingMaterial(label string, _ []byte, length int) ([]byte, error) {
@@ -19,7 +21,7 @@ func (m *mockKeyingMaterialExporter) ExportKeyingMaterial(label string, _ []byte
 		return nil, fmt.Errorf("%w: expected(%s) actual(%s)", errExporterWrongLabel, label, labelExtractorDtlsSrtp)
 	}
 
-	m.exported = make([]byte, length)
+	m.exported = make([]byte, length-m.truncate)
 	if _, err := rand.Read(m.exported); err != nil {
 		return nil, err
 	}
@@ -32,6 +34,12 @@ func TestExtractSessionKeysFromDTLS(t *testing.T) {
 		config *Config
 	}{
 		{&Config{Profile: ProtectionProfileAes128CmHmacSha1_80}},
+		{&Config{Profile: ProtectionProfileAes128CmHmacSha1_32}},
+		{&Config{Profile: ProtectionProfileAes256CmHmacSha1_80}},
+		{&Config{Profile: ProtectionProfileAes256CmHmacSha1_32}},
+		{&Config{Profile: ProtectionProfileNullHmacSha1_80}},
+		{&Config{Profile: ProtectionProfileAeadAes128Gcm}},
+		{&Config{Profile: ProtectionProfileAeadAes256Gcm}},
 	}
 
 	m := &mockKeyingMaterialExporter{}
@@ -70,3 +78,72 @@ func TestExtractSessionKeysFromDTLS(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractSessionKeysFromDTLSShortMaterial(t *testing.T) {
+	config := &Config{Profile: ProtectionProfileAes128CmHmacSha1_80}
+	m := &mockKeyingMaterialExporter{truncate: 1}
+
+	if err := config.ExtractSessionKeysFromDTLS(m, true); !errors.Is(err, errShortKeyingMaterial) {
+		t.Errorf("Expected error '%v', got '%v'", errShortKeyingMaterial, err)
+	}
+}
+
+func TestConfigCreateContexts(t *testing.T) {
+	m := &mockKeyingMaterialExporter{}
+	clientConfig := &Config{Profile: ProtectionProfileAes128CmHmacSha1_80}
+	serverConfig := &Config{Profile: ProtectionProfileAes128CmHmacSha1_80}
+	if err := clientConfig.ExtractSessionKeysFromDTLS(m, true); err != nil {
+		t.Fatal(err)
+	}
+	exported := m.exported
+	if err := serverConfig.ExtractSessionKeysFromDTLS(&fixedKeyingMaterialExporter{exported}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	clientLocal, clientRemote, err := clientConfig.CreateContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverLocal, serverRemote, err := serverConfig.CreateContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted := []byte{
+		0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad,
+		0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab,
+	}
+	for _, pair := range []struct{ local, remote *Context }{
+		{clientLocal, serverRemote},
+		{serverLocal, clientRemote},
+	} {
+		encrypted, err := pair.local.EncryptRTP(nil, decrypted, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := pair.remote.DecryptRTP(nil, encrypted, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, actual) {
+			t.Errorf("Decrypted packet does not match exp(%v) actual(%v)", decrypted, actual)
+		}
+
+		// Remote contexts have replay protection enabled
+		if _, err = pair.remote.DecryptRTP(nil, encrypted, nil); !errors.Is(err, ErrDuplicated) {
+			t.Errorf("Expected error '%v', got '%v'", ErrDuplicated, err)
+		}
+	}
+
+	if _, _, err = (&Config{Profile: ProtectionProfileAes128CmHmacSha1_80}).CreateContexts(); !errors.Is(err, errShortSrtpMasterKey) {
+		t.Errorf("Expected error '%v', got '%v'", errShortSrtpMasterKey, err)
+	}
+}
+
+type fixedKeyingMaterialExporter struct {
+	exported []byte
+}
+
+func (m *fixedKeyingMaterialExporter) ExportKeyingMaterial(string, []byte, int) ([]byte, error) {
+	return m.exported, nil
+}
@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"crypto/cipher"
+	"sync"
+)
+
+const maxSRTPIndex = (1 << 48) - 1
+
+type keystreamIndex struct {
+	ssrc  uint32
+	index uint64
+}
+
+// keystreamPrecomputer generates in a background goroutine the SRTP payload
+// keystreams of the packets following the last one processed for each SSRC,
+// so that the packets which arrive in order only need to be xored with them.
+// The goroutine is only started when a packet has been processed, and exits
+// once the keystreams are generated, so that an unused cipher or a Context
+// dropped without Close does not leave it running.
+// The block cipher is safe for concurrent use, and the session salt is copied
+// so that the cipher can be zeroed independently.
+type keystreamPrecomputer struct {
+	block   cipher.Block
+	salt    []byte
+	packets int
+	size    int
+
+	mu         sync.Mutex
+	keystreams map[keystreamIndex][]byte
+	latest     map[uint32]uint64
+	free       [][]byte
+
+	// Set when new packets have been processed since the goroutine last
+	// looked at latest
+	pending, running, stopped bool
+
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func newKeystreamPrecomputer(block cipher.Block, salt []byte, packets, size int) *keystreamPrecomputer {
+	return &keystreamPrecomputer{
+		block:      block,
+		salt:       append([]byte{}, salt...),
+		packets:    packets,
+		size:       size,
+		keystreams: map[keystreamIndex][]byte{},
+		latest:     map[uint32]uint64{},
+		done:       make(chan struct{}),
+	}
+}
+
+// take returns the precomputed keystream of a packet, or nil if it is not
+// available or shorter than n. The keystream must be given back with release.
+func (k *keystreamPrecomputer) take(ssrc uint32, index uint64, n int) []byte {
+	id := keystreamIndex{ssrc, index}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keystream, ok := k.keystreams[id]
+	if !ok || len(keystream) < n {
+		return nil
+	}
+	delete(k.keystreams, id)
+	return keystream
+}
+
+func (k *keystreamPrecomputer) release(keystream []byte) {
+	k.mu.Lock()
+	k.free = append(k.free, keystream)
+	k.mu.Unlock()
+}
+
+// processed records the index of a packet which has been encrypted, or
+// authenticated and decrypted, and starts the goroutine if needed to generate
+// the keystreams of the following packets.
+func (k *keystreamPrecomputer) processed(ssrc uint32, index uint64) {
+	k.mu.Lock()
+	if latest, ok := k.latest[ssrc]; !ok || index > latest {
+		k.latest[ssrc] = index
+	}
+	k.pending = true
+	start := !k.running && !k.stopped
+	if start {
+		k.running = true
+		k.wg.Add(1)
+	}
+	k.mu.Unlock()
+
+	if start {
+		go k.run()
+	}
+}
+
+func (k *keystreamPrecomputer) run() {
+	defer k.wg.Done()
+	for {
+		k.mu.Lock()
+		if !k.pending || k.stopped {
+			k.running = false
+			k.mu.Unlock()
+			return
+		}
+		k.pending = false
+		k.mu.Unlock()
+
+		k.fill()
+	}
+}
+
+func (k *keystreamPrecomputer) fill() {
+	k.mu.Lock()
+	pending := make([]keystreamIndex, 0, len(k.latest))
+	for ssrc, index := range k.latest {
+		pending = append(pending, keystreamIndex{ssrc, index})
+	}
+	// Keystreams up to the latest packet are only used by late packets,
+	// which fall back to generating their own.
+	for id, keystream := range k.keystreams {
+		if id.index <= k.latest[id.ssrc] {
+			delete(k.keystreams, id)
+			k.free = append(k.free, keystream)
+		}
+	}
+	k.mu.Unlock()
+
+	var ctr ctrBuffers
+	for _, latest := range pending {
+		for i := uint64(1); i <= uint64(k.packets) && latest.index+i <= maxSRTPIndex; i++ {
+			select {
+			case <-k.done:
+				return
+			default:
+			}
+
+			id := keystreamIndex{latest.ssrc, latest.index + i}
+			k.mu.Lock()
+			_, ok := k.keystreams[id]
+			var keystream []byte
+			if n := len(k.free); !ok && n != 0 {
+				keystream, k.free = k.free[n-1], k.free[:n-1]
+			}
+			k.mu.Unlock()
+			if ok {
+				continue
+			}
+
+			if keystream == nil {
+				keystream = make([]byte, k.size)
+			}
+			zeroBytes(keystream)
+			counter := generateCounter(uint16(id.index), uint32(id.index>>16), id.ssrc, k.salt)
+			_ = ctr.xorBytesCTR(k.block, counter[:], keystream, keystream)
+
+			k.mu.Lock()
+			k.keystreams[id] = keystream
+			k.mu.Unlock()
+		}
+	}
+	zeroBytes(ctr.stream[:])
+}
+
+// stop waits for the goroutine to exit, and zeroes the keystreams.
+func (k *keystreamPrecomputer) stop() {
+	k.stopOnce.Do(func() {
+		k.mu.Lock()
+		k.stopped = true
+		k.mu.Unlock()
+		close(k.done)
+		k.wg.Wait()
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		for _, keystream := range k.keystreams {
+			zeroBytes(keystream)
+		}
+		for _, keystream := range k.free {
+			zeroBytes(keystream)
+		}
+		k.keystreams, k.free = nil, nil
+		zeroBytes(k.salt)
+	})
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/transport/v3/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystreamPrecomputation(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+	const ssrc = 5000
+
+	precomputeContext, err := buildTestContext(profileCTR, SRTPKeystreamPrecomputation(4, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, SRTPKeystreamPrecomputation(4, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystreams := precomputeContext.keys.cipher.(*srtpCipherAesCmHmacSha1).keystreams //nolint:forcetypeassert
+
+	waitForKeystream := func(index uint64) {
+		for {
+			keystreams.mu.Lock()
+			_, ok := keystreams.keystreams[keystreamIndex{ssrc, index}]
+			keystreams.mu.Unlock()
+			if ok {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// In order, out of order, across a rollover and larger than the
+	// precomputed keystreams.
+	for i, seq := range []uint16{65533, 65534, 65535, 0, 2, 1, 3, 4} {
+		payloadLen := 60
+		if i == 6 {
+			payloadLen = 150
+		}
+		raw, errMarshal := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: ssrc, SequenceNumber: seq},
+			Payload: make([]byte, payloadLen),
+		}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+
+		if i == 1 {
+			waitForKeystream(65534)
+		}
+		encrypted, errEnc := precomputeContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(errEnc)
+		expected, errEnc := plainContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(errEnc)
+		assert.Equal(expected, encrypted, "packet %d", i)
+
+		decrypted, errDec := decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(errDec)
+		assert.Equal(raw, decrypted, "packet %d", i)
+	}
+
+	// The keystreams are zeroed when the Context is closed.
+	waitForKeystream(1<<16 | 5)
+	assert.NoError(precomputeContext.Close())
+	assert.NoError(decryptContext.Close())
+	assert.Nil(keystreams.keystreams)
+}
+
+func TestKeystreamPrecomputationGoroutines(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	// No goroutine is left running by a Context which is not closed.
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+	const ssrc = 5000
+
+	// The session keys are derived again every 2 packets.
+	c, err := buildTestContext(profileCTR, KeyDerivationRate(1), SRTPKeystreamPrecomputation(4, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := c.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var replaced []*keystreamPrecomputer
+	for seq := uint16(0); seq < 6; seq++ {
+		raw, errMarshal := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: ssrc, SequenceNumber: seq},
+			Payload: make([]byte, 60),
+		}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		_, err = c.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		_, err = clone.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+
+		keystreams := c.srtpSSRCStates[ssrc].sessionKeys.cipher.(*srtpCipherAesCmHmacSha1).keystreams //nolint:forcetypeassert
+		if len(replaced) == 0 || replaced[len(replaced)-1] != keystreams {
+			replaced = append(replaced, keystreams)
+		}
+	}
+
+	// The precomputers of the replaced session keys are stopped.
+	assert.Len(replaced, 3)
+	for _, keystreams := range replaced[:len(replaced)-1] {
+		keystreams.mu.Lock()
+		assert.True(keystreams.stopped)
+		assert.Nil(keystreams.keystreams)
+		keystreams.mu.Unlock()
+	}
+}
+
+// BenchmarkKeystreamPrecomputation measures the cost of encrypting a packet
+// once its keystream is ready, as for a stream paced by its bitrate.
+func BenchmarkKeystreamPrecomputation(b *testing.B) {
+	for name, opts := range map[string][]ContextOption{
+		"OnDemand":    nil,
+		"Precomputed": {SRTPKeystreamPrecomputation(4, 1200)},
+	} {
+		opts := opts
+		b.Run(name, func(b *testing.B) {
+			context, err := buildTestContext(profileCTR, opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() { _ = context.Close() }()
+			keystreams := context.keys.cipher.(*srtpCipherAesCmHmacSha1).keystreams //nolint:forcetypeassert
+
+			header := &rtp.Header{Version: 2, SSRC: 5000}
+			payload := make([]byte, 1200)
+			dst := make([]byte, 1500)
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				header.SequenceNumber = uint16(i)
+				if _, err = context.encryptRTP(dst, header, payload); err != nil {
+					b.Fatal(err)
+				}
+
+				if keystreams == nil {
+					continue
+				}
+				b.StopTimer()
+				next := keystreamIndex{5000, uint64(i) + 1}
+				for ready := false; !ready; runtime.Gosched() {
+					keystreams.mu.Lock()
+					_, ready = keystreams.keystreams[next]
+					keystreams.mu.Unlock()
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@
 package srtp
 
 import (
+	"fmt"
+
 	"github.com/pion/transport/v3/replaydetector"
 )
 
@@ -16,6 +18,7 @@ func SRTPReplayProtection(windowSize uint) ContextOption { // nolint:revive
 		c.newSRTPReplayDetector = func() replaydetector.ReplayDetector {
 			return replaydetector.New(windowSize, maxROC<<16|maxSequenceNumber)
 		}
+		c.srtpReplayWindow = windowSize
 		return nil
 	}
 }
@@ -26,6 +29,7 @@ func SRTCPReplayProtection(windowSize uint) ContextOption {
 		c.newSRTCPReplayDetector = func() replaydetector.ReplayDetector {
 			return replaydetector.New(windowSize, maxSRTCPIndex)
 		}
+		c.srtcpReplayWindow = windowSize
 		return nil
 	}
 }
@@ -36,6 +40,7 @@ func SRTPNoReplayProtection() ContextOption { // nolint:revive
 		c.newSRTPReplayDetector = func() replaydetector.ReplayDetector {
 			return &nopReplayDetector{}
 		}
+		c.srtpReplayWindow = 0
 		return nil
 	}
 }
@@ -46,6 +51,7 @@ func SRTCPNoReplayProtection() ContextOption {
 		c.newSRTCPReplayDetector = func() replaydetector.ReplayDetector {
 			return &nopReplayDetector{}
 		}
+		c.srtcpReplayWindow = 0
 		return nil
 	}
 }
@@ -54,6 +60,7 @@ func SRTCPNoReplayProtection() ContextOption {
 func SRTPReplayDetectorFactory(fn func() replaydetector.ReplayDetector) ContextOption { // nolint:revive
 	return func(c *Context) error {
 		c.newSRTPReplayDetector = fn
+		c.srtpReplayWindow = 0
 		return nil
 	}
 }
@@ -62,6 +69,207 @@ func SRTPReplayDetectorFactory(fn func() replaydetector.ReplayDetector) ContextO
 func SRTCPReplayDetectorFactory(fn func() replaydetector.ReplayDetector) ContextOption {
 	return func(c *Context) error {
 		c.newSRTCPReplayDetector = fn
+		c.srtcpReplayWindow = 0
+		return nil
+	}
+}
+
+// KeyDerivationRate sets the key derivation rate to 2^n packets, so that
+// session keys are derived again from the master key each time (index DIV kdr)
+// changes. n must be between 0 and 24.
+// By default the key derivation rate is zero and session keys are only
+// derived once.
+func KeyDerivationRate(n uint) ContextOption {
+	return func(c *Context) error {
+		if n > 24 {
+			return fmt.Errorf("%w: 2^%d", errInvalidKDR, n)
+		}
+		c.kdr = 1 << n
+		return nil
+	}
+}
+
+// SRTPEncryptedHeaderExtensions enables encryption of the RTP header
+// extensions with the given IDs as specified in RFC 6904. Both one-byte and
+// two-byte header extensions (RFC 8285) are supported.
+// Both sides of the session must agree on the IDs, this is usually negotiated
+// in SDP with "urn:ietf:params:rtp-hdrext:encrypt".
+// Header extension encryption is only supported by the AES-CM profiles.
+// ID 0 is padding in both formats and is rejected.
+func SRTPEncryptedHeaderExtensions(ids ...uint8) ContextOption { // nolint:revive
+	return func(c *Context) error {
+		for _, id := range ids {
+			if id == 0 {
+				return fmt.Errorf("%w: %d", errInvalidHeaderExtensionID, id)
+			}
+		}
+		c.encryptedHeaderExtensionIDs = append([]uint8{}, ids...)
+		return nil
+	}
+}
+
+// MasterKeyLifetime limits the number of SRTP and SRTCP packets protected
+// with each master key. Once a master key has reached its lifetime, encryption
+// fails with ErrKeyLifetimeExceeded until a new master key is set with
+// UpdateMasterKey or SetSendMKI. 0 or a value above the RFC 3711 maximum of
+// 2^48 SRTP and 2^31 SRTCP packets selects that maximum, which also applies by
+// default.
+//
+// https://www.rfc-editor.org/rfc/rfc3711#section-9.2
+func MasterKeyLifetime(srtpPackets, srtcpPackets uint64) ContextOption {
+	return func(c *Context) error {
+		if srtpPackets > maxSRTPKeyLifetime {
+			srtpPackets = 0
+		}
+		if srtcpPackets > maxSRTCPKeyLifetime {
+			srtcpPackets = 0
+		}
+		c.srtpKeyLifetime, c.srtcpKeyLifetime = srtpPackets, srtcpPackets
+		return nil
+	}
+}
+
+// MasterKeyExpiry registers a function called once per master key, when it
+// can protect at most threshold more SRTP or SRTCP packets, so that new keys
+// can be negotiated before its lifetime is exceeded. f receives the MKI of the
+// master key, nil if MKI is disabled. It is called from EncryptRTP or
+// EncryptRTCP, with the same restrictions as the EventHandler methods.
+func MasterKeyExpiry(threshold uint64, f func(mki []byte)) ContextOption {
+	return func(c *Context) error {
+		c.keyExpiryThreshold, c.onKeyExpiring = threshold, f
+		return nil
+	}
+}
+
+// Events sets the handler notified of authentication failures, replays,
+// rollover counter changes and new SSRCs. With sessions, it is usually passed
+// in Config.RemoteOptions.
+func Events(h EventHandler) ContextOption {
+	return func(c *Context) error {
+		c.events = h
+		return nil
+	}
+}
+
+// SRTPPadding pads the payload of encrypted RTP packets to a multiple of
+// blockSize with RTP padding, so that the exact payload sizes are hidden.
+// Packets which already have the padding bit set are sent unchanged. 0 and 1
+// disable padding, which is the default.
+func SRTPPadding(blockSize uint8) ContextOption {
+	return func(c *Context) error {
+		c.rtpPaddingBlockSize = blockSize
+		return nil
+	}
+}
+
+// SRTPMaxROCDisorder sets how many sequence numbers an SRTP packet may be
+// behind the highest one received or sent for its SSRC before it is assumed
+// to be ahead of it instead, possibly after a rollover. Raising it up to 65535 accepts more
+// reordering, as on satellite links, at the cost of the gaps expected after
+// losses, and lowering it the opposite. 0 uses the index estimation of RFC
+// 3711 Appendix A, which is the default and splits the sequence numbers
+// evenly.
+func SRTPMaxROCDisorder(maxDisorder uint16) ContextOption {
+	return func(c *Context) error {
+		c.srtpMaxROCDisorder = maxDisorder
+		return nil
+	}
+}
+
+// SRTPKeystreamPrecomputation makes the AES-CM profiles generate the payload
+// keystreams of the next packets of each SSRC in a background goroutine,
+// which is useful for high-bitrate streams where generating the keystream
+// dominates. Keystreams are generated for the next packets ahead of the last
+// packet encrypted, or authenticated and decrypted, and for payloads of at
+// most size bytes. Packets which are out of order or larger fall back to
+// generating their keystream on demand.
+// Only SRTP payloads are covered, SRTCP, header extensions encrypted as
+// specified in RFC 6904 and cryptex packets are processed on demand. The
+// goroutines only run while keystreams are being generated, and
+// Context.Close zeroes the precomputed keystreams.
+func SRTPKeystreamPrecomputation(packets, size int) ContextOption {
+	return func(c *Context) error {
+		if packets <= 0 || size <= 0 {
+			packets, size = 0, 0
+		}
+		c.keystreamPackets, c.keystreamSize = packets, size
+		return nil
+	}
+}
+
+// SRTPPipelinedCTR makes the AES-CM profiles encrypt and decrypt the SRTP and
+// SRTCP payloads of at least minSize bytes with the CTR mode of crypto/cipher.
+// On CPUs with AES instructions, the standard library then processes several
+// blocks at once in assembly, which encrypts a video payload of 1200 bytes
+// about three times faster, at the cost of allocating a stream for each
+// packet. The HMAC-SHA1 authentication then dominates.
+// Without AES instructions, the standard library falls back to its generic
+// implementation, which is not faster than the default one of the Context.
+// Smaller payloads, for which the allocation outweighs the gain, are
+// processed as usual. A minSize of 0 disables it, the default.
+// See BenchmarkRTPPayloadSizes to choose minSize for a platform.
+func SRTPPipelinedCTR(minSize int) ContextOption { // nolint:revive
+	return func(c *Context) error {
+		if minSize < 0 {
+			minSize = 0
+		}
+		c.pipelinedCTRSize = minSize
+		return nil
+	}
+}
+
+// SRTPSequenceOracle makes the Context take the SRTP indexes of the packets
+// it encrypts and decrypts from o, rather than estimating them from their
+// sequence numbers. It is meant for interoperability testing, e.g. to replay
+// captured traffic with known indexes.
+func SRTPSequenceOracle(o SequenceOracle) ContextOption {
+	return func(c *Context) error {
+		c.sequenceOracle = o
+		return nil
+	}
+}
+
+// StrictValidation makes the Context reject received packets with field
+// combinations which can not be valid before processing them further: SRTP
+// packets with an RTP version other than 2 or with the padding bit set and an
+// empty payload, and SRTCP packets whose first RTCP length exceeds the
+// packet. Truncated packets and header extensions running over their length
+// are always rejected.
+// It is meant for endpoints exposed to arbitrary traffic, the checks are not
+// done by default as some senders write packets with a zero version.
+func StrictValidation() ContextOption {
+	return func(c *Context) error {
+		c.strictValidation = true
+		return nil
+	}
+}
+
+// SRTPCryptex enables cryptex as specified in RFC 9335: the CSRCs and all
+// header extensions of RTP packets are encrypted along with the payload. It is
+// usually negotiated in SDP with "a=cryptex".
+// Packets received without cryptex are still accepted. Only one-byte and
+// two-byte header extensions (RFC 8285) can be protected, packets with other
+// extension profiles are sent without cryptex.
+// Cryptex can not be combined with SRTPEncryptedHeaderExtensions.
+func SRTPCryptex() ContextOption { // nolint:revive
+	return func(c *Context) error {
+		c.cryptex = true
+		return nil
+	}
+}
+
+// MasterKeyIndicator enables MKI and sets the MKI of the master key passed to
+// CreateContext. Each SRTP and SRTCP packet then carries the MKI of the master
+// key used to protect it, right before the authentication tag.
+// Further master keys can be added with Context.AddCipherForMKI.
+//
+// https://tools.ietf.org/html/rfc3711#section-3.1
+func MasterKeyIndicator(mki []byte) ContextOption {
+	return func(c *Context) error {
+		if len(mki) == 0 {
+			return fmt.Errorf("%w: %d", errInvalidMKILength, len(mki))
+		}
+		c.sendMKI = append([]byte{}, mki...)
 		return nil
 	}
 }
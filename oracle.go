@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"fmt"
+	"math"
+)
+
+// SequenceOracle provides the SRTP indexes of packets instead of having them
+// estimated from the sequence numbers, e.g. to replay captured traffic whose
+// indexes are known. As the keystreams and IVs only depend on the session keys
+// and the index, the packets are then protected byte for byte like by any
+// other implementation given the same indexes. See the SRTPSequenceOracle
+// option.
+type SequenceOracle interface {
+	// SRTPIndex returns the 48-bit index (2^16 * ROC + SEQ) of the packet of
+	// ssrc with sequenceNumber, or false to estimate it as usual.
+	SRTPIndex(ssrc uint32, sequenceNumber uint16) (index uint64, ok bool)
+}
+
+// rolloverCount returns the ROC of an SRTP packet of s like nextRolloverCount,
+// asking the SequenceOracle first if there is one.
+func (c *Context) rolloverCount(s *srtpSSRCState, sequenceNumber uint16) (roc uint32, diff int32, overflow bool, err error) {
+	if c.sequenceOracle == nil {
+		roc, diff, overflow = s.nextRolloverCount(sequenceNumber)
+		return roc, diff, overflow, nil
+	}
+
+	index, ok := c.sequenceOracle.SRTPIndex(s.ssrc, sequenceNumber)
+	if !ok {
+		roc, diff, overflow = s.nextRolloverCount(sequenceNumber)
+		return roc, diff, overflow, nil
+	}
+	if uint16(index) != sequenceNumber || index > maxSRTPIndex {
+		return 0, 0, false, fmt.Errorf("%w: %d for sequence number %d", errInvalidOracleIndex, index, sequenceNumber)
+	}
+
+	if s.rolloverHasProcessed {
+		switch d := int64(index) - int64(s.index); {
+		case d > math.MaxInt32:
+			return 0, 0, false, fmt.Errorf("%w: %d is too far ahead of %d", errInvalidOracleIndex, index, s.index)
+		case d < math.MinInt32:
+			diff = math.MinInt32
+		default:
+			diff = int32(d)
+		}
+	}
+	return uint32(index >> 16), diff, false, nil
+}
+
+// updateRolloverCount records the index of a packet whose ROC is roc, as
+// returned by rolloverCount. The first packet of an SSRC sets its ROC, which
+// only differs from the one of s if it has been given by the SequenceOracle.
+func (c *Context) updateRolloverCount(s *srtpSSRCState, sequenceNumber uint16, roc uint32, diff int32) bool {
+	if !s.rolloverHasProcessed {
+		s.index = uint64(roc) << 16
+	}
+	return s.updateRolloverCount(sequenceNumber, diff)
+}
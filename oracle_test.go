@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type mapSequenceOracle map[uint16]uint64
+
+func (o mapSequenceOracle) SRTPIndex(_ uint32, sequenceNumber uint16) (uint64, bool) {
+	index, ok := o[sequenceNumber]
+	return index, ok
+}
+
+func TestSequenceOracle(t *testing.T) {
+	assert := assert.New(t)
+
+	oracle := mapSequenceOracle{10: 5<<16 | 10, 11: 7<<16 | 11}
+	encryptContext, err := buildTestContext(profileCTR, SRTPSequenceOracle(oracle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, SRTPSequenceOracle(oracle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	referenceContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The packets are protected with the given indexes, even when the
+	// estimation would have guessed another ROC. Sequence numbers unknown to
+	// the oracle are estimated.
+	for _, seq := range []uint16{10, 11, 20} {
+		raw, errMarshal := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: 5000, SequenceNumber: seq},
+			Payload: rtpTestCaseDecrypted(),
+		}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		index, ok := oracle[seq]
+		if !ok {
+			index = 7<<16 | uint64(seq)
+		}
+
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(errEnc)
+		referenceContext.SetROC(5000, uint32(index>>16))
+		expected, errEnc := referenceContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(errEnc)
+		assert.Equal(expected, encrypted, "packet %d", seq)
+
+		decrypted, decryptedIndex, errDec := decryptContext.DecryptRTPWithIndex(nil, encrypted, nil)
+		assert.NoError(errDec)
+		assert.Equal(raw, decrypted)
+		assert.Equal(index, decryptedIndex)
+	}
+	roc, _ := decryptContext.ROC(5000)
+	assert.Equal(uint32(7), roc)
+
+	// An index which does not match the sequence number is rejected.
+	oracle[12] = 1<<16 | 13
+	_, err = encryptContext.encryptRTP(nil, &rtp.Header{Version: 2, SSRC: 5000, SequenceNumber: 12}, []byte{0x01})
+	assert.ErrorIs(err, errInvalidOracleIndex)
+}
@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package pcapreplay reads the UDP datagrams of packet captures, e.g. made
+// with tcpdump or Wireshark, to replay captured SRTP traffic through a
+// srtp.Context, for instance to compare it with another implementation.
+package pcapreplay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// Link types of the captures which can be read
+//
+// https://www.tcpdump.org/linktypes.html
+const (
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+)
+
+const (
+	globalHeaderLen = 24
+	recordHeaderLen = 16
+
+	// Larger records are rejected rather than allocated.
+	maxRecordLen = 256 * 1024
+)
+
+var (
+	errBadMagic          = errors.New("not a pcap capture")
+	errUnknownLinkType   = errors.New("unsupported link type")
+	errRecordTooLarge    = errors.New("pcap record is too large")
+	errTruncatedCapture  = errors.New("pcap capture is truncated")
+	errUnsupportedHeader = errors.New("unsupported packet header")
+)
+
+// Packet is a UDP datagram read from a capture.
+type Packet struct {
+	Timestamp           time.Time
+	Source, Destination netip.AddrPort
+	Payload             []byte
+}
+
+// Reader reads the UDP datagrams of a capture in the libpcap format. The
+// pcapng format is not supported, captures can be converted with editcap.
+type Reader struct {
+	r          io.Reader
+	byteOrder  binary.ByteOrder
+	nanosecond bool
+	linkType   uint32
+	header     [recordHeaderLen]byte
+}
+
+// NewReader reads the global header of the capture in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	var header [globalHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", errTruncatedCapture, err) //nolint:errorlint
+	}
+
+	reader := &Reader{r: r}
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch byteOrder.Uint32(header[0:]) {
+		case 0xa1b2c3d4:
+			reader.byteOrder = byteOrder
+		case 0xa1b23c4d:
+			reader.byteOrder, reader.nanosecond = byteOrder, true
+		}
+	}
+	if reader.byteOrder == nil {
+		return nil, fmt.Errorf("%w: magic %x", errBadMagic, header[0:4])
+	}
+
+	reader.linkType = reader.byteOrder.Uint32(header[20:]) & 0x0fffffff
+	switch reader.linkType {
+	case linkTypeEthernet, linkTypeRaw, linkTypeLinuxSLL:
+	default:
+		return nil, fmt.Errorf("%w: %d", errUnknownLinkType, reader.linkType)
+	}
+	return reader, nil
+}
+
+// Next returns the next UDP datagram of the capture, skipping the other
+// frames, and io.EOF at the end of the capture. Fragmented IP packets and
+// IPv6 packets with extension headers are skipped too.
+func (r *Reader) Next() (*Packet, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.header[:]); errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", errTruncatedCapture, err) //nolint:errorlint
+		}
+
+		capturedLen := r.byteOrder.Uint32(r.header[8:])
+		if capturedLen > maxRecordLen {
+			return nil, fmt.Errorf("%w: %d", errRecordTooLarge, capturedLen)
+		}
+		frame := make([]byte, capturedLen)
+		if _, err := io.ReadFull(r.r, frame); err != nil {
+			return nil, fmt.Errorf("%w: %v", errTruncatedCapture, err) //nolint:errorlint
+		}
+
+		p, err := r.parseFrame(frame)
+		if errors.Is(err, errUnsupportedHeader) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		subsecond := time.Duration(r.byteOrder.Uint32(r.header[4:]))
+		if !r.nanosecond {
+			subsecond *= time.Microsecond
+		}
+		p.Timestamp = time.Unix(int64(r.byteOrder.Uint32(r.header[0:])), int64(subsecond))
+		return p, nil
+	}
+}
+
+func (r *Reader) parseFrame(frame []byte) (*Packet, error) {
+	var etherType uint16
+	switch r.linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil, errUnsupportedHeader
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[12:]), frame[14:]
+		for etherType == 0x8100 && len(frame) >= 4 { // 802.1Q VLAN tag
+			etherType, frame = binary.BigEndian.Uint16(frame[2:]), frame[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return nil, errUnsupportedHeader
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[14:]), frame[16:]
+	default: // raw IP, identified by its version
+		switch {
+		case len(frame) == 0:
+			return nil, errUnsupportedHeader
+		case frame[0]>>4 == 4:
+			etherType = 0x0800
+		case frame[0]>>4 == 6:
+			etherType = 0x86dd
+		}
+	}
+
+	var src, dst netip.Addr
+	switch etherType {
+	case 0x0800:
+		if len(frame) < 20 || frame[9] != 17 {
+			return nil, errUnsupportedHeader
+		}
+		headerLen := 4 * int(frame[0]&0x0f)
+		if binary.BigEndian.Uint16(frame[6:])&0x3fff != 0 || headerLen < 20 || len(frame) < headerLen {
+			return nil, errUnsupportedHeader // fragmented or malformed
+		}
+		src, _ = netip.AddrFromSlice(frame[12:16])
+		dst, _ = netip.AddrFromSlice(frame[16:20])
+		frame = frame[headerLen:]
+	case 0x86dd:
+		if len(frame) < 40 || frame[6] != 17 {
+			return nil, errUnsupportedHeader
+		}
+		src, _ = netip.AddrFromSlice(frame[8:24])
+		dst, _ = netip.AddrFromSlice(frame[24:40])
+		frame = frame[40:]
+	default:
+		return nil, errUnsupportedHeader
+	}
+
+	if len(frame) < 8 {
+		return nil, errUnsupportedHeader
+	}
+	udpLen := int(binary.BigEndian.Uint16(frame[4:]))
+	if udpLen < 8 || udpLen > len(frame) {
+		return nil, errUnsupportedHeader // truncated by the snapshot length
+	}
+	return &Packet{
+		Source:      netip.AddrPortFrom(src, binary.BigEndian.Uint16(frame[0:])),
+		Destination: netip.AddrPortFrom(dst, binary.BigEndian.Uint16(frame[2:])),
+		Payload:     frame[8:udpLen],
+	}, nil
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package pcapreplay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/pion/srtp/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type captureWriter struct {
+	bytes.Buffer
+	byteOrder binary.ByteOrder
+}
+
+func newCaptureWriter(byteOrder binary.ByteOrder, magic uint32, linkType uint32) *captureWriter {
+	w := &captureWriter{byteOrder: byteOrder}
+	header := make([]byte, globalHeaderLen)
+	byteOrder.PutUint32(header[0:], magic)
+	byteOrder.PutUint16(header[4:], 2)
+	byteOrder.PutUint16(header[6:], 4)
+	byteOrder.PutUint32(header[16:], 65535)
+	byteOrder.PutUint32(header[20:], linkType)
+	w.Write(header)
+	return w
+}
+
+func (w *captureWriter) writeRecord(sec, subsec uint32, frame []byte) {
+	header := make([]byte, recordHeaderLen)
+	w.byteOrder.PutUint32(header[0:], sec)
+	w.byteOrder.PutUint32(header[4:], subsec)
+	w.byteOrder.PutUint32(header[8:], uint32(len(frame)))
+	w.byteOrder.PutUint32(header[12:], uint32(len(frame)))
+	w.Write(header)
+	w.Write(frame)
+}
+
+func udp(srcPort, dstPort uint16, payload []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:], srcPort)
+	binary.BigEndian.PutUint16(header[2:], dstPort)
+	binary.BigEndian.PutUint16(header[4:], uint16(8+len(payload)))
+	return append(header, payload...)
+}
+
+func ethernetIPv4UDP(payload []byte) []byte {
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+	ip := []byte{0x45, 0, 0, 0, 0, 0, 0x40, 0, 64, 17, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2}
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+8+len(payload)))
+	return append(append(frame, ip...), udp(5004, 5006, payload)...)
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	key := bytes.Repeat([]byte{0x01}, 16)
+	salt := bytes.Repeat([]byte{0x02}, 14)
+	sender, err := srtp.CreateContext(key, salt, srtp.ProtectionProfileAes128CmHmacSha1_80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtpPacket := []byte{0x80, 0x60, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0x13, 0x88, 0xaa, 0xbb}
+	rtcpPacket := []byte{0x81, 0xc8, 0x00, 0x01, 0, 0, 0x13, 0x88}
+	encryptedRTP, err := sender.EncryptRTP(nil, rtpPacket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedRTCP, err := sender.EncryptRTCP(nil, rtcpPacket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newCaptureWriter(binary.LittleEndian, 0xa1b2c3d4, linkTypeEthernet)
+	w.writeRecord(1, 500, ethernetIPv4UDP(encryptedRTP))
+	w.writeRecord(1, 600, ethernetIPv4UDP([]byte{0x00, 0x01, 0x00, 0x00})) // STUN
+	w.writeRecord(1, 700, append(make([]byte, 12), 0x08, 0x06))            // ARP
+	w.writeRecord(2, 0, ethernetIPv4UDP(encryptedRTCP))
+	w.writeRecord(2, 100, ethernetIPv4UDP(encryptedRTP)) // replayed
+
+	receiver, err := srtp.CreateContext(key, salt, srtp.ProtectionProfileAes128CmHmacSha1_80, srtp.SRTPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decrypted [][]byte
+	var errs []error
+	assert.NoError(Replay(bytes.NewReader(w.Bytes()), receiver, nil, func(p *Packet, d []byte, err error) error {
+		assert.Equal(netip.MustParseAddrPort("10.0.0.1:5004"), p.Source)
+		assert.Equal(netip.MustParseAddrPort("10.0.0.2:5006"), p.Destination)
+		decrypted, errs = append(decrypted, d), append(errs, err)
+		return nil
+	}))
+	assert.Equal([][]byte{rtpPacket, rtcpPacket, nil}, decrypted)
+	assert.NoError(errs[0])
+	assert.NoError(errs[1])
+	assert.ErrorIs(errs[2], srtp.ErrDuplicated)
+
+	// An error of fn stops the replay.
+	errStop := errors.New("stop")
+	calls := 0
+	assert.ErrorIs(Replay(bytes.NewReader(w.Bytes()), receiver, func(p *Packet) bool {
+		return !srtp.IsRTCP(p.Payload)
+	}, func(*Packet, []byte, error) error {
+		calls++
+		return errStop
+	}), errStop)
+	assert.Equal(1, calls)
+}
+
+func TestReader(t *testing.T) {
+	assert := assert.New(t)
+
+	// Raw IPv6 frames, big-endian with nanosecond timestamps
+	w := newCaptureWriter(binary.BigEndian, 0xa1b23c4d, linkTypeRaw)
+	ip := make([]byte, 40)
+	ip[0], ip[6] = 0x60, 17
+	ip[23], ip[39] = 1, 2
+	w.writeRecord(3, 42, append(ip, udp(1, 2, []byte{0x80, 0x60})...))
+	truncated := append(ip, udp(1, 2, []byte{0x80, 0x60})...)
+	w.writeRecord(4, 0, truncated[:len(truncated)-1])
+
+	r, err := NewReader(bytes.NewReader(w.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.Next()
+	assert.NoError(err)
+	assert.Equal(time.Unix(3, 42), p.Timestamp)
+	assert.Equal(netip.MustParseAddrPort("[::1]:1"), p.Source)
+	assert.Equal(netip.MustParseAddrPort("[::2]:2"), p.Destination)
+	assert.Equal([]byte{0x80, 0x60}, p.Payload)
+
+	// Datagrams truncated by the snapshot length are skipped.
+	_, err = r.Next()
+	assert.ErrorIs(err, io.EOF)
+
+	_, err = NewReader(bytes.NewReader(make([]byte, globalHeaderLen)))
+	assert.ErrorIs(err, errBadMagic)
+	_, err = NewReader(bytes.NewReader(newCaptureWriter(binary.LittleEndian, 0xa1b2c3d4, 228).Bytes()))
+	assert.ErrorIs(err, errUnknownLinkType)
+	_, err = NewReader(bytes.NewReader(nil))
+	assert.ErrorIs(err, errTruncatedCapture)
+}
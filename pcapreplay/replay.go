@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package pcapreplay
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pion/srtp/v3"
+)
+
+// Replay decrypts with c the SRTP and SRTCP packets of the capture in r which
+// are accepted by filter, e.g. the packets sent by one side of the captured
+// session, and calls fn with each decrypted packet or decryption error. The
+// other datagrams, such as STUN and DTLS, are skipped as specified in RFC
+// 7983. Replay stops at the end of the capture or at the first error returned
+// by fn, which it returns.
+// To reproduce the packet indexes of another implementation byte for byte,
+// c can be created with the srtp.SRTPSequenceOracle option.
+func Replay(r io.Reader, c *srtp.Context, filter func(*Packet) bool, fn func(p *Packet, decrypted []byte, err error) error) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		p, errNext := reader.Next()
+		if errors.Is(errNext, io.EOF) {
+			return nil
+		} else if errNext != nil {
+			return errNext
+		}
+
+		// https://tools.ietf.org/html/rfc7983#section-7
+		if len(p.Payload) == 0 || p.Payload[0] < 128 || p.Payload[0] > 191 || (filter != nil && !filter(p)) {
+			continue
+		}
+
+		var decrypted []byte
+		var errDecrypt error
+		if srtp.IsRTCP(p.Payload) {
+			decrypted, errDecrypt = c.DecryptRTCP(nil, p.Payload, nil)
+		} else {
+			decrypted, errDecrypt = c.DecryptRTP(nil, p.Payload, nil)
+		}
+		if err = fn(p, decrypted, errDecrypt); err != nil {
+			return err
+		}
+	}
+}
@@ -3,9 +3,14 @@
 
 package srtp
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
-// ProtectionProfile specifies Cipher and AuthTag details, similar to TLS cipher suite
+// ProtectionProfile specifies Cipher and AuthTag details, similar to TLS cipher suite.
+// Its values are the SRTPProtectionProfile identifiers of the DTLS use_srtp
+// extension (RFC 5764) when the profile has one, see ProtectionProfileFromDTLS.
 type ProtectionProfile uint16
 
 // Supported protection profiles
@@ -13,15 +18,26 @@ type ProtectionProfile uint16
 const (
 	ProtectionProfileAes128CmHmacSha1_80 ProtectionProfile = 0x0001
 	ProtectionProfileAes128CmHmacSha1_32 ProtectionProfile = 0x0002
-	ProtectionProfileAeadAes128Gcm       ProtectionProfile = 0x0007
-	ProtectionProfileAeadAes256Gcm       ProtectionProfile = 0x0008
+	// AES-256 counter mode is defined in RFC 6188, which does not assign
+	// DTLS-SRTP values, so these use the private use range of RFC 5764.
+	// 0x0003 and 0x0004 are reserved by IANA and are not valid profiles.
+	ProtectionProfileAes256CmHmacSha1_80 ProtectionProfile = 0xff03
+	ProtectionProfileAes256CmHmacSha1_32 ProtectionProfile = 0xff04
+
+	// The NULL profiles authenticate packets without encrypting them.
+	ProtectionProfileNullHmacSha1_80 ProtectionProfile = 0x0005
+	ProtectionProfileNullHmacSha1_32 ProtectionProfile = 0x0006
+
+	ProtectionProfileAeadAes128Gcm ProtectionProfile = 0x0007
+	ProtectionProfileAeadAes256Gcm ProtectionProfile = 0x0008
 )
 
 func (p ProtectionProfile) keyLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80, ProtectionProfileAeadAes128Gcm:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80, ProtectionProfileAeadAes128Gcm,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
 		return 16, nil
-	case ProtectionProfileAeadAes256Gcm:
+	case ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80, ProtectionProfileAeadAes256Gcm:
 		return 32, nil
 	default:
 		return 0, fmt.Errorf("%w: %#v", errNoSuchSRTPProfile, p)
@@ -30,7 +46,9 @@ func (p ProtectionProfile) keyLen() (int, error) {
 
 func (p ProtectionProfile) saltLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
 		return 14, nil
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
 		return 12, nil
@@ -41,9 +59,9 @@ func (p ProtectionProfile) saltLen() (int, error) {
 
 func (p ProtectionProfile) rtpAuthTagLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_80:
+	case ProtectionProfileAes128CmHmacSha1_80, ProtectionProfileAes256CmHmacSha1_80, ProtectionProfileNullHmacSha1_80:
 		return 10, nil
-	case ProtectionProfileAes128CmHmacSha1_32:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileNullHmacSha1_32:
 		return 4, nil
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
 		return 0, nil
@@ -54,7 +72,9 @@ func (p ProtectionProfile) rtpAuthTagLen() (int, error) {
 
 func (p ProtectionProfile) rtcpAuthTagLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
 		return 10, nil
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
 		return 0, nil
@@ -65,7 +85,9 @@ func (p ProtectionProfile) rtcpAuthTagLen() (int, error) {
 
 func (p ProtectionProfile) aeadAuthTagLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
 		return 0, nil
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
 		return 16, nil
@@ -76,7 +98,9 @@ func (p ProtectionProfile) aeadAuthTagLen() (int, error) {
 
 func (p ProtectionProfile) authKeyLen() (int, error) {
 	switch p {
-	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80:
+	case ProtectionProfileAes128CmHmacSha1_32, ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32, ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
 		return 20, nil
 	case ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
 		return 0, nil
@@ -84,3 +108,74 @@ func (p ProtectionProfile) authKeyLen() (int, error) {
 		return 0, fmt.Errorf("%w: %#v", errNoSuchSRTPProfile, p)
 	}
 }
+
+// ProtectionProfileFromDTLS returns the profile with the given
+// SRTPProtectionProfile identifier of the DTLS use_srtp extension. Reserved,
+// unassigned and unsupported identifiers are rejected.
+func ProtectionProfileFromDTLS(id uint16) (ProtectionProfile, error) {
+	switch p := ProtectionProfile(id); p {
+	case ProtectionProfileAes128CmHmacSha1_80, ProtectionProfileAes128CmHmacSha1_32,
+		ProtectionProfileNullHmacSha1_80, ProtectionProfileNullHmacSha1_32,
+		ProtectionProfileAeadAes128Gcm, ProtectionProfileAeadAes256Gcm:
+		return p, nil
+	default:
+		return 0, fmt.Errorf("%w: DTLS-SRTP ID %#04x", errNoSuchSRTPProfile, id)
+	}
+}
+
+// String returns the DTLS-SRTP name of the profile. The AES-256 counter mode
+// profiles have no DTLS-SRTP name, the SDES crypto-suite name of RFC 6188 is
+// used for them.
+func (p ProtectionProfile) String() string {
+	switch p {
+	case ProtectionProfileAes128CmHmacSha1_80:
+		return "SRTP_AES128_CM_HMAC_SHA1_80"
+	case ProtectionProfileAes128CmHmacSha1_32:
+		return "SRTP_AES128_CM_HMAC_SHA1_32"
+	case ProtectionProfileAes256CmHmacSha1_80:
+		return "AES_256_CM_HMAC_SHA1_80"
+	case ProtectionProfileAes256CmHmacSha1_32:
+		return "AES_256_CM_HMAC_SHA1_32"
+	case ProtectionProfileNullHmacSha1_80:
+		return "SRTP_NULL_HMAC_SHA1_80"
+	case ProtectionProfileNullHmacSha1_32:
+		return "SRTP_NULL_HMAC_SHA1_32"
+	case ProtectionProfileAeadAes128Gcm:
+		return "SRTP_AEAD_AES_128_GCM"
+	case ProtectionProfileAeadAes256Gcm:
+		return "SRTP_AEAD_AES_256_GCM"
+	default:
+		return fmt.Sprintf("ProtectionProfile(%#04x)", uint16(p))
+	}
+}
+
+// ParseProtectionProfile returns the profile with the given DTLS-SRTP name
+// (e.g. "SRTP_AES128_CM_HMAC_SHA1_80"), SDES crypto-suite name
+// (e.g. "AES_CM_128_HMAC_SHA1_80") or DTLS-SRTP numeric ID (e.g. "0x0001").
+func ParseProtectionProfile(s string) (ProtectionProfile, error) {
+	switch s {
+	case "SRTP_AES128_CM_HMAC_SHA1_80", "AES_CM_128_HMAC_SHA1_80":
+		return ProtectionProfileAes128CmHmacSha1_80, nil
+	case "SRTP_AES128_CM_HMAC_SHA1_32", "AES_CM_128_HMAC_SHA1_32":
+		return ProtectionProfileAes128CmHmacSha1_32, nil
+	case "AES_256_CM_HMAC_SHA1_80":
+		return ProtectionProfileAes256CmHmacSha1_80, nil
+	case "AES_256_CM_HMAC_SHA1_32":
+		return ProtectionProfileAes256CmHmacSha1_32, nil
+	case "SRTP_NULL_HMAC_SHA1_80", "NULL_HMAC_SHA1_80":
+		return ProtectionProfileNullHmacSha1_80, nil
+	case "SRTP_NULL_HMAC_SHA1_32", "NULL_HMAC_SHA1_32":
+		return ProtectionProfileNullHmacSha1_32, nil
+	case "SRTP_AEAD_AES_128_GCM", "AEAD_AES_128_GCM":
+		return ProtectionProfileAeadAes128Gcm, nil
+	case "SRTP_AEAD_AES_256_GCM", "AEAD_AES_256_GCM":
+		return ProtectionProfileAeadAes256Gcm, nil
+	}
+
+	id, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errNoSuchSRTPProfile, s)
+	}
+
+	return ProtectionProfileFromDTLS(uint16(id))
+}
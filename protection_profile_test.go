@@ -4,8 +4,10 @@
 package srtp
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,3 +20,148 @@ func TestInvalidProtectionProfile(t *testing.T) {
 	_, err = invalidProtectionProfile.saltLen()
 	assert.Error(t, err)
 }
+
+func TestProtectionProfileAuthTagLen(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		profile                                   ProtectionProfile
+		rtpAuthTagLen, rtcpAuthTagLen, aeadTagLen int
+	}{
+		"AES_CM_128_HMAC_SHA1_80": {ProtectionProfileAes128CmHmacSha1_80, 10, 10, 0},
+		"AES_CM_128_HMAC_SHA1_32": {ProtectionProfileAes128CmHmacSha1_32, 4, 10, 0},
+		"AES_256_CM_HMAC_SHA1_80": {ProtectionProfileAes256CmHmacSha1_80, 10, 10, 0},
+		"AES_256_CM_HMAC_SHA1_32": {ProtectionProfileAes256CmHmacSha1_32, 4, 10, 0},
+		"NULL_HMAC_SHA1_80":       {ProtectionProfileNullHmacSha1_80, 10, 10, 0},
+		"NULL_HMAC_SHA1_32":       {ProtectionProfileNullHmacSha1_32, 4, 10, 0},
+		"AEAD_AES_128_GCM":        {ProtectionProfileAeadAes128Gcm, 0, 0, 16},
+		"AEAD_AES_256_GCM":        {ProtectionProfileAeadAes256Gcm, 0, 0, 16},
+	} {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rtpAuthTagLen, err := testCase.profile.rtpAuthTagLen()
+			assert.NoError(err)
+			assert.Equal(testCase.rtpAuthTagLen, rtpAuthTagLen)
+			rtcpAuthTagLen, err := testCase.profile.rtcpAuthTagLen()
+			assert.NoError(err)
+			assert.Equal(testCase.rtcpAuthTagLen, rtcpAuthTagLen)
+			aeadTagLen, err := testCase.profile.aeadAuthTagLen()
+			assert.NoError(err)
+			assert.Equal(testCase.aeadTagLen, aeadTagLen)
+
+			encryptContext, err := buildTestContext(testCase.profile)
+			assert.NoError(err)
+			decryptContext, err := buildTestContext(testCase.profile)
+			assert.NoError(err)
+
+			// The trailer appended to the packets is sized by the profile
+			pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SequenceNumber: 5000}}
+			raw, err := pkt.Marshal()
+			assert.NoError(err)
+			encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+			assert.NoError(err)
+			assert.Equal(len(raw)+testCase.rtpAuthTagLen+testCase.aeadTagLen, len(encrypted))
+			decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+			assert.NoError(err)
+			assert.Equal(raw, decrypted)
+
+			rtcpRaw := []byte{0x81, 0xc8, 0x00, 0x02, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab}
+			encrypted, err = encryptContext.EncryptRTCP(nil, rtcpRaw, nil)
+			assert.NoError(err)
+			assert.Equal(len(rtcpRaw)+srtcpIndexSize+testCase.rtcpAuthTagLen+testCase.aeadTagLen, len(encrypted))
+			decrypted, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+			assert.NoError(err)
+			assert.Equal(rtcpRaw, decrypted)
+		})
+	}
+}
+
+func TestProtectionProfileAuthTagLenMismatch(t *testing.T) {
+	encryptContext, err := buildTestContext(ProtectionProfileAes128CmHmacSha1_80)
+	assert.NoError(t, err)
+	decryptContext, err := buildTestContext(ProtectionProfileAes128CmHmacSha1_32)
+	assert.NoError(t, err)
+
+	// A packet with a 80-bit tag never decrypts with a 32-bit tag profile
+	pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SequenceNumber: 5000}}
+	raw, err := pkt.Marshal()
+	assert.NoError(t, err)
+	encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+	assert.NoError(t, err)
+	_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+	assert.ErrorIs(t, err, ErrFailedToVerifyAuthTag)
+}
+
+func TestParseProtectionProfile(t *testing.T) {
+	profiles := []ProtectionProfile{
+		ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes128CmHmacSha1_32,
+		ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32,
+		ProtectionProfileNullHmacSha1_80,
+		ProtectionProfileNullHmacSha1_32,
+		ProtectionProfileAeadAes128Gcm,
+		ProtectionProfileAeadAes256Gcm,
+	}
+	for _, profile := range profiles {
+		parsed, err := ParseProtectionProfile(profile.String())
+		assert.NoError(t, err)
+		assert.Equal(t, profile, parsed)
+
+		// The AES-256 counter mode profiles have no DTLS-SRTP ID.
+		parsed, err = ParseProtectionProfile(fmt.Sprintf("%#04x", uint16(profile)))
+		if profile == ProtectionProfileAes256CmHmacSha1_80 || profile == ProtectionProfileAes256CmHmacSha1_32 {
+			assert.ErrorIs(t, err, errNoSuchSRTPProfile)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, profile, parsed)
+	}
+
+	for name, profile := range map[string]ProtectionProfile{
+		"AES_CM_128_HMAC_SHA1_80": ProtectionProfileAes128CmHmacSha1_80,
+		"AES_CM_128_HMAC_SHA1_32": ProtectionProfileAes128CmHmacSha1_32,
+		"AEAD_AES_256_GCM":        ProtectionProfileAeadAes256Gcm,
+		"7":                       ProtectionProfileAeadAes128Gcm,
+	} {
+		parsed, err := ParseProtectionProfile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, profile, parsed, name)
+	}
+
+	for _, name := range []string{"", "SRTP_AES256_CM_HMAC_SHA1_80", "0x0003", "0x0004", "0x0009", "0x10000"} {
+		_, err := ParseProtectionProfile(name)
+		assert.ErrorIs(t, err, errNoSuchSRTPProfile, name)
+	}
+
+	assert.Equal(t, "ProtectionProfile(0x0009)", ProtectionProfile(9).String())
+}
+
+func TestProtectionProfileDTLSValues(t *testing.T) {
+	// https://www.iana.org/assignments/srtp-protection/srtp-protection.xhtml
+	for id, name := range map[uint16]string{
+		0x0001: "SRTP_AES128_CM_HMAC_SHA1_80",
+		0x0002: "SRTP_AES128_CM_HMAC_SHA1_32",
+		0x0005: "SRTP_NULL_HMAC_SHA1_80",
+		0x0006: "SRTP_NULL_HMAC_SHA1_32",
+		0x0007: "SRTP_AEAD_AES_128_GCM",
+		0x0008: "SRTP_AEAD_AES_256_GCM",
+	} {
+		profile, err := ProtectionProfileFromDTLS(id)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, profile.String())
+
+		_, err = profile.keyLen()
+		assert.NoError(t, err, name)
+	}
+
+	// Reserved (0x0003 and 0x0004), unsupported and unassigned IDs, and the
+	// private use values of the AES-256 counter mode profiles
+	for _, id := range []uint16{
+		0x0000, 0x0003, 0x0004, 0x0009, 0x000a, 0x00ff,
+		uint16(ProtectionProfileAes256CmHmacSha1_80), uint16(ProtectionProfileAes256CmHmacSha1_32),
+	} {
+		_, err := ProtectionProfileFromDTLS(id)
+		assert.ErrorIs(t, err, errNoSuchSRTPProfile, "%#04x", id)
+	}
+}
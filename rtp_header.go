@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pion/rtp"
+)
+
+// rtpHeaderLen validates the RTP header at the start of buf and returns its
+// length, i.e. the offset of the payload. The length only depends on the CSRC
+// count and the extension length field, and the header extension elements of
+// RFC 8285 must fit in the extension.
+// The version is not checked, as packets written from a zero rtp.Header carry
+// version 0.
+//
+// https://tools.ietf.org/html/rfc3550#section-5.1
+func rtpHeaderLen(buf []byte) (int, error) {
+	if len(buf) < 12 {
+		return 0, fmt.Errorf("%w: %d", ErrTooShortRTP, len(buf))
+	}
+
+	n := 12 + 4*int(buf[0]&0x0f)
+	if buf[0]&0x10 == 0 {
+		if len(buf) < n {
+			return 0, fmt.Errorf("%w: %d < %d", ErrTooShortRTP, len(buf), n)
+		}
+		return n, nil
+	}
+
+	if len(buf) < n+4 {
+		return 0, fmt.Errorf("%w: %d < %d", ErrTooShortRTP, len(buf), n+4)
+	}
+	profile := binary.BigEndian.Uint16(buf[n:])
+	extensionLen := 4 * int(binary.BigEndian.Uint16(buf[n+2:]))
+	n += 4
+	if len(buf) < n+extensionLen {
+		return 0, fmt.Errorf("%w: %d < %d", ErrTooShortRTP, len(buf), n+extensionLen)
+	}
+
+	var oneByte bool
+	switch {
+	case profile == headerExtensionProfileOneByte:
+		oneByte = true
+	case profile&0xFFF0 == headerExtensionProfileTwoByte:
+	default:
+		return n + extensionLen, nil
+	}
+
+	elements := buf[n : n+extensionLen]
+	for i := 0; i < len(elements); {
+		if elements[i] == 0x00 { // padding
+			i++
+			continue
+		}
+
+		var dataLen int
+		if oneByte {
+			if elements[i]>>4 == 15 { // reserved, stop processing
+				break
+			}
+			dataLen = int(elements[i]&0x0f) + 1
+			i++
+		} else {
+			if i+1 >= len(elements) {
+				return 0, fmt.Errorf("%w: truncated element", errInvalidRTPHeaderExtension)
+			}
+			dataLen = int(elements[i+1])
+			i += 2
+		}
+
+		if i+dataLen > len(elements) {
+			return 0, fmt.Errorf("%w: element exceeds the extension by %d", errInvalidRTPHeaderExtension, i+dataLen-len(elements))
+		}
+		i += dataLen
+	}
+
+	return n + extensionLen, nil
+}
+
+// rtpPaddingLen returns the number of padding octets at the end of the
+// payload of a packet with the padding bit set, including the count octet.
+//
+// https://tools.ietf.org/html/rfc3550#section-5.1
+func rtpPaddingLen(payload []byte) (int, error) {
+	if len(payload) == 0 {
+		return 0, fmt.Errorf("%w: empty payload", errInvalidRTPPadding)
+	} else if paddingLen := int(payload[len(payload)-1]); paddingLen <= len(payload) {
+		return paddingLen, nil
+	}
+	return 0, fmt.Errorf("%w: %d octets in a payload of %d", errInvalidRTPPadding, payload[len(payload)-1], len(payload))
+}
+
+// padRTP returns header and payload with the payload padded to a multiple of
+// blockSize. header is copied rather than modified, as it belongs to the
+// caller.
+func padRTP(header *rtp.Header, payload []byte, blockSize int) (*rtp.Header, []byte) {
+	paddingLen := blockSize - len(payload)%blockSize
+	if header.Padding || paddingLen == blockSize {
+		return header, payload
+	}
+
+	padded := make([]byte, len(payload)+paddingLen)
+	copy(padded, payload)
+	padded[len(padded)-1] = byte(paddingLen)
+
+	h := *header
+	h.Padding = true
+	return &h, padded
+}
+
+// unmarshalRTPHeader unmarshals the RTP header of buf into header and returns
+// its length. The length is taken from rtpHeaderLen rather than from
+// header.Unmarshal, which stops at the first element with the reserved ID 15,
+// so that the payload always starts where the receiver expects it.
+func unmarshalRTPHeader(buf []byte, header *rtp.Header) (int, error) {
+	headerLen, err := rtpHeaderLen(buf)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = header.Unmarshal(buf); err != nil {
+		return 0, err
+	}
+	return headerLen, nil
+}
+
+// validateRTP rejects the SRTP packets which can not be valid, for
+// StrictValidation. headerLen has been validated by rtpHeaderLen.
+func (c *Context) validateRTP(ciphertext []byte, header *rtp.Header, headerLen int) error {
+	if header.Version != 2 {
+		return fmt.Errorf("%w: %d", errInvalidRTPVersion, header.Version)
+	}
+
+	authTagLen, err := c.keys.cipher.rtpAuthTagLen()
+	if err != nil {
+		return err
+	}
+	aeadAuthTagLen, err := c.keys.cipher.aeadAuthTagLen()
+	if err != nil {
+		return err
+	}
+	if header.Padding && len(ciphertext) <= headerLen+len(c.sendMKI)+authTagLen+aeadAuthTagLen {
+		return fmt.Errorf("%w: empty payload", errInvalidRTPPadding)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTPHeaderLen(t *testing.T) {
+	fixed := []byte{0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}
+	withCSRC := append([]byte{0x82}, fixed[1:]...)
+	withCSRC = append(withCSRC, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08)
+	withExtension := func(profile0, profile1 byte, elements ...byte) []byte {
+		words := byte(len(elements) / 4)
+		return append(append([]byte{0x90}, fixed[1:]...), append([]byte{profile0, profile1, 0x00, words}, elements...)...)
+	}
+
+	for name, tc := range map[string]struct {
+		buf       []byte
+		headerLen int
+		err       error
+	}{
+		"Fixed":            {buf: fixed, headerLen: 12},
+		"CSRC":             {buf: withCSRC, headerLen: 20},
+		"OneByte":          {buf: withExtension(0xBE, 0xDE, 0x11, 0xAA, 0xBB, 0x00), headerLen: 20},
+		"OneByteStop":      {buf: withExtension(0xBE, 0xDE, 0x10, 0xAA, 0xF0, 0x00), headerLen: 20},
+		"TwoByte":          {buf: withExtension(0x10, 0x00, 0x01, 0x02, 0xAA, 0xBB), headerLen: 20},
+		"Raw":              {buf: withExtension(0x12, 0x34, 0xFF, 0xFF, 0xFF, 0xFF), headerLen: 20},
+		"Short":            {buf: fixed[:11], err: ErrTooShortRTP},
+		"ShortCSRC":        {buf: withCSRC[:19], err: ErrTooShortRTP},
+		"ShortExtension":   {buf: withExtension(0xBE, 0xDE, 0x11, 0xAA, 0xBB, 0x00)[:19], err: ErrTooShortRTP},
+		"NoExtensionHdr":   {buf: append([]byte{0x90}, fixed[1:]...), err: ErrTooShortRTP},
+		"OneByteOverrun":   {buf: withExtension(0xBE, 0xDE, 0x17, 0xAA, 0xBB, 0xCC, 0x00, 0x00, 0x00, 0x00), err: errInvalidRTPHeaderExtension},
+		"TwoByteOverrun":   {buf: withExtension(0x10, 0x00, 0x01, 0x03, 0xAA, 0xBB), err: errInvalidRTPHeaderExtension},
+		"TwoByteTruncated": {buf: withExtension(0x10, 0x00, 0x01, 0x00, 0x00, 0x01), err: errInvalidRTPHeaderExtension},
+	} {
+		headerLen, err := rtpHeaderLen(tc.buf)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected error '%v', got '%v'", name, tc.err, err)
+		} else if headerLen != tc.headerLen {
+			t.Errorf("%s: expected header length %d, got %d", name, tc.headerLen, headerLen)
+		}
+	}
+}
+
+func TestRTPMalformedHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, profile := range []ProtectionProfile{profileCTR, profileGCM} {
+		encryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The elements after the reserved ID 15 are part of the header, not
+		// of the payload.
+		payload := []byte{0x01, 0x02, 0x03, 0x04}
+		raw := append([]byte{
+			0x90, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe,
+			0xBE, 0xDE, 0x00, 0x01, 0x10, 0xAA, 0xF0, 0x00,
+		}, payload...)
+		encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err)
+		assert.Equal(payload, decrypted[20:])
+
+		// An element running over the extension is rejected.
+		malformed := append([]byte{}, raw...)
+		malformed[16] = 0x13
+		_, err = encryptContext.EncryptRTP(nil, malformed, nil)
+		assert.ErrorIs(err, errInvalidRTPHeaderExtension)
+		_, err = decryptContext.DecryptRTP(nil, malformed, nil)
+		assert.ErrorIs(err, errInvalidRTPHeaderExtension)
+
+		// Truncated packets do not panic.
+		for i := range encrypted {
+			_, err = decryptContext.DecryptRTP(nil, encrypted[:i], nil)
+			assert.Error(err)
+		}
+	}
+}
+
+func TestRTPPadding(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, profile := range []ProtectionProfile{profileCTR, profileGCM} {
+		encryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The padding is encrypted, and kept in the decrypted packet.
+		raw, err := (&rtp.Packet{
+			Header:      rtp.Header{Version: 2, Padding: true, SSRC: 5000, SequenceNumber: 1},
+			Payload:     []byte{0x01, 0x02, 0x03},
+			PaddingSize: 3,
+		}).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+		assert.NoError(err)
+		assert.NotEqual(raw[len(raw)-3:], encrypted[len(raw)-3:len(raw)])
+		decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err)
+		assert.Equal(raw, decrypted)
+
+		// A padding count larger than the payload is rejected once the
+		// packet has been authenticated.
+		invalid := append([]byte{}, raw...)
+		invalid[3] = 2
+		invalid[len(invalid)-1] = 7
+		encrypted, err = encryptContext.EncryptRTP(nil, invalid, nil)
+		assert.NoError(err)
+		_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.ErrorIs(err, errInvalidRTPPadding)
+	}
+}
+
+func TestRTPPaddingOption(t *testing.T) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profileCTR, SRTPPadding(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for seq, payloadLen := range []int{6, 16, 17} {
+		header := &rtp.Header{Version: 2, SSRC: 5000, SequenceNumber: uint16(seq)}
+		payload := make([]byte, payloadLen)
+		encrypted, errEnc := encryptContext.encryptRTP(nil, header, payload)
+		assert.NoError(errEnc)
+		assert.False(header.Padding)
+		assert.Equal(12+(payloadLen+15)/16*16+10, len(encrypted))
+
+		decrypted, errDec := decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(errDec)
+		pkt := &rtp.Packet{}
+		assert.NoError(pkt.Unmarshal(decrypted))
+		assert.Equal(payload, pkt.Payload)
+		assert.Equal(payloadLen%16 != 0, pkt.Header.Padding)
+	}
+}
+
+func TestStrictValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, profile := range []ProtectionProfile{profileCTR, profileGCM} {
+		encryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		strictContext, err := buildTestContext(profile, StrictValidation())
+		if err != nil {
+			t.Fatal(err)
+		}
+		lenientContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encryptRTP := func(version uint8, padding bool, payload []byte) []byte {
+			raw, errMarshal := (&rtp.Header{Version: version, Padding: padding, SSRC: 5000, SequenceNumber: 1}).Marshal()
+			if errMarshal != nil {
+				t.Fatal(errMarshal)
+			}
+			encrypted, errEnc := encryptContext.EncryptRTP(nil, append(raw, payload...), nil)
+			if errEnc != nil {
+				t.Fatal(errEnc)
+			}
+			// Corrupt the tag so that packets reaching the ciphers fail
+			// authentication.
+			encrypted[len(encrypted)-1] ^= 0xff
+			return encrypted
+		}
+		encryptRTCP := func(length uint16) []byte {
+			raw := []byte{
+				0x81, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe,
+			}
+			encrypted, errEnc := encryptContext.EncryptRTCP(nil, raw, nil)
+			if errEnc != nil {
+				t.Fatal(errEnc)
+			}
+			binary.BigEndian.PutUint16(encrypted[2:], length)
+			encrypted[8] ^= 0xff
+			return encrypted
+		}
+
+		for name, tc := range map[string]struct {
+			encrypted []byte
+			rtcp      bool
+			err       error
+		}{
+			"Valid":          {encrypted: encryptRTP(2, false, []byte{0x01}), err: ErrFailedToVerifyAuthTag},
+			"Version":        {encrypted: encryptRTP(0, false, []byte{0x01}), err: errInvalidRTPVersion},
+			"EmptyPadding":   {encrypted: encryptRTP(2, true, nil), err: errInvalidRTPPadding},
+			"RTCPValid":      {encrypted: encryptRTCP(1), rtcp: true, err: ErrFailedToVerifyAuthTag},
+			"RTCPOverLength": {encrypted: encryptRTCP(2), rtcp: true, err: errInvalidRTCPLength},
+		} {
+			var strictErr, lenientErr error
+			if tc.rtcp {
+				_, strictErr = strictContext.DecryptRTCP(nil, tc.encrypted, nil)
+				_, lenientErr = lenientContext.DecryptRTCP(nil, tc.encrypted, nil)
+			} else {
+				_, strictErr = strictContext.DecryptRTP(nil, tc.encrypted, nil)
+				_, lenientErr = lenientContext.DecryptRTP(nil, tc.encrypted, nil)
+			}
+			assert.ErrorIs(strictErr, tc.err, "%s %s", profile, name)
+			assert.ErrorIs(lenientErr, ErrFailedToVerifyAuthTag, "%s %s", profile, name)
+		}
+	}
+}
+
+func TestDecryptRTPPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, opts := range map[string][]ContextOption{
+		"CTR":     nil,
+		"Cryptex": {SRTPCryptex()},
+	} {
+		encryptContext, err := buildTestContext(profileCTR, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decryptContext, err := buildTestContext(profileCTR, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payload := []byte{0x01, 0x02, 0x03}
+		pkt := &rtp.Packet{
+			Header: rtp.Header{
+				Version: 2, Padding: true, SSRC: 5000, CSRC: []uint32{1, 2},
+			},
+			Payload:     payload,
+			PaddingSize: 5,
+		}
+		assert.NoError(pkt.Header.SetExtension(1, []byte{0xAA, 0xBB}))
+
+		for seq := uint16(1); seq <= 2; seq++ {
+			pkt.Header.SequenceNumber = seq
+			raw, errMarshal := pkt.Marshal()
+			if errMarshal != nil {
+				t.Fatal(errMarshal)
+			}
+			encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+			assert.NoError(errEnc)
+
+			if seq == 1 {
+				// The full packet is decrypted in place, as it was sent.
+				decrypted, errDec := decryptContext.DecryptRTP(encrypted[:0], encrypted, nil)
+				assert.NoError(errDec, name)
+				assert.Equal(raw, decrypted, name)
+				assert.Equal(&encrypted[0], &decrypted[0], name)
+				continue
+			}
+
+			header := &rtp.Header{}
+			decrypted, errDec := decryptContext.DecryptRTPPayload(nil, encrypted, header)
+			assert.NoError(errDec, name)
+			assert.Equal(payload, decrypted, name)
+			assert.Equal([]uint32{1, 2}, header.CSRC, name)
+			assert.Equal([]byte{0xAA, 0xBB}, header.GetExtension(1), name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A retransmission stream delivered to the read stream of its primary SSRC
+type rtxAssociation struct {
+	primarySSRC uint32
+
+	// Original payload types by RTX payload type, nil to deliver the
+	// packets without unwrapping them.
+	apt map[uint8]uint8
+}
+
+// unwrapRTX restores in place the original packet carried by a decrypted RTX
+// packet, and returns it. It returns nil for a packet without OSN and
+// payload, which only carries padding.
+//
+// https://tools.ietf.org/html/rfc4588#section-4
+func unwrapRTX(pkt []byte, headerLen int, ssrc uint32, payloadType uint8) ([]byte, error) {
+	payloadLen := len(pkt) - headerLen
+	if pkt[0]&0x20 != 0 {
+		paddingLen, err := rtpPaddingLen(pkt[headerLen:])
+		if err != nil {
+			return nil, err
+		}
+		payloadLen -= paddingLen
+	}
+	switch {
+	case payloadLen == 0:
+		return nil, nil
+	case payloadLen < 2:
+		return nil, fmt.Errorf("%w: payload of %d octets", errInvalidRTXPacket, payloadLen)
+	}
+
+	osn := pkt[headerLen : headerLen+2]
+	pkt[1] = pkt[1]&0x80 | payloadType&0x7f
+	copy(pkt[2:4], osn)
+	binary.BigEndian.PutUint32(pkt[8:12], ssrc)
+	copy(pkt[headerLen:], pkt[headerLen+2:])
+	return pkt[:len(pkt)-2], nil
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwrapRTX(t *testing.T) {
+	header := []byte{0x80, 0xe1, 0x00, 0x01, 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x02}
+	for name, tc := range map[string]struct {
+		payload, expected []byte
+		padding           bool
+		err               error
+	}{
+		"Payload": {
+			payload:  []byte{0x12, 0x34, 0xaa, 0xbb},
+			expected: []byte{0x80, 0xe0, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x01, 0xaa, 0xbb},
+		},
+		"Padding": {
+			payload:  []byte{0x12, 0x34, 0xaa, 0x00, 0x02},
+			padding:  true,
+			expected: []byte{0xa0, 0xe0, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x01, 0xaa, 0x00, 0x02},
+		},
+		"PaddingOnly": {payload: []byte{0x00, 0x00, 0x03}, padding: true},
+		"Empty":       {},
+		"NoOSN":       {payload: []byte{0x12}, err: errInvalidRTXPacket},
+		"BadPadding":  {payload: []byte{0x12, 0x34, 0x04}, padding: true, err: errInvalidRTPPadding},
+		"OSNOnly": {
+			payload:  []byte{0x12, 0x34, 0x01},
+			padding:  true,
+			expected: []byte{0xa0, 0xe0, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0x00, 0x00, 0x00, 0x01, 0x01},
+		},
+	} {
+		pkt := append(append([]byte{}, header...), tc.payload...)
+		if tc.padding {
+			pkt[0] |= 0x20
+		}
+		unwrapped, err := unwrapRTX(pkt, len(header), 1, 0x60)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected error '%v', got '%v'", name, tc.err, err)
+		}
+		assert.Equal(t, tc.expected, unwrapped, name)
+	}
+}
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	sdesAttributePrefix = "crypto:"
+	sdesKeyMethod       = "inline:"
+	sdesKDRParam        = "KDR="
+	sdesMaxMKILength    = 128
+)
+
+// CryptoAttribute is an SDP crypto attribute carrying the master key of one
+// direction of an SDES keyed session.
+//
+// https://tools.ietf.org/html/rfc4568
+type CryptoAttribute struct {
+	Tag        uint32
+	Profile    ProtectionProfile
+	MasterKey  []byte
+	MasterSalt []byte
+
+	// Lifetime is the maximum number of packets to be protected with the
+	// master key, 0 if it is not given. CreateContext applies it with
+	// MasterKeyLifetime.
+	Lifetime uint64
+
+	// MKI is the master key identifier, nil if MKI is not used.
+	MKI []byte
+
+	// SessionParams are the optional session parameters, e.g. "KDR=1",
+	// kept verbatim.
+	SessionParams []string
+}
+
+// GenerateCryptoAttribute returns a CryptoAttribute with a new random master
+// key and salt, e.g. for an outbound offer.
+func GenerateCryptoAttribute(tag uint32, profile ProtectionProfile) (*CryptoAttribute, error) {
+	if _, err := sdesCryptoSuite(profile); err != nil {
+		return nil, err
+	}
+	keyLen, err := profile.keyLen()
+	if err != nil {
+		return nil, err
+	}
+	saltLen, err := profile.saltLen()
+	if err != nil {
+		return nil, err
+	}
+
+	keyingMaterial := make([]byte, keyLen+saltLen)
+	if _, err = rand.Read(keyingMaterial); err != nil {
+		return nil, err
+	}
+	return &CryptoAttribute{
+		Tag:        tag,
+		Profile:    profile,
+		MasterKey:  keyingMaterial[:keyLen],
+		MasterSalt: keyingMaterial[keyLen:],
+	}, nil
+}
+
+// ParseCryptoAttribute parses a crypto attribute, with or without the
+// leading "a=crypto:", e.g.
+// "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4".
+// A single inline key is supported.
+func ParseCryptoAttribute(s string) (*CryptoAttribute, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "a=")
+	s = strings.TrimPrefix(s, sdesAttributePrefix)
+
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("%w: %q", errInvalidCryptoAttribute, s)
+	}
+
+	tag, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tag %q", errInvalidCryptoAttribute, fields[0])
+	}
+
+	profile, err := ParseProtectionProfile(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	if suite, err := sdesCryptoSuite(profile); err != nil || suite != fields[1] {
+		return nil, fmt.Errorf("%w: %q", errNoSuchSRTPProfile, fields[1])
+	}
+
+	a := &CryptoAttribute{Tag: uint32(tag), Profile: profile}
+	if err = a.parseKeyParams(fields[2]); err != nil {
+		return nil, err
+	}
+	if len(fields) > 3 {
+		a.SessionParams = fields[3:]
+	}
+	return a, nil
+}
+
+func (a *CryptoAttribute) parseKeyParams(keyParams string) error {
+	if strings.Contains(keyParams, ";") {
+		return fmt.Errorf("%w: multiple keys are not supported", errInvalidCryptoAttribute)
+	}
+	if !strings.HasPrefix(keyParams, sdesKeyMethod) {
+		return fmt.Errorf("%w: unsupported key method %q", errInvalidCryptoAttribute, keyParams)
+	}
+
+	params := strings.Split(strings.TrimPrefix(keyParams, sdesKeyMethod), "|")
+	if len(params) > 3 {
+		return fmt.Errorf("%w: %q", errInvalidCryptoAttribute, keyParams)
+	}
+
+	keyLen, err := a.Profile.keyLen()
+	if err != nil {
+		return err
+	}
+	saltLen, err := a.Profile.saltLen()
+	if err != nil {
+		return err
+	}
+	keyingMaterial, err := base64.StdEncoding.DecodeString(params[0])
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCryptoAttribute, err)
+	} else if len(keyingMaterial) != keyLen+saltLen {
+		return fmt.Errorf("%w: key and salt must be %d bytes, got %d", errInvalidCryptoAttribute, keyLen+saltLen, len(keyingMaterial))
+	}
+	a.MasterKey = keyingMaterial[:keyLen]
+	a.MasterSalt = keyingMaterial[keyLen:]
+
+	// The lifetime comes before the MKI, both are optional.
+	for i, param := range params[1:] {
+		if value, length, ok := strings.Cut(param, ":"); ok {
+			if i != len(params)-2 {
+				return fmt.Errorf("%w: MKI must be the last parameter", errInvalidCryptoAttribute)
+			}
+			if a.MKI, err = parseSDESMKI(value, length); err != nil {
+				return err
+			}
+		} else if i == 0 {
+			if a.Lifetime, err = parseSDESLifetime(param); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("%w: %q", errInvalidCryptoAttribute, param)
+		}
+	}
+	return nil
+}
+
+func parseSDESLifetime(lifetime string) (uint64, error) {
+	if strings.HasPrefix(lifetime, "2^") {
+		n, err := strconv.ParseUint(lifetime[2:], 10, 8)
+		if err != nil || n > 63 {
+			return 0, fmt.Errorf("%w: lifetime %q", errInvalidCryptoAttribute, lifetime)
+		}
+		return 1 << n, nil
+	}
+
+	n, err := strconv.ParseUint(lifetime, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("%w: lifetime %q", errInvalidCryptoAttribute, lifetime)
+	}
+	return n, nil
+}
+
+func parseSDESMKI(value, length string) ([]byte, error) {
+	n, err := strconv.Atoi(length)
+	if err != nil || n < 1 || n > sdesMaxMKILength {
+		return nil, fmt.Errorf("%w: MKI length %q", errInvalidCryptoAttribute, length)
+	}
+
+	v, ok := new(big.Int).SetString(value, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > n*8 {
+		return nil, fmt.Errorf("%w: MKI value %q", errInvalidCryptoAttribute, value)
+	}
+	return v.FillBytes(make([]byte, n)), nil
+}
+
+// String returns the attribute value as it follows "a=crypto:" in SDP.
+func (a *CryptoAttribute) String() string {
+	suite, err := sdesCryptoSuite(a.Profile)
+	if err != nil {
+		suite = a.Profile.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s %s", a.Tag, suite, sdesKeyMethod)
+	b.WriteString(base64.StdEncoding.EncodeToString(append(append([]byte{}, a.MasterKey...), a.MasterSalt...)))
+
+	if a.Lifetime != 0 {
+		if a.Lifetime&(a.Lifetime-1) == 0 {
+			fmt.Fprintf(&b, "|2^%d", bits.TrailingZeros64(a.Lifetime))
+		} else {
+			fmt.Fprintf(&b, "|%d", a.Lifetime)
+		}
+	}
+	if len(a.MKI) != 0 {
+		fmt.Fprintf(&b, "|%s:%d", new(big.Int).SetBytes(a.MKI), len(a.MKI))
+	}
+
+	for _, param := range a.SessionParams {
+		b.WriteString(" ")
+		b.WriteString(param)
+	}
+	return b.String()
+}
+
+// CreateContext creates a Context from the master key of the attribute.
+// MKI is enabled if the attribute carries one, the lifetime limits the number
+// of SRTP and SRTCP packets protected with the master key, and the KDR session
+// parameter sets the key derivation rate. The other session parameters, e.g.
+// UNENCRYPTED_SRTP or WSH, change how packets are protected and are not
+// supported, an attribute carrying one is rejected.
+func (a *CryptoAttribute) CreateContext(opts ...ContextOption) (*Context, error) {
+	var attrOpts []ContextOption
+	if a.Lifetime != 0 {
+		attrOpts = append(attrOpts, MasterKeyLifetime(a.Lifetime, a.Lifetime))
+	}
+	if len(a.MKI) != 0 {
+		attrOpts = append(attrOpts, MasterKeyIndicator(a.MKI))
+	}
+	for _, param := range a.SessionParams {
+		if !strings.HasPrefix(param, sdesKDRParam) {
+			return nil, fmt.Errorf("%w: unsupported session parameter %q", errInvalidCryptoAttribute, param)
+		}
+		// RFC 4568 Section 6.3.1 only allows rates of 2^1 to 2^24.
+		n, err := strconv.ParseUint(param[len(sdesKDRParam):], 10, 8)
+		if err != nil || n < 1 || n > 24 {
+			return nil, fmt.Errorf("%w: %q", errInvalidCryptoAttribute, param)
+		}
+		attrOpts = append(attrOpts, KeyDerivationRate(uint(n)))
+	}
+	return CreateContext(a.MasterKey, a.MasterSalt, a.Profile, append(attrOpts, opts...)...)
+}
+
+// sdesCryptoSuite returns the SDES crypto-suite name of the profile.
+// https://www.iana.org/assignments/sdp-security-descriptions/sdp-security-descriptions.xhtml
+func sdesCryptoSuite(p ProtectionProfile) (string, error) {
+	switch p {
+	case ProtectionProfileAes128CmHmacSha1_80:
+		return "AES_CM_128_HMAC_SHA1_80", nil
+	case ProtectionProfileAes128CmHmacSha1_32:
+		return "AES_CM_128_HMAC_SHA1_32", nil
+	case ProtectionProfileAes256CmHmacSha1_80:
+		return "AES_256_CM_HMAC_SHA1_80", nil
+	case ProtectionProfileAes256CmHmacSha1_32:
+		return "AES_256_CM_HMAC_SHA1_32", nil
+	// Not registered, but used by libsrtp based endpoints for debugging.
+	case ProtectionProfileNullHmacSha1_80:
+		return "NULL_HMAC_SHA1_80", nil
+	case ProtectionProfileNullHmacSha1_32:
+		return "NULL_HMAC_SHA1_32", nil
+	case ProtectionProfileAeadAes128Gcm:
+		return "AEAD_AES_128_GCM", nil
+	case ProtectionProfileAeadAes256Gcm:
+		return "AEAD_AES_256_GCM", nil
+	default:
+		return "", fmt.Errorf("%w: %v has no SDES crypto-suite", errNoSuchSRTPProfile, p)
+	}
+}
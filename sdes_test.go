@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCryptoAttribute(t *testing.T) {
+	assert := assert.New(t)
+
+	// https://tools.ietf.org/html/rfc4568#section-4
+	a, err := ParseCryptoAttribute("a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(uint32(1), a.Tag)
+	assert.Equal(ProtectionProfileAes128CmHmacSha1_80, a.Profile)
+	assert.Equal([]byte{
+		0x3d, 0x2d, 0x6e, 0x40, 0x25, 0x5e, 0x78, 0x21,
+		0x42, 0x6a, 0x75, 0x66, 0x72, 0x39, 0x29, 0x3f,
+	}, a.MasterKey)
+	assert.Equal([]byte{
+		0x2c, 0x23, 0x35, 0x68, 0x5c, 0x60, 0x3d, 0x26,
+		0x5d, 0x7b, 0x71, 0x69, 0x50, 0x51,
+	}, a.MasterSalt)
+	assert.Equal(uint64(1<<20), a.Lifetime)
+	assert.Equal([]byte{0x00, 0x00, 0x00, 0x01}, a.MKI)
+	assert.Nil(a.SessionParams)
+	assert.Equal("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4", a.String())
+
+	a, err = ParseCryptoAttribute("2 AES_CM_128_HMAC_SHA1_32 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1000 KDR=1 UNENCRYPTED_SRTCP")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(ProtectionProfileAes128CmHmacSha1_32, a.Profile)
+	assert.Equal(uint64(1000), a.Lifetime)
+	assert.Nil(a.MKI)
+	assert.Equal([]string{"KDR=1", "UNENCRYPTED_SRTCP"}, a.SessionParams)
+	assert.Equal("2 AES_CM_128_HMAC_SHA1_32 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1000 KDR=1 UNENCRYPTED_SRTCP", a.String())
+
+	a, err = ParseCryptoAttribute("crypto:3 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|258:2")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Zero(a.Lifetime)
+	assert.Equal([]byte{0x01, 0x02}, a.MKI)
+}
+
+func TestParseCryptoAttributeInvalid(t *testing.T) {
+	for name, attr := range map[string]string{
+		"Empty":          "",
+		"NoKey":          "1 AES_CM_128_HMAC_SHA1_80",
+		"BadTag":         "x AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+		"KeyMethod":      "1 AES_CM_128_HMAC_SHA1_80 uri:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+		"Base64":         "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVB!",
+		"KeyLength":      "1 AES_256_CM_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+		"Lifetime":       "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^64",
+		"ZeroLifetime":   "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|0",
+		"MKIOrder":       "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1:4|2^20",
+		"MKILength":      "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1:0",
+		"MKIValue":       "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|256:1",
+		"TwoLifetimes":   "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|2^20",
+		"MultipleKeys":   "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1:4;inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2:4",
+		"UnknownSuite":   "1 F8_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+		"DTLSSuiteName":  "1 SRTP_AES128_CM_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+		"NumericSuiteID": "1 1 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR",
+	} {
+		if _, err := ParseCryptoAttribute(attr); err == nil {
+			t.Errorf("%s: ParseCryptoAttribute accepted %q", name, attr)
+		}
+	}
+}
+
+func TestCryptoAttributeContext(t *testing.T) {
+	for _, profile := range []ProtectionProfile{
+		ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes256CmHmacSha1_32,
+		ProtectionProfileNullHmacSha1_80,
+		ProtectionProfileAeadAes128Gcm,
+	} {
+		profile := profile
+		t.Run(profile.String(), func(t *testing.T) {
+			assert := assert.New(t)
+
+			offer, err := GenerateCryptoAttribute(1, profile)
+			if !assert.NoError(err) {
+				return
+			}
+			offer.MKI = []byte{0x00, 0x07}
+
+			answer, err := ParseCryptoAttribute(offer.String())
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(offer, answer)
+
+			encryptContext, err := offer.CreateContext()
+			assert.NoError(err)
+			decryptContext, err := answer.CreateContext()
+			assert.NoError(err)
+
+			decrypted := []byte{
+				0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad,
+				0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab,
+			}
+			encrypted, err := encryptContext.EncryptRTP(nil, decrypted, nil)
+			assert.NoError(err)
+			actual, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+			assert.NoError(err)
+			assert.Equal(decrypted, actual)
+			assert.True(bytes.Contains(encrypted, offer.MKI), "packet must carry the MKI")
+		})
+	}
+
+	_, err := GenerateCryptoAttribute(1, ProtectionProfile(0x1234))
+	assert.ErrorIs(t, err, errNoSuchSRTPProfile)
+}
+
+func TestCryptoAttributeKeyDerivationRate(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR KDR=4")
+	if !assert.NoError(err) {
+		return
+	}
+	c, err := a.CreateContext()
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(uint64(1<<4), c.kdr)
+
+	a.SessionParams = []string{"KDR=25"}
+	_, err = a.CreateContext()
+	assert.ErrorIs(err, errInvalidCryptoAttribute)
+
+	a.SessionParams = []string{"KDR=0"}
+	_, err = a.CreateContext()
+	assert.ErrorIs(err, errInvalidCryptoAttribute)
+
+	a.SessionParams = []string{"KDR=x"}
+	_, err = a.CreateContext()
+	assert.ErrorIs(err, errInvalidCryptoAttribute)
+}
+
+func TestCryptoAttributeUnsupportedSessionParams(t *testing.T) {
+	for _, param := range []string{
+		"UNENCRYPTED_SRTP", "UNENCRYPTED_SRTCP", "UNAUTHENTICATED_SRTP",
+		"FEC_ORDER=FEC_SRTP", "FEC_KEY=inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR", "WSH=128",
+	} {
+		a, err := ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR KDR=4 " + param)
+		if !assert.NoError(t, err, param) {
+			continue
+		}
+		_, err = a.CreateContext()
+		assert.ErrorIs(t, err, errInvalidCryptoAttribute, param)
+	}
+}
+
+func TestCryptoAttributeNullHmacSha1(t *testing.T) {
+	a, err := ParseCryptoAttribute("1 NULL_HMAC_SHA1_32 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ProtectionProfileNullHmacSha1_32, a.Profile)
+	assert.Equal(t, "1 NULL_HMAC_SHA1_32 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR", a.String())
+
+	c, err := a.CreateContext()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The payload stays readable, e.g. in a packet capture.
+	decrypted := []byte{
+		0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad,
+		0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab,
+	}
+	encrypted, err := c.EncryptRTP(nil, decrypted, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, decrypted, encrypted[:len(decrypted)])
+}
+
+func TestCryptoAttributeLifetime(t *testing.T) {
+	a, err := ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c, err := a.CreateContext()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	decrypted := []byte{0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe}
+	for i := 0; i < 2; i++ {
+		_, err = c.EncryptRTP(nil, decrypted, nil)
+		assert.NoError(t, err)
+	}
+	_, err = c.EncryptRTP(nil, decrypted, nil)
+	assert.ErrorIs(t, err, ErrKeyLifetimeExceeded)
+}
@@ -0,0 +1,239 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"fmt"
+
+	"github.com/pion/rtp"
+)
+
+type knownAnswerTest struct {
+	name string
+	run  func() error
+}
+
+// RunKnownAnswerTests checks the key derivation, the counter and IV
+// construction, the ciphers and the authentication tags against known
+// answers, from the test vectors of RFC 3711 Appendix B, RFC 6188 and RFC
+// 7714 where they exist. It is meant as a self-test to run at startup, and
+// returns an error wrapping ErrKnownAnswerTest for the first check that fails.
+func RunKnownAnswerTests() error {
+	for _, kat := range knownAnswerTests {
+		if err := kat.run(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrKnownAnswerTest, kat.name, err) //nolint:errorlint
+		}
+	}
+	return nil
+}
+
+var knownAnswerTests = []knownAnswerTest{ //nolint:gochecknoglobals
+	// https://tools.ietf.org/html/rfc3711#appendix-B.3
+	{"AES_CM_128 key derivation", func() error {
+		return checkKeyDerivation(
+			[]byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39},
+			[]byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6},
+			[]byte{0xC6, 0x1E, 0x7A, 0x93, 0x74, 0x4F, 0x39, 0xEE, 0x10, 0x73, 0x4A, 0xFE, 0x3F, 0xF7, 0xA0, 0x87},
+			[]byte{0x30, 0xCB, 0xBC, 0x08, 0x86, 0x3D, 0x8C, 0x85, 0xD4, 0x9D, 0xB3, 0x4A, 0x9A, 0xE1},
+			[]byte{
+				0xCE, 0xBE, 0x32, 0x1F, 0x6F, 0xF7, 0x71, 0x6B, 0x6F, 0xD4,
+				0xAB, 0x49, 0xAF, 0x25, 0x6A, 0x15, 0x6D, 0x38, 0xBA, 0xA4,
+			},
+		)
+	}},
+	// https://tools.ietf.org/html/rfc6188#section-7
+	{"AES_CM_256 key derivation", func() error {
+		return checkKeyDerivation(
+			[]byte{
+				0xf0, 0xf0, 0x49, 0x14, 0xb5, 0x13, 0xf2, 0x76, 0x3a, 0x1b, 0x1f, 0xa1, 0x30, 0xf1, 0x0e, 0x29,
+				0x98, 0xf6, 0xf6, 0xe4, 0x3e, 0x43, 0x09, 0xd1, 0xe6, 0x22, 0xa0, 0xe3, 0x32, 0xb9, 0xf1, 0xb6,
+			},
+			[]byte{0x3b, 0x04, 0x80, 0x3d, 0xe5, 0x1e, 0xe7, 0xc9, 0x64, 0x23, 0xab, 0x5b, 0x78, 0xd2},
+			[]byte{
+				0x5b, 0xa1, 0x06, 0x4e, 0x30, 0xec, 0x51, 0x61, 0x3c, 0xad, 0x92, 0x6c, 0x5a, 0x28, 0xef, 0x73,
+				0x1e, 0xc7, 0xfb, 0x39, 0x7f, 0x70, 0xa9, 0x60, 0x65, 0x3c, 0xaf, 0x06, 0x55, 0x4c, 0xd8, 0xc4,
+			},
+			[]byte{0xfa, 0x31, 0x79, 0x16, 0x85, 0xca, 0x44, 0x4a, 0x9e, 0x07, 0xc6, 0xc6, 0x4e, 0x93},
+			[]byte{
+				0xfd, 0x9c, 0x32, 0xd3, 0x9e, 0xd5, 0xfb, 0xb5, 0xa9, 0xdc,
+				0x96, 0xb3, 0x08, 0x18, 0x45, 0x4d, 0x13, 0x13, 0xdc, 0x05,
+			},
+		)
+	}},
+	// https://tools.ietf.org/html/rfc3711#appendix-B.2
+	{"AES_CM keystream", func() error {
+		salt := []byte{0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd}
+		block, err := aes.NewCipher([]byte{
+			0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c,
+		})
+		if err != nil {
+			return err
+		}
+
+		counter := generateCounter(0, 0, 0, salt)
+		if err = checkBytes("counter", append(salt, 0x00, 0x00), counter[:]); err != nil {
+			return err
+		}
+		keystream := make([]byte, 48)
+		if err = xorBytesCTR(block, counter[:], keystream, keystream); err != nil {
+			return err
+		}
+		return checkBytes("keystream", []byte{
+			0xe0, 0x3e, 0xad, 0x09, 0x35, 0xc9, 0x5e, 0x80, 0xe1, 0x66, 0xb1, 0x6d, 0xd9, 0x2b, 0x4e, 0xb4,
+			0xd2, 0x35, 0x13, 0x16, 0x2b, 0x02, 0xd0, 0xf7, 0x2a, 0x43, 0xa2, 0xfe, 0x4a, 0x5f, 0x97, 0xab,
+			0x41, 0xe9, 0x5b, 0x3b, 0xb0, 0xa2, 0xe8, 0xdd, 0x47, 0x79, 0x01, 0xe4, 0xfc, 0xa8, 0x94, 0xc0,
+		}, keystream)
+	}},
+	// https://tools.ietf.org/html/rfc7714#section-16.1.1
+	{"AEAD_AES_GCM IV", func() error {
+		salt := []byte{0x51, 0x75, 0x69, 0x64, 0x20, 0x70, 0x72, 0x6f, 0x20, 0x71, 0x75, 0x6f}
+		s := &srtpCipherAeadAesGcm{srtpSessionSalt: salt, srtcpSessionSalt: salt}
+
+		iv := s.rtpInitializationVector(&rtp.Header{SSRC: 0xcafebabe, SequenceNumber: 0x1234}, 0)
+		if err := checkBytes("SRTP IV", []byte{
+			0x51, 0x75, 0xa3, 0x9a, 0x9a, 0xce, 0x72, 0x6f, 0x20, 0x71, 0x67, 0x5b,
+		}, iv[:]); err != nil {
+			return err
+		}
+		iv = s.rtcpInitializationVector(0x000005d4, 0x4d617273)
+		return checkBytes("SRTCP IV", []byte{
+			0x51, 0x75, 0x24, 0x05, 0x52, 0x03, 0x72, 0x6f, 0x20, 0x71, 0x70, 0xbb,
+		}, iv[:])
+	}},
+	{"AES_CM_128_HMAC_SHA1_80 SRTP", func() error {
+		return checkPacket(ProtectionProfileAes128CmHmacSha1_80, false,
+			[]byte{0x0d, 0xcd, 0x21, 0x3e, 0x4c, 0xbc, 0xf2, 0x8f, 0x01, 0x7f, 0x69, 0x94, 0x40, 0x1e, 0x28, 0x89},
+			[]byte{0x62, 0x77, 0x60, 0x38, 0xc0, 0x6d, 0xc9, 0x41, 0x9f, 0x6d, 0xd9, 0x43, 0x3e, 0x7c},
+			[]byte{
+				0x00, 0x00, 0x13, 0x88, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x01, 0x02, 0x03, 0x04, 0x05,
+			},
+			[]byte{
+				0x00, 0x00, 0x13, 0x88, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x6d, 0xd3, 0x7e, 0xd5, 0x99, 0xb7, 0x2d, 0x28, 0xb1, 0xf3, 0xa1, 0xf0, 0x0c, 0xfb, 0xfd, 0x08,
+			},
+		)
+	}},
+	{"AES_CM_128_HMAC_SHA1_80 SRTCP", func() error {
+		return checkPacket(ProtectionProfileAes128CmHmacSha1_80, true,
+			[]byte{0xfd, 0xa6, 0x25, 0x95, 0xd7, 0xf6, 0x92, 0x6f, 0x7d, 0x9c, 0x02, 0x4c, 0xc9, 0x20, 0x9f, 0x34},
+			[]byte{0xa9, 0x65, 0x19, 0x85, 0x54, 0x0b, 0x47, 0xbe, 0x2f, 0x27, 0xa8, 0xb8, 0x81, 0x23},
+			[]byte{
+				0x80, 0xc8, 0x00, 0x06, 0x66, 0xef, 0x91, 0xff, 0xdf, 0x48, 0x80, 0xdd, 0x61, 0xa6, 0x2e, 0xd3,
+				0xd8, 0xbc, 0xde, 0xbe, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x16, 0x04, 0x81, 0xca, 0x00, 0x06,
+				0x66, 0xef, 0x91, 0xff, 0x01, 0x10, 0x52, 0x6e, 0x54, 0x35, 0x43, 0x6d, 0x4a, 0x68, 0x7a, 0x79,
+				0x65, 0x74, 0x41, 0x78, 0x77, 0x2b, 0x00, 0x00,
+			},
+			[]byte{
+				0x80, 0xc8, 0x00, 0x06, 0x66, 0xef, 0x91, 0xff, 0xcd, 0x34, 0xc5, 0x78, 0xb2, 0x8b, 0xe1, 0x6b,
+				0xc5, 0x09, 0xd5, 0x77, 0xe4, 0xce, 0x5f, 0x20, 0x80, 0x21, 0xbd, 0x66, 0x74, 0x65, 0xe9, 0x5f,
+				0x49, 0xe5, 0xf5, 0xc0, 0x68, 0x4e, 0xe5, 0x6a, 0x78, 0x07, 0x75, 0x46, 0xed, 0x90, 0xf6, 0xdc,
+				0x9d, 0xef, 0x3b, 0xdf, 0xf2, 0x79, 0xa9, 0xd8, 0x80, 0x00, 0x00, 0x01, 0x60, 0xc0, 0xae, 0xb5,
+				0x6f, 0x40, 0x88, 0x0e, 0x28, 0xba,
+			},
+		)
+	}},
+	{"AEAD_AES_128_GCM SRTP", func() error {
+		return checkPacket(ProtectionProfileAeadAes128Gcm, false, gcmKnownAnswerKey, gcmKnownAnswerSalt,
+			[]byte{
+				0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab,
+				0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+			},
+			[]byte{
+				0x80, 0x0f, 0x12, 0x34, 0xde, 0xca, 0xfb, 0xad, 0xca, 0xfe, 0xba, 0xbe, 0xc5, 0x00, 0x2e, 0xde,
+				0x04, 0xcf, 0xdd, 0x2e, 0xb9, 0x11, 0x59, 0xe0, 0x88, 0x0a, 0xa0, 0x6e, 0xd2, 0x97, 0x68, 0x26,
+				0xf7, 0x96, 0xb2, 0x01, 0xdf, 0x31, 0x31, 0xa1, 0x27, 0xe8, 0xa3, 0x92,
+			},
+		)
+	}},
+	{"AEAD_AES_128_GCM SRTCP", func() error {
+		return checkPacket(ProtectionProfileAeadAes128Gcm, true, gcmKnownAnswerKey, gcmKnownAnswerSalt,
+			[]byte{
+				0x81, 0xc8, 0x00, 0x0b, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+				0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+			},
+			[]byte{
+				0x81, 0xc8, 0x00, 0x0b, 0xca, 0xfe, 0xba, 0xbe, 0xc9, 0x8b, 0x8b, 0x5d, 0xf0, 0x39, 0x2a, 0x55,
+				0x85, 0x2b, 0x6c, 0x21, 0xac, 0x8e, 0x70, 0x25, 0xc5, 0x2c, 0x6f, 0xbe, 0xa2, 0xb3, 0xb4, 0x46,
+				0xea, 0x31, 0x12, 0x3b, 0xa8, 0x8c, 0xe6, 0x1e, 0x80, 0x00, 0x00, 0x01,
+			},
+		)
+	}},
+}
+
+var (
+	gcmKnownAnswerKey  = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f} //nolint:gochecknoglobals
+	gcmKnownAnswerSalt = []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab}                         //nolint:gochecknoglobals
+)
+
+func checkBytes(name string, expected, actual []byte) error {
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("%s is % 02x, expected % 02x", name, actual, expected) //nolint:goerr113
+	}
+	return nil
+}
+
+func checkKeyDerivation(masterKey, masterSalt, sessionKey, sessionSalt, sessionAuthKey []byte) error {
+	for _, derived := range []struct {
+		name     string
+		label    byte
+		expected []byte
+	}{
+		{"session key", labelSRTPEncryption, sessionKey},
+		{"session salt", labelSRTPSalt, sessionSalt},
+		{"session authentication key", labelSRTPAuthenticationTag, sessionAuthKey},
+	} {
+		actual, err := aesCmKeyDerivation(derived.label, masterKey, masterSalt, 0, len(derived.expected))
+		if err != nil {
+			return err
+		}
+		if err = checkBytes(derived.name, derived.expected, actual); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPacket encrypts and decrypts the first packet of an SSRC with new
+// Contexts, and compares the results with the known packets.
+func checkPacket(profile ProtectionProfile, rtcp bool, masterKey, masterSalt, decrypted, encrypted []byte) error {
+	for _, encrypt := range []bool{true, false} {
+		c, err := CreateContext(masterKey, masterSalt, profile)
+		if err != nil {
+			return err
+		}
+
+		var in, expected, actual []byte
+		if in, expected = encrypted, decrypted; encrypt {
+			in, expected = decrypted, encrypted
+		}
+		switch {
+		case rtcp && encrypt:
+			actual, err = c.EncryptRTCP(nil, in, nil)
+		case rtcp:
+			actual, err = c.DecryptRTCP(nil, in, nil)
+		case encrypt:
+			actual, err = c.EncryptRTP(nil, in, nil)
+		default:
+			actual, err = c.DecryptRTP(nil, in, nil)
+		}
+		if errClose := c.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			return err
+		}
+
+		name := "decrypted packet"
+		if encrypt {
+			name = "encrypted packet"
+		}
+		if err = checkBytes(name, expected, actual); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunKnownAnswerTests(t *testing.T) {
+	assert.NoError(t, RunKnownAnswerTests())
+
+	// A mismatch is reported with the name of the test.
+	tests := knownAnswerTests
+	defer func() { knownAnswerTests = tests }()
+	knownAnswerTests = append([]knownAnswerTest{}, tests...)
+	knownAnswerTests = append(knownAnswerTests, knownAnswerTest{"Broken", func() error {
+		return checkKeyDerivation(make([]byte, 16), make([]byte, 14), make([]byte, 16), make([]byte, 14), make([]byte, 20))
+	}})
+
+	err := RunKnownAnswerTests()
+	assert.ErrorIs(t, err, ErrKnownAnswerTest)
+	assert.Contains(t, err.Error(), "Broken: session key is")
+}
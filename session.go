@@ -4,10 +4,12 @@
 package srtp
 
 import (
+	"crypto/rand"
 	"errors"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/logging"
@@ -21,7 +23,8 @@ type streamSession interface {
 }
 
 type session struct {
-	localContextMutex           sync.Mutex
+	localContextMutex, remoteContextMutex sync.Mutex
+
 	localContext, remoteContext *Context
 	localOptions, remoteOptions []ContextOption
 
@@ -39,6 +42,24 @@ type session struct {
 	bufferFactory func(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser
 
 	nextConn net.Conn
+
+	// Set until the keys are installed with setKeys, see
+	// Config.PreKeyBufferSize. The packets received in the meantime are
+	// queued in preKeyPackets, and decrypted by a goroutine which closes
+	// preKeyReplayed once done. preKeyClosed is set when the read loop exits.
+	keysPending      atomic.Bool
+	preKeyLock       sync.Mutex
+	preKeyPackets    []preKeyPacket
+	preKeyReplayed   chan struct{}
+	preKeyClosed     bool
+	preKeyBufferSize int
+	preKeyBufferTTL  time.Duration
+}
+
+// A packet received before the keys of the session
+type preKeyPacket struct {
+	buf      []byte
+	received time.Time
 }
 
 // Config is used to configure a session.
@@ -56,6 +77,17 @@ type Config struct {
 	// ReplayProtection is enabled on remote context by default.
 	// Default replay protection window size is 64.
 	LocalOptions, RemoteOptions []ContextOption
+
+	// PreKeyBufferSize allows creating a session before its keys are known,
+	// e.g. while the DTLS handshake completes, so that the media received
+	// in the meantime is not lost. If it is not zero and Keys is empty, up
+	// to PreKeyBufferSize packets received before the keys are installed
+	// with SetKeys are queued, and decrypted once they are. The oldest
+	// packets are dropped when the queue is full, and packets queued for
+	// more than PreKeyBufferTTL, if it is not zero, are dropped when the
+	// keys are installed. Writing fails until then.
+	PreKeyBufferSize int
+	PreKeyBufferTTL  time.Duration
 }
 
 // SessionKeys bundles the keys required to setup an SRTP session
@@ -109,11 +141,167 @@ func (s *session) close() error {
 	}
 
 	<-s.closed
+
+	// The read loop has exited, zero the key material of both directions.
+	s.remoteContextMutex.Lock()
+	if s.remoteContext != nil {
+		_ = s.remoteContext.Close()
+	}
+	s.remoteContextMutex.Unlock()
+	s.localContextMutex.Lock()
+	defer s.localContextMutex.Unlock()
+	if s.localContext != nil {
+		_ = s.localContext.Close()
+	}
+	return nil
+}
+
+// updateMasterKeys replaces the master keys of both directions.
+func (s *session) updateMasterKeys(keys SessionKeys) error {
+	if _, ok := <-s.started; ok {
+		return errStartedChannelUsedIncorrectly
+	} else if s.keysPending.Load() {
+		return errKeysNotSet
+	}
+	return s.installMasterKeys(keys)
+}
+
+// setKeys installs the keys of a session created without them, and decrypts
+// the packets received until then in a goroutine. The read loop waits for it
+// before decrypting the following packets, so that they stay in order.
+func (s *session) setKeys(keys SessionKeys, child streamSession) error {
+	if _, ok := <-s.started; ok {
+		return errStartedChannelUsedIncorrectly
+	}
+
+	s.preKeyLock.Lock()
+	defer s.preKeyLock.Unlock()
+	if s.preKeyClosed {
+		return errSessionClosed
+	} else if !s.keysPending.Load() {
+		return errKeysAlreadySet
+	}
+	if err := s.installMasterKeys(keys); err != nil {
+		return err
+	}
+	s.keysPending.Store(false)
+
+	packets := s.preKeyPackets
+	s.preKeyPackets = nil
+	replayed := make(chan struct{})
+	s.preKeyReplayed = replayed
+	go func() {
+		defer close(replayed)
+		for _, p := range packets {
+			if s.preKeyBufferTTL != 0 && time.Since(p.received) > s.preKeyBufferTTL {
+				continue
+			}
+			if err := child.decrypt(p.buf); err != nil {
+				s.log.Info(err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// bufferPreKeyPacket queues a packet received before the keys, and returns
+// false if the keys are installed. It then waits for the queued packets to be
+// decrypted.
+func (s *session) bufferPreKeyPacket(buf []byte) bool {
+	s.preKeyLock.Lock()
+	if !s.keysPending.Load() {
+		replayed := s.preKeyReplayed
+		s.preKeyLock.Unlock()
+		if replayed != nil {
+			<-replayed
+		}
+		return false
+	}
+	defer s.preKeyLock.Unlock()
+
+	if len(s.preKeyPackets) == s.preKeyBufferSize {
+		s.log.Debug("pre-key buffer is full, dropping the oldest packet")
+		copy(s.preKeyPackets, s.preKeyPackets[1:])
+		s.preKeyPackets = s.preKeyPackets[:len(s.preKeyPackets)-1]
+	}
+	s.preKeyPackets = append(s.preKeyPackets, preKeyPacket{append([]byte{}, buf...), time.Now()})
+	return true
+}
+
+// closePreKeyBuffer drops the queued packets when the read loop exits, and
+// waits for the packets queued before the keys were installed to be decrypted.
+func (s *session) closePreKeyBuffer() {
+	s.preKeyLock.Lock()
+	s.preKeyClosed = true
+	s.preKeyPackets = nil
+	replayed := s.preKeyReplayed
+	s.preKeyLock.Unlock()
+	if replayed != nil {
+		<-replayed
+	}
+}
+
+// installMasterKeys replaces the master keys of both directions, the new keys
+// are validated before either Context is changed. Both Contexts are locked
+// meanwhile, as the packets received before the keys may still be decrypted.
+func (s *session) installMasterKeys(keys SessionKeys) error {
+	s.localContextMutex.Lock()
+	defer s.localContextMutex.Unlock()
+	s.remoteContextMutex.Lock()
+	defer s.remoteContextMutex.Unlock()
+	if s.localContext.closed || s.remoteContext.closed {
+		return errContextClosed
+	}
+
+	local, err := s.localContext.newMasterKeys(keys.LocalMasterKey, keys.LocalMasterSalt, s.localContext.sendMKI)
+	if err != nil {
+		return err
+	}
+	remote, err := s.remoteContext.newMasterKeys(keys.RemoteMasterKey, keys.RemoteMasterSalt, s.remoteContext.sendMKI)
+	if err != nil {
+		local.zero()
+		return err
+	}
+	s.localContext.setMasterKeys(local)
+	s.remoteContext.setMasterKeys(remote)
 	return nil
 }
 
+// stats returns the counters of both directions.
+func (s *session) stats() (local, remote Stats) {
+	s.localContextMutex.Lock()
+	local = s.localContext.Stats()
+	s.localContextMutex.Unlock()
+	s.remoteContextMutex.Lock()
+	remote = s.remoteContext.Stats()
+	s.remoteContextMutex.Unlock()
+	return local, remote
+}
+
+// addEKTKey adds an EKT key to the remote Context.
+func (s *session) addEKTKey(key EKTKey) error {
+	if _, ok := <-s.started; ok {
+		return errStartedChannelUsedIncorrectly
+	}
+
+	s.remoteContextMutex.Lock()
+	defer s.remoteContextMutex.Unlock()
+	return s.remoteContext.AddEKTKey(key)
+}
+
 func (s *session) start(localMasterKey, localMasterSalt, remoteMasterKey, remoteMasterSalt []byte, profile ProtectionProfile, child streamSession) error {
 	var err error
+	if s.preKeyBufferSize > 0 && len(localMasterKey) == 0 && len(localMasterSalt) == 0 &&
+		len(remoteMasterKey) == 0 && len(remoteMasterSalt) == 0 {
+		// The Contexts are created with random keys, replaced by setKeys
+		// before they are used.
+		if localMasterKey, localMasterSalt, err = randomMasterKey(profile); err != nil {
+			return err
+		}
+		remoteMasterKey, remoteMasterSalt = localMasterKey, localMasterSalt
+		s.keysPending.Store(true)
+	}
+
 	s.localContext, err = CreateContext(localMasterKey, localMasterSalt, profile, s.localOptions...)
 	if err != nil {
 		return err
@@ -130,6 +318,7 @@ func (s *session) start(localMasterKey, localMasterSalt, remoteMasterKey, remote
 
 	go func() {
 		defer func() {
+			s.closePreKeyBuffer()
 			close(s.newStream)
 
 			s.readStreamsLock.Lock()
@@ -149,6 +338,9 @@ func (s *session) start(localMasterKey, localMasterSalt, remoteMasterKey, remote
 				return
 			}
 
+			if s.bufferPreKeyPacket(b[:i]) {
+				continue
+			}
 			if err = child.decrypt(b[:i]); err != nil {
 				s.log.Info(err.Error())
 			}
@@ -159,3 +351,20 @@ func (s *session) start(localMasterKey, localMasterSalt, remoteMasterKey, remote
 
 	return nil
 }
+
+func randomMasterKey(profile ProtectionProfile) (masterKey, masterSalt []byte, err error) {
+	keyLen, err := profile.keyLen()
+	if err != nil {
+		return nil, nil, err
+	}
+	saltLen, err := profile.saltLen()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := make([]byte, keyLen+saltLen)
+	if _, err = rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+	return b[:keyLen], b[keyLen:], nil
+}
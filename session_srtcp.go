@@ -59,6 +59,8 @@ func NewSessionSRTCP(conn net.Conn, config *Config) (*SessionSRTCP, error) { //n
 			closed:              make(chan interface{}),
 			bufferFactory:       config.BufferFactory,
 			log:                 loggerFactory.NewLogger("srtp"),
+			preKeyBufferSize:    config.PreKeyBufferSize,
+			preKeyBufferTTL:     config.PreKeyBufferTTL,
 		},
 	}
 	s.writeStream = &WriteStreamSRTCP{s}
@@ -106,6 +108,25 @@ func (s *SessionSRTCP) AcceptStream() (*ReadStreamSRTCP, uint32, error) {
 	return readStream, stream.GetSSRC(), nil
 }
 
+// UpdateMasterKeys replaces the master keys of both directions, e.g. after a
+// DTLS renegotiation, without losing the SRTCP index and replay state of the
+// streams. See Context.UpdateMasterKey.
+func (s *SessionSRTCP) UpdateMasterKeys(keys SessionKeys) error {
+	return s.session.updateMasterKeys(keys)
+}
+
+// SetKeys installs the keys of a session created without them, see
+// Config.PreKeyBufferSize, and decrypts the packets received until then.
+func (s *SessionSRTCP) SetKeys(keys SessionKeys) error {
+	return s.session.setKeys(keys, s)
+}
+
+// Stats returns a snapshot of the counters of the written (local) and read
+// (remote) streams. See Context.Stats.
+func (s *SessionSRTCP) Stats() (local, remote Stats) {
+	return s.session.stats()
+}
+
 // Close ends the session
 func (s *SessionSRTCP) Close() error {
 	return s.session.close()
@@ -116,6 +137,8 @@ func (s *SessionSRTCP) Close() error {
 func (s *SessionSRTCP) write(buf []byte) (int, error) {
 	if _, ok := <-s.session.started; ok {
 		return 0, errStartedChannelUsedIncorrectly
+	} else if s.session.keysPending.Load() {
+		return 0, errKeysNotSet
 	}
 
 	ibuf := bufferpool.Get()
@@ -154,7 +177,9 @@ func destinationSSRC(pkts []rtcp.Packet) []uint32 {
 }
 
 func (s *SessionSRTCP) decrypt(buf []byte) error {
+	s.session.remoteContextMutex.Lock()
 	decrypted, err := s.remoteContext.DecryptRTCP(buf, buf, nil)
+	s.session.remoteContextMutex.Unlock()
 	if err != nil {
 		return err
 	}
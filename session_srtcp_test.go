@@ -222,6 +222,92 @@ func TestSessionSRTCPOpenReadStream(t *testing.T) {
 	}
 }
 
+func TestSessionSRTCPConcurrentWrite(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const packetCount = 100
+	ssrcs := []uint32{5000, 5001, 5002, 5003}
+	aSession, bPipe, config := buildSessionSRTCP(t)
+	bSession, err := NewSessionSRTCP(bPipe, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each session encrypts on several goroutines while its read loop
+	// decrypts the packets sent by the other session, and the master keys
+	// are updated (to the same value) in the meantime.
+	var wg sync.WaitGroup
+	for _, session := range []*SessionSRTCP{aSession, bSession} {
+		readStreams := make(map[uint32]*ReadStreamSRTCP)
+		for _, ssrc := range ssrcs {
+			readStream, errOpen := session.OpenReadStream(ssrc)
+			if errOpen != nil {
+				t.Fatal(errOpen)
+			}
+			readStreams[ssrc] = readStream
+		}
+
+		writeStream, errOpen := session.OpenWriteStream()
+		if errOpen != nil {
+			t.Fatal(errOpen)
+		}
+
+		for _, ssrc := range ssrcs {
+			wg.Add(2)
+			go func(ssrc uint32) {
+				defer wg.Done()
+				for i := 0; i < packetCount; i++ {
+					raw, errMarshal := (&rtcp.PictureLossIndication{SenderSSRC: uint32(i), MediaSSRC: ssrc}).Marshal()
+					if errMarshal != nil {
+						t.Error(errMarshal)
+						return
+					}
+					if _, errWrite := writeStream.Write(raw); errWrite != nil {
+						t.Error(errWrite)
+						return
+					}
+				}
+			}(ssrc)
+			go func(readStream *ReadStreamSRTCP) {
+				defer wg.Done()
+				for i := 0; i < packetCount; i++ {
+					senderSSRC, errRead := getSenderSSRC(t, readStream)
+					if errRead != nil {
+						return
+					}
+					if senderSSRC != uint32(i) {
+						t.Errorf("Expected sender SSRC %d, got %d", i, senderSSRC)
+					}
+				}
+			}(readStreams[ssrc])
+		}
+
+		wg.Add(1)
+		go func(session *SessionSRTCP) {
+			defer wg.Done()
+			for i := 0; i < packetCount; i++ {
+				if errUpdate := session.UpdateMasterKeys(config.Keys); errUpdate != nil {
+					t.Error(errUpdate)
+					return
+				}
+			}
+		}(session)
+	}
+	wg.Wait()
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSessionSRTCPReplayProtection(t *testing.T) {
 	lim := test.TimeOut(time.Second * 5)
 	defer lim.Stop()
@@ -4,6 +4,10 @@
 package srtp
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -21,6 +25,14 @@ const defaultSessionSRTPReplayProtectionWindow = 64
 type SessionSRTP struct {
 	session
 	writeStream *WriteStreamSRTP
+
+	// Retransmission streams by RTX SSRC, see AssociateRTX
+	rtx     map[uint32]rtxAssociation
+	rtxLock sync.Mutex
+
+	// Streams opened with OpenOutboundStream by SSRC
+	outboundStreams     map[uint32]*OutboundStreamSRTP
+	outboundStreamsLock sync.Mutex
 }
 
 // NewSessionSRTP creates a SRTP session using conn as the underlying transport.
@@ -60,6 +72,8 @@ func NewSessionSRTP(conn net.Conn, config *Config) (*SessionSRTP, error) { //nol
 			closed:              make(chan interface{}),
 			bufferFactory:       config.BufferFactory,
 			log:                 loggerFactory.NewLogger("srtp"),
+			preKeyBufferSize:    config.PreKeyBufferSize,
+			preKeyBufferTTL:     config.PreKeyBufferTTL,
 		},
 	}
 	s.writeStream = &WriteStreamSRTP{s}
@@ -81,6 +95,79 @@ func (s *SessionSRTP) OpenWriteStream() (*WriteStreamSRTP, error) {
 	return s.writeStream, nil
 }
 
+// OpenOutboundStream opens a stream originating the RTP packets of a new
+// SSRC. As recommended by RFC 3550, the SSRC and the initial sequence number
+// are random, and the SSRC is not used by the other streams of the Session.
+func (s *SessionSRTP) OpenOutboundStream() (*OutboundStreamSRTP, error) {
+	for {
+		var b [6]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		o, err := s.OpenOutboundStreamWithSSRC(binary.BigEndian.Uint32(b[:]), binary.BigEndian.Uint16(b[4:]))
+		if !errors.Is(err, errSSRCInUse) {
+			return o, err
+		}
+	}
+}
+
+// OpenOutboundStreamWithSSRC opens a stream originating the RTP packets of
+// ssrc, starting with sequenceNumber. It fails if ssrc is already used by a
+// stream read or written in the Session.
+func (s *SessionSRTP) OpenOutboundStreamWithSSRC(ssrc uint32, sequenceNumber uint16) (*OutboundStreamSRTP, error) {
+	if _, ok := <-s.session.started; ok {
+		return nil, errStartedChannelUsedIncorrectly
+	}
+
+	s.outboundStreamsLock.Lock()
+	defer s.outboundStreamsLock.Unlock()
+
+	if _, ok := s.outboundStreams[ssrc]; ok || s.remoteSSRCInUse(ssrc) {
+		return nil, fmt.Errorf("%w: %d", errSSRCInUse, ssrc)
+	}
+	s.session.localContextMutex.Lock()
+	_, ok := s.localContext.srtpSSRCStates[ssrc]
+	s.session.localContextMutex.Unlock()
+	if ok {
+		return nil, fmt.Errorf("%w: %d", errSSRCInUse, ssrc)
+	}
+
+	o := &OutboundStreamSRTP{session: s, ssrc: ssrc, sequenceNumber: sequenceNumber}
+	if s.outboundStreams == nil {
+		s.outboundStreams = map[uint32]*OutboundStreamSRTP{}
+	}
+	s.outboundStreams[ssrc] = o
+	return o, nil
+}
+
+// remoteSSRCInUse reports whether packets of ssrc have been received, or a
+// read stream is open for it.
+func (s *SessionSRTP) remoteSSRCInUse(ssrc uint32) bool {
+	s.session.readStreamsLock.Lock()
+	_, ok := s.session.readStreams[ssrc]
+	s.session.readStreamsLock.Unlock()
+	if ok {
+		return true
+	}
+
+	s.session.remoteContextMutex.Lock()
+	defer s.session.remoteContextMutex.Unlock()
+	_, ok = s.remoteContext.srtpSSRCStates[ssrc]
+	return ok
+}
+
+func (s *SessionSRTP) outboundStream(ssrc uint32) *OutboundStreamSRTP {
+	s.outboundStreamsLock.Lock()
+	defer s.outboundStreamsLock.Unlock()
+	return s.outboundStreams[ssrc]
+}
+
+func (s *SessionSRTP) removeOutboundStream(ssrc uint32) {
+	s.outboundStreamsLock.Lock()
+	defer s.outboundStreamsLock.Unlock()
+	delete(s.outboundStreams, ssrc)
+}
+
 // OpenReadStream opens a read stream for the given SSRC, it can be used
 // if you want a certain SSRC, but don't want to wait for AcceptStream
 func (s *SessionSRTP) OpenReadStream(ssrc uint32) (*ReadStreamSRTP, error) {
@@ -108,19 +195,80 @@ func (s *SessionSRTP) AcceptStream() (*ReadStreamSRTP, uint32, error) {
 	return readStream, stream.GetSSRC(), nil
 }
 
+// UpdateMasterKeys replaces the master keys of both directions, e.g. after a
+// DTLS renegotiation, without losing the rollover and replay state of the
+// streams. See Context.UpdateMasterKey.
+func (s *SessionSRTP) UpdateMasterKeys(keys SessionKeys) error {
+	return s.session.updateMasterKeys(keys)
+}
+
+// SetKeys installs the keys of a session created without them, see
+// Config.PreKeyBufferSize, and decrypts the packets received until then.
+func (s *SessionSRTP) SetKeys(keys SessionKeys) error {
+	return s.session.setKeys(keys, s)
+}
+
+// Stats returns a snapshot of the counters of the written (local) and read
+// (remote) streams. See Context.Stats.
+func (s *SessionSRTP) Stats() (local, remote Stats) {
+	return s.session.stats()
+}
+
+// AddEKTKey adds an EKT key to the remote direction, so that the master keys
+// of the remote senders are learned from the EKT fields of their packets. See
+// Context.AddEKTKey.
+func (s *SessionSRTP) AddEKTKey(key EKTKey) error {
+	return s.session.addEKTKey(key)
+}
+
+// AssociateRTX delivers the packets of rtxSSRC, a retransmission stream
+// (RFC 4588), to the read stream of primarySSRC. RTX packets are decrypted
+// with their own SSRC, so that their ROC and replay window are kept apart.
+// If apt is not nil, it maps RTX payload types to the original payload types
+// as given by the "apt" format parameter, and RTX packets are unwrapped into
+// the original packets before being delivered: the OSN becomes the sequence
+// number, and the SSRC and the payload type are the original ones. RTX
+// packets with a payload type missing from apt are delivered unchanged, and
+// padding-only packets, e.g. bandwidth probes, are dropped.
+func (s *SessionSRTP) AssociateRTX(rtxSSRC, primarySSRC uint32, apt map[uint8]uint8) {
+	a := rtxAssociation{primarySSRC: primarySSRC}
+	if apt != nil {
+		a.apt = make(map[uint8]uint8, len(apt))
+		for rtxPT, pt := range apt {
+			a.apt[rtxPT] = pt
+		}
+	}
+
+	s.rtxLock.Lock()
+	defer s.rtxLock.Unlock()
+	if s.rtx == nil {
+		s.rtx = map[uint32]rtxAssociation{}
+	}
+	s.rtx[rtxSSRC] = a
+}
+
+// rtxAssociation returns the association of ssrc, if it is a retransmission
+// stream.
+func (s *SessionSRTP) rtxAssociation(ssrc uint32) (rtxAssociation, bool) {
+	s.rtxLock.Lock()
+	defer s.rtxLock.Unlock()
+	a, ok := s.rtx[ssrc]
+	return a, ok
+}
+
 // Close ends the session
 func (s *SessionSRTP) Close() error {
 	return s.session.close()
 }
 
 func (s *SessionSRTP) write(b []byte) (int, error) {
-	packet := &rtp.Packet{}
-
-	if err := packet.Unmarshal(b); err != nil {
+	header := &rtp.Header{}
+	headerLen, err := unmarshalRTPHeader(b, header)
+	if err != nil {
 		return 0, err
 	}
 
-	return s.writeRTP(&packet.Header, packet.Payload)
+	return s.writeRTP(header, b[headerLen:])
 }
 
 // bufferpool is a global pool of buffers used for encrypted packets in
@@ -140,6 +288,8 @@ var bufferpool = sync.Pool{ // nolint:gochecknoglobals
 func (s *SessionSRTP) writeRTP(header *rtp.Header, payload []byte) (int, error) {
 	if _, ok := <-s.session.started; ok {
 		return 0, errStartedChannelUsedIncorrectly
+	} else if s.session.keysPending.Load() {
+		return 0, errKeysNotSet
 	}
 
 	// encryptRTP will either return our buffer, or, if it is too
@@ -166,12 +316,18 @@ func (s *SessionSRTP) setWriteDeadline(t time.Time) error {
 
 func (s *SessionSRTP) decrypt(buf []byte) error {
 	h := &rtp.Header{}
-	headerLen, err := h.Unmarshal(buf)
+	headerLen, err := unmarshalRTPHeader(buf, h)
 	if err != nil {
 		return err
 	}
 
-	r, isNew := s.session.getOrCreateReadStream(h.SSRC, s, newReadStreamSRTP)
+	streamSSRC := h.SSRC
+	rtx, isRTX := s.rtxAssociation(h.SSRC)
+	if isRTX {
+		streamSSRC = rtx.primarySSRC
+	}
+
+	r, isNew := s.session.getOrCreateReadStream(streamSSRC, s, newReadStreamSRTP)
 	if r == nil {
 		return nil // Session has been closed
 	} else if isNew {
@@ -186,11 +342,29 @@ func (s *SessionSRTP) decrypt(buf []byte) error {
 		return errFailedTypeAssertion
 	}
 
+	s.session.remoteContextMutex.Lock()
 	decrypted, err := s.remoteContext.decryptRTP(buf, buf, h, headerLen)
+	s.session.remoteContextMutex.Unlock()
 	if err != nil {
 		return err
 	}
 
+	// Only authenticated packets are taken as a collision, see
+	// OutboundStreamSRTP.Collided.
+	if o := s.outboundStream(h.SSRC); o != nil {
+		o.collided.Store(true)
+	}
+
+	if isRTX && rtx.apt != nil {
+		if pt, ok := rtx.apt[h.PayloadType]; ok {
+			if decrypted, err = unwrapRTX(decrypted, headerLen, rtx.primarySSRC, pt); err != nil {
+				return err
+			} else if decrypted == nil {
+				return nil
+			}
+		}
+	}
+
 	_, err = readStream.write(decrypted)
 	if err != nil {
 		return err
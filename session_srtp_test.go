@@ -15,6 +15,7 @@ import (
 
 	"github.com/pion/rtp"
 	"github.com/pion/transport/v3/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSessionSRTPBadInit(t *testing.T) {
@@ -272,6 +273,670 @@ func TestSessionSRTPMultiSSRC(t *testing.T) {
 	}
 }
 
+func TestSessionSRTPProfiles(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+	)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+
+	for _, profile := range []ProtectionProfile{
+		ProtectionProfileAes128CmHmacSha1_80,
+		ProtectionProfileAes128CmHmacSha1_32,
+		ProtectionProfileAes256CmHmacSha1_80,
+		ProtectionProfileNullHmacSha1_80,
+		ProtectionProfileAeadAes128Gcm,
+		ProtectionProfileAeadAes256Gcm,
+	} {
+		profile := profile
+		t.Run(profile.String(), func(t *testing.T) {
+			keyLen, err := profile.keyLen()
+			if err != nil {
+				t.Fatal(err)
+			}
+			saltLen, err := profile.saltLen()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			aPipe, bPipe := net.Pipe()
+			config := &Config{
+				Profile: profile,
+				Keys: SessionKeys{
+					bytes.Repeat([]byte{0x01}, keyLen), bytes.Repeat([]byte{0x02}, saltLen),
+					bytes.Repeat([]byte{0x01}, keyLen), bytes.Repeat([]byte{0x02}, saltLen),
+				},
+			}
+			aSession, err := NewSessionSRTP(aPipe, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bSession, err := NewSessionSRTP(bPipe, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bReadStream, err := bSession.OpenReadStream(testSSRC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aWriteStream, err := aSession.OpenWriteStream()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := uint16(0); i < 3; i++ {
+				if _, err = aWriteStream.WriteRTP(&rtp.Header{SSRC: testSSRC, SequenceNumber: i}, append([]byte{}, testPayload...)); err != nil {
+					t.Fatal(err)
+				}
+				seq, err := assertPayloadSRTP(t, bReadStream, rtpHeaderSize, testPayload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if seq != i {
+					t.Errorf("Sequence number mismatch exp(%d) actual(%d)", i, seq)
+				}
+			}
+
+			if err = aSession.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err = bSession.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSessionSRTPMKI(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+	)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+
+	aPipe, bPipe := net.Pipe()
+	config := &Config{
+		Profile: ProtectionProfileAes128CmHmacSha1_80,
+		Keys: SessionKeys{
+			[]byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39},
+			[]byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6},
+			[]byte{0xE1, 0xF9, 0x7A, 0x0D, 0x3E, 0x01, 0x8B, 0xE0, 0xD6, 0x4F, 0xA3, 0x2C, 0x06, 0xDE, 0x41, 0x39},
+			[]byte{0x0E, 0xC6, 0x75, 0xAD, 0x49, 0x8A, 0xFE, 0xEB, 0xB6, 0x96, 0x0B, 0x3A, 0xAB, 0xE6},
+		},
+		LocalOptions:  []ContextOption{MasterKeyIndicator([]byte{0x01, 0x02})},
+		RemoteOptions: []ContextOption{MasterKeyIndicator([]byte{0x01, 0x02})},
+	}
+
+	aSession, err := NewSessionSRTP(aPipe, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bSession, err := NewSessionSRTP(bPipe, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bReadStream, err := bSession.OpenReadStream(testSSRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aWriteStream, err := aSession.OpenWriteStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = aWriteStream.WriteRTP(&rtp.Header{SSRC: testSSRC}, append([]byte{}, testPayload...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = assertPayloadSRTP(t, bReadStream, rtpHeaderSize, testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionSRTPUpdateMasterKeys(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+	)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+	aSession, bSession := buildSessionSRTPPair(t)
+
+	bReadStream, err := bSession.OpenReadStream(testSSRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aWriteStream, err := aSession.OpenWriteStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := SessionKeys{
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+	}
+	for i := uint16(0); i < 4; i++ {
+		if i == 2 {
+			if err = aSession.UpdateMasterKeys(keys); err != nil {
+				t.Fatal(err)
+			}
+			if err = bSession.UpdateMasterKeys(keys); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err = aWriteStream.WriteRTP(&rtp.Header{SSRC: testSSRC, SequenceNumber: i}, append([]byte{}, testPayload...)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = assertPayloadSRTP(t, bReadStream, rtpHeaderSize, testPayload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Counters are kept across the key update.
+	local, _ := aSession.Stats()
+	_, remote := bSession.Stats()
+	if local.SRTP[testSSRC].Packets != 4 || remote.SRTP[testSSRC].Packets != 4 {
+		t.Errorf("Expected 4 packets written and read, got %d and %d", local.SRTP[testSSRC].Packets, remote.SRTP[testSSRC].Packets)
+	}
+
+	badKeys := keys
+	badKeys.RemoteMasterSalt = badKeys.RemoteMasterSalt[1:]
+	if err = aSession.UpdateMasterKeys(badKeys); !errors.Is(err, errShortSrtpMasterSalt) {
+		t.Errorf("Expected error '%v', got '%v'", errShortSrtpMasterSalt, err)
+	}
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = aSession.UpdateMasterKeys(keys); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+}
+
+func TestSessionSRTPPreKeyBuffer(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+	)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+	keys := SessionKeys{
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+	}
+
+	for name, tc := range map[string]struct {
+		ttl          time.Duration
+		expectedSeqs []uint16
+	}{
+		"Replayed": {expectedSeqs: []uint16{2, 3, 4, 5}},
+		"Expired":  {ttl: time.Millisecond, expectedSeqs: []uint16{5}},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			aPipe, bPipe := net.Pipe()
+			sender, err := CreateContext(keys.LocalMasterKey, keys.LocalMasterSalt, ProtectionProfileAes128CmHmacSha1_80)
+			assert.NoError(err)
+			session, err := NewSessionSRTP(bPipe, &Config{
+				Profile:          ProtectionProfileAes128CmHmacSha1_80,
+				PreKeyBufferSize: 3,
+				PreKeyBufferTTL:  tc.ttl,
+			})
+			assert.NoError(err)
+			readStream, err := session.OpenReadStream(testSSRC)
+			assert.NoError(err)
+
+			send := func(seq uint16) {
+				encrypted, errEnc := encryptSRTP(sender, &rtp.Packet{
+					Header:  rtp.Header{Version: 2, SSRC: testSSRC, SequenceNumber: seq},
+					Payload: testPayload,
+				})
+				assert.NoError(errEnc)
+				_, errWrite := aPipe.Write(encrypted)
+				assert.NoError(errWrite)
+			}
+
+			// The oldest packets are dropped when the buffer is full.
+			for seq := uint16(0); seq < 5; seq++ {
+				send(seq)
+			}
+			for queued := 0; queued != 3; time.Sleep(time.Millisecond) {
+				session.preKeyLock.Lock()
+				queued = len(session.preKeyPackets)
+				session.preKeyLock.Unlock()
+			}
+
+			writeStream, err := session.OpenWriteStream()
+			assert.NoError(err)
+			_, err = writeStream.WriteRTP(&rtp.Header{SSRC: 1}, testPayload)
+			assert.ErrorIs(err, errKeysNotSet)
+			assert.ErrorIs(session.UpdateMasterKeys(keys), errKeysNotSet)
+
+			if tc.ttl != 0 {
+				time.Sleep(2 * tc.ttl)
+			}
+			assert.NoError(session.SetKeys(keys))
+			assert.ErrorIs(session.SetKeys(keys), errKeysAlreadySet)
+
+			// Packets received after SetKeys follow the queued ones.
+			send(5)
+			for _, expected := range tc.expectedSeqs {
+				seq, errRead := assertPayloadSRTP(t, readStream, rtpHeaderSize, testPayload)
+				assert.NoError(errRead)
+				assert.Equal(expected, seq)
+			}
+
+			assert.NoError(aPipe.Close())
+			assert.NoError(session.Close())
+		})
+	}
+
+	// Keys can not be set once the session is closed.
+	_, bPipe := net.Pipe()
+	session, err := NewSessionSRTP(bPipe, &Config{Profile: ProtectionProfileAes128CmHmacSha1_80, PreKeyBufferSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = session.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = session.SetKeys(keys); !errors.Is(err, errSessionClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errSessionClosed, err)
+	}
+}
+
+// TestSessionSRTPPreKeyBufferSetKeysRace is meant to be run with -race, the
+// keys are set and updated while the queued packets are being decrypted.
+func TestSessionSRTPPreKeyBufferSetKeysRace(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+		queued        = 64
+	)
+	assert := assert.New(t)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+	keys := SessionKeys{
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+		bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 14),
+	}
+
+	aPipe, bPipe := net.Pipe()
+	sender, err := CreateContext(keys.LocalMasterKey, keys.LocalMasterSalt, ProtectionProfileAes128CmHmacSha1_80)
+	assert.NoError(err)
+	session, err := NewSessionSRTP(bPipe, &Config{
+		Profile:          ProtectionProfileAes128CmHmacSha1_80,
+		PreKeyBufferSize: queued,
+	})
+	assert.NoError(err)
+	readStream, err := session.OpenReadStream(testSSRC)
+	assert.NoError(err)
+
+	for seq := uint16(0); seq < queued; seq++ {
+		encrypted, errEnc := encryptSRTP(sender, &rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: testSSRC, SequenceNumber: seq},
+			Payload: testPayload,
+		})
+		assert.NoError(errEnc)
+		_, err = aPipe.Write(encrypted)
+		assert.NoError(err)
+	}
+	for n := 0; n != queued; time.Sleep(time.Millisecond) {
+		session.preKeyLock.Lock()
+		n = len(session.preKeyPackets)
+		session.preKeyLock.Unlock()
+	}
+
+	// Only one of the concurrent calls installs the keys, the others replace
+	// them while the queued packets are decrypted.
+	errs := make(chan error, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- session.SetKeys(keys)
+			assert.NoError(session.UpdateMasterKeys(keys))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	set := 0
+	for err := range errs {
+		if err == nil {
+			set++
+		} else {
+			assert.ErrorIs(err, errKeysAlreadySet)
+		}
+	}
+	assert.Equal(1, set)
+
+	for expected := uint16(0); expected < queued; expected++ {
+		seq, errRead := assertPayloadSRTP(t, readStream, rtpHeaderSize, testPayload)
+		assert.NoError(errRead)
+		assert.Equal(expected, seq)
+	}
+
+	assert.NoError(aPipe.Close())
+	assert.NoError(session.Close())
+}
+
+func TestSessionSRTPAssociateRTX(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		primarySSRC = 5000
+		rtxSSRC     = 5001
+	)
+	aSession, bSession := buildSessionSRTPPair(t)
+	bSession.AssociateRTX(rtxSSRC, primarySSRC, map[uint8]uint8{97: 96})
+
+	readStream, err := bSession.OpenReadStream(primarySSRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStream, err := aSession.OpenWriteStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pkt := range []struct {
+		header  rtp.Header
+		payload []byte
+	}{
+		{rtp.Header{Version: 2, SSRC: primarySSRC, PayloadType: 96, SequenceNumber: 10}, []byte{0xaa, 0xbb}},
+		// A padding-only probe is dropped.
+		{rtp.Header{Version: 2, SSRC: rtxSSRC, PayloadType: 97, SequenceNumber: 1, Padding: true}, []byte{0x00, 0x00, 0x03}},
+		// The retransmission of the first packet.
+		{rtp.Header{Version: 2, SSRC: rtxSSRC, PayloadType: 97, SequenceNumber: 2}, []byte{0x00, 0x0a, 0xaa, 0xbb}},
+	} {
+		if _, err = writeStream.WriteRTP(&pkt.header, pkt.payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readBuffer := make([]byte, 1500)
+	for i := 0; i < 2; i++ {
+		n, header, errRead := readStream.ReadRTP(readBuffer)
+		if errRead != nil {
+			t.Fatal(errRead)
+		}
+		if header.SSRC != primarySSRC || header.PayloadType != 96 || header.SequenceNumber != 10 {
+			t.Errorf("Expected the original header, got %v", header)
+		}
+		if !bytes.Equal([]byte{0xaa, 0xbb}, readBuffer[12:n]) {
+			t.Errorf("Expected the original payload, got %v", readBuffer[12:n])
+		}
+	}
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionSRTPOutboundStream(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	assert := assert.New(t)
+	aSession, bSession := buildSessionSRTPPair(t)
+
+	outbound, err := aSession.OpenOutboundStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstSequenceNumber := outbound.SequenceNumber()
+	_, ok := outbound.ROC()
+	assert.False(ok)
+
+	// The SSRC and the sequence numbers are set by the stream.
+	readStream, err := bSession.OpenReadStream(outbound.GetSSRC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = outbound.WriteRTP(&rtp.Header{Version: 2, SSRC: 1, SequenceNumber: 1}, []byte{0x01}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readBuffer := make([]byte, 1500)
+	for i := uint16(0); i < 2; i++ {
+		_, header, errRead := readStream.ReadRTP(readBuffer)
+		if errRead != nil {
+			t.Fatal(errRead)
+		}
+		assert.Equal(outbound.GetSSRC(), header.SSRC)
+		assert.Equal(firstSequenceNumber+i, header.SequenceNumber)
+	}
+	assert.Equal(firstSequenceNumber+2, outbound.SequenceNumber())
+	roc, ok := outbound.ROC()
+	assert.True(ok)
+	assert.Equal(firstSequenceNumber == 0xffff, roc == 1)
+
+	// The SSRC is taken until the stream is closed, and the SSRC of a
+	// received stream can not be used.
+	_, err = aSession.OpenOutboundStreamWithSSRC(outbound.GetSSRC(), 0)
+	assert.ErrorIs(err, errSSRCInUse)
+	_, err = bSession.OpenOutboundStreamWithSSRC(outbound.GetSSRC(), 0)
+	assert.ErrorIs(err, errSSRCInUse)
+	assert.NoError(outbound.Close())
+	_, err = outbound.WriteRTP(&rtp.Header{Version: 2}, []byte{0x01})
+	assert.ErrorIs(err, errStreamAlreadyClosed)
+
+	// A stream of the remote side using the SSRC is a collision.
+	const collidingSSRC = 5000
+	colliding, err := bSession.OpenOutboundStreamWithSSRC(collidingSSRC, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := aSession.OpenOutboundStreamWithSSRC(collidingSSRC, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readStream, err = bSession.OpenReadStream(collidingSSRC); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(colliding.Collided())
+	if _, err = remote.WriteRTP(&rtp.Header{Version: 2}, []byte{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = readStream.ReadRTP(readBuffer); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(colliding.Collided())
+	assert.False(remote.Collided())
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionSRTPAddEKTKey(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		testSSRC      = 5000
+		rtpHeaderSize = 12
+	)
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+	aSession, bPipe, config := buildSessionSRTP(t)
+
+	ektKey := EKTKey{SPI: 1, Key: bytes.Repeat([]byte{0x03}, 16), MasterSalt: config.Keys.RemoteMasterSalt}
+	if err := aSession.AddEKTKey(ektKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sender uses its own master key, which is only known through EKT.
+	sender, err := CreateContext(bytes.Repeat([]byte{0x04}, 16), ektKey.MasterSalt, config.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sender.AddEKTKey(ektKey); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, SSRC: testSSRC, SequenceNumber: 1},
+		Payload: testPayload,
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := sender.EncryptRTP(nil, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted, err = sender.AppendEKTField(encrypted, testSSRC, ektKey.SPI, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bPipe.Write(encrypted); err != nil {
+		t.Fatal(err)
+	}
+
+	readStream, ssrc, err := aSession.AcceptStream()
+	if err != nil {
+		t.Fatal(err)
+	} else if ssrc != testSSRC {
+		t.Fatalf("SSRC mismatch during accept exp(%v) actual(%v)", testSSRC, ssrc)
+	}
+	if _, err = assertPayloadSRTP(t, readStream, rtpHeaderSize, testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = aSession.AddEKTKey(ektKey); !errors.Is(err, errContextClosed) {
+		t.Errorf("Expected error '%v', got '%v'", errContextClosed, err)
+	}
+}
+
+func TestSessionSRTPConcurrentWrite(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		rtpHeaderSize = 12
+		packetCount   = 100
+	)
+	ssrcs := []uint32{5000, 5001, 5002, 5003}
+	testPayload := []byte{0x00, 0x01, 0x03, 0x04}
+	aSession, bSession := buildSessionSRTPPair(t)
+
+	// Each session encrypts on several goroutines while its read loop
+	// decrypts the packets sent by the other session.
+	var wg sync.WaitGroup
+	for _, session := range []*SessionSRTP{aSession, bSession} {
+		readStreams := make(map[uint32]*ReadStreamSRTP)
+		for _, ssrc := range ssrcs {
+			readStream, err := session.OpenReadStream(ssrc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readStreams[ssrc] = readStream
+		}
+
+		writeStream, err := session.OpenWriteStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ssrc := range ssrcs {
+			wg.Add(2)
+			go func(ssrc uint32) {
+				defer wg.Done()
+				for i := 0; i < packetCount; i++ {
+					header := &rtp.Header{SSRC: ssrc, SequenceNumber: uint16(i)}
+					if _, err := writeStream.WriteRTP(header, append([]byte{}, testPayload...)); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(ssrc)
+			go func(readStream *ReadStreamSRTP) {
+				defer wg.Done()
+				for i := 0; i < packetCount; i++ {
+					seq, err := assertPayloadSRTP(t, readStream, rtpHeaderSize, testPayload)
+					if err != nil {
+						return
+					}
+					if seq != uint16(i) {
+						t.Errorf("Expected sequence number %d, got %d", i, seq)
+					}
+				}
+			}(readStreams[ssrc])
+		}
+	}
+	wg.Wait()
+
+	if err := aSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bSession.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSessionSRTPReplayProtection(t *testing.T) {
 	lim := test.TimeOut(time.Second * 5)
 	defer lim.Stop()
@@ -5,6 +5,7 @@ package srtp
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/pion/rtcp"
@@ -13,39 +14,91 @@ import (
 const maxSRTCPIndex = 0x7FFFFFFF
 
 func (c *Context) decryptRTCP(dst, encrypted []byte) ([]byte, error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+
 	out := allocateIfMismatch(dst, encrypted)
 
-	authTagLen, err := c.cipher.rtcpAuthTagLen()
+	authTagLen, err := c.keys.cipher.rtcpAuthTagLen()
 	if err != nil {
 		return nil, err
 	}
-	aeadAuthTagLen, err := c.cipher.aeadAuthTagLen()
+	aeadAuthTagLen, err := c.keys.cipher.aeadAuthTagLen()
 	if err != nil {
 		return nil, err
 	}
-	tailOffset := len(encrypted) - (authTagLen + srtcpIndexSize)
+	tailOffset := len(encrypted) - (authTagLen + len(c.sendMKI) + srtcpIndexSize)
 
 	if tailOffset < aeadAuthTagLen {
-		return nil, fmt.Errorf("%w: %d", errTooShortRTCP, len(encrypted))
-	} else if isEncrypted := encrypted[tailOffset] >> 7; isEncrypted == 0 {
-		return out, nil
+		return nil, fmt.Errorf("%w: %d", ErrTooShortRTCP, len(encrypted))
+	}
+	if c.strictValidation {
+		if err = validateRTCP(encrypted, tailOffset-aeadAuthTagLen); err != nil {
+			return nil, err
+		}
 	}
 
-	index := c.cipher.getRTCPIndex(encrypted)
+	keys, err := c.decryptionKeys(encrypted, authTagLen)
+	if err != nil {
+		return nil, err
+	}
+
+	index := keys.cipher.getRTCPIndex(encrypted)
 	ssrc := binary.BigEndian.Uint32(encrypted[4:])
+	if s, ok := c.srtpSSRCStates[ssrc]; ok && s.ektKeys != nil {
+		keys = s.ektKeys
+	}
 
+	if _, known := c.srtcpSSRCStates[ssrc]; !known && c.events != nil {
+		c.events.OnUnknownSSRC(PacketInfo{RTCP: true, SSRC: ssrc, Index: uint64(index)})
+	}
 	s := c.getSRTCPSSRCState(ssrc)
 	markAsValid, ok := s.replayDetector.Check(uint64(index))
 	if !ok {
+		s.stats.ReplayDrops++
+		if c.events != nil {
+			c.events.OnReplay(PacketInfo{RTCP: true, SSRC: ssrc, Index: uint64(index)})
+		}
 		return nil, &duplicatedError{Proto: "srtcp", SSRC: ssrc, Index: index}
 	}
 
-	out, err = c.cipher.decryptRTCP(out, encrypted, index, ssrc)
+	cipher, derived, err := c.deriveSessionCipher(&s.sessionKeys, keys, uint64(index))
 	if err != nil {
 		return nil, err
 	}
+	if derived {
+		// Only cached once the packet has been authenticated with it.
+		defer func() {
+			if s.sessionKeys.cipher != cipher {
+				cipher.zero()
+			}
+		}()
+	}
+
+	out, err = cipher.decryptRTCP(out, encrypted, index, ssrc)
+	if err != nil {
+		if errors.Is(err, ErrFailedToVerifyAuthTag) {
+			s.stats.AuthFailures++
+			if c.events != nil {
+				c.events.OnAuthFailure(PacketInfo{RTCP: true, SSRC: ssrc, Index: uint64(index)}, err)
+			}
+		}
+		return nil, err
+	}
 
 	markAsValid()
+	if s.replayWindow != nil {
+		s.replayWindow.accept(uint64(index))
+	}
+	if derived {
+		c.setSessionCipher(&s.sessionKeys, keys, uint64(index), cipher)
+	}
+	s.stats.Packets++
+	s.stats.Bytes += uint64(len(encrypted))
+	if index > s.receivedIndex {
+		s.receivedIndex = index
+	}
 	return out, nil
 }
 
@@ -63,6 +116,10 @@ func (c *Context) DecryptRTCP(dst, encrypted []byte, header *rtcp.Header) ([]byt
 }
 
 func (c *Context) encryptRTCP(dst, decrypted []byte) ([]byte, error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+
 	ssrc := binary.BigEndian.Uint32(decrypted[4:])
 	s := c.getSRTCPSSRCState(ssrc)
 
@@ -74,10 +131,24 @@ func (c *Context) encryptRTCP(dst, decrypted []byte) ([]byte, error) {
 		return nil, errExceededMaxPackets
 	}
 
+	cipher, err := c.sessionCipher(&s.sessionKeys, c.keys, uint64(s.srtcpIndex+1))
+	if err != nil {
+		return nil, err
+	}
+	if err = c.countProtectedPacket(true); err != nil {
+		return nil, err
+	}
+
 	// We roll over early because MSB is used for marking as encrypted
 	s.srtcpIndex++
 
-	return c.cipher.encryptRTCP(dst, decrypted, s.srtcpIndex, ssrc)
+	out, err := cipher.encryptRTCP(dst, decrypted, s.srtcpIndex, ssrc)
+	if err != nil {
+		return nil, err
+	}
+	s.stats.Packets++
+	s.stats.Bytes += uint64(len(out))
+	return out, nil
 }
 
 // EncryptRTCP Encrypts a RTCP packet
@@ -92,3 +163,18 @@ func (c *Context) EncryptRTCP(dst, decrypted []byte, header *rtcp.Header) ([]byt
 
 	return c.encryptRTCP(dst, decrypted)
 }
+
+// validateRTCP rejects the SRTCP packets whose first RTCP packet exceeds the
+// RTCP data, which ends at dataLen, for StrictValidation. The version has been
+// checked by the RTCP header unmarshaling.
+//
+// https://tools.ietf.org/html/rfc3550#section-6.4.1
+func validateRTCP(encrypted []byte, dataLen int) error {
+	if dataLen < 4 {
+		return fmt.Errorf("%w: %d", ErrTooShortRTCP, len(encrypted))
+	}
+	if n := 4 * (int(binary.BigEndian.Uint16(encrypted[2:])) + 1); n > dataLen {
+		return fmt.Errorf("%w: %d > %d", errInvalidRTCPLength, n, dataLen)
+	}
+	return nil
+}
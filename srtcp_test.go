@@ -300,14 +300,185 @@ func TestRTCPInvalidAuthTag(t *testing.T) {
 					copy(rtcpPacket[authTagPos:authTagPos+aeadAuthTagLen], make([]byte, aeadAuthTagLen))
 				}
 
-				if _, err = decryptContext.DecryptRTCP(nil, rtcpPacket, nil); err == nil {
-					t.Errorf("Was able to decrypt RTCP packet with invalid Auth Tag")
+				if _, err = decryptContext.DecryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, ErrFailedToVerifyAuthTag) {
+					t.Errorf("Expected error '%v' for RTCP packet with invalid Auth Tag, got '%v'", ErrFailedToVerifyAuthTag, err)
 				}
 			}
 		})
 	}
 }
 
+// unencryptedSRTCP builds an SRTCP packet with the E flag cleared
+// using the session keys of the given context.
+func unencryptedSRTCP(t *testing.T, c *Context, decrypted []byte, index uint32) []byte {
+	t.Helper()
+
+	ssrc := binary.BigEndian.Uint32(decrypted[4:])
+	esrtcpWord := make([]byte, srtcpIndexSize)
+	binary.BigEndian.PutUint32(esrtcpWord, index)
+
+	switch cipher := c.keys.cipher.(type) {
+	case *srtpCipherAesCmHmacSha1:
+		out := append(append([]byte{}, decrypted...), esrtcpWord...)
+		authTag, err := cipher.generateSrtcpAuthTag(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(out, authTag...)
+	case *srtpCipherAeadAesGcm:
+		iv := cipher.rtcpInitializationVector(index, ssrc)
+		aad := append(append([]byte{}, decrypted...), esrtcpWord...)
+		out := cipher.srtcpCipher.Seal(append([]byte{}, decrypted...), iv[:], nil, aad)
+		return append(out, esrtcpWord...)
+	default:
+		t.Fatalf("unknown cipher %T", c.keys.cipher)
+		return nil
+	}
+}
+
+func TestRTCPUnencrypted(t *testing.T) {
+	for caseName, testCase := range rtcpTestCases() {
+		testCase := testCase
+		t.Run(caseName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			decryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, pkt := range testCase.packets {
+				rtcpPacket := unencryptedSRTCP(t, decryptContext, pkt.decrypted, uint32(i+1))
+
+				decrypted, err := decryptContext.DecryptRTCP(nil, rtcpPacket, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(pkt.decrypted, decrypted, "RTCP payload must be passed through unchanged")
+
+				// The payload of an unencrypted packet is still authenticated.
+				rtcpPacket[8] ^= 0xff
+				if _, err = decryptContext.DecryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, ErrFailedToVerifyAuthTag) {
+					t.Errorf("Expected error '%v' for tampered unencrypted RTCP packet, got '%v'", ErrFailedToVerifyAuthTag, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRTCPNullHmacSha1(t *testing.T) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(ProtectionProfileNullHmacSha1_80)
+	assert.NoError(err)
+	decryptContext, err := buildTestContext(ProtectionProfileNullHmacSha1_80)
+	assert.NoError(err)
+
+	decrypted := []byte{0x81, 0xc8, 0x00, 0x02, 0xca, 0xfe, 0xba, 0xbe, 0xab, 0xab, 0xab, 0xab}
+	encrypted, err := encryptContext.EncryptRTCP(nil, decrypted, nil)
+	assert.NoError(err)
+
+	// The payload is sent in the clear and the E flag is not set.
+	assert.Equal(decrypted, encrypted[:len(decrypted)])
+	assert.Equal([]byte{0x00, 0x00, 0x00, 0x01}, encrypted[len(decrypted):len(decrypted)+srtcpIndexSize])
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(decrypted)-1] ^= 0xff
+	_, err = decryptContext.DecryptRTCP(nil, tampered, nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+
+	out, err := decryptContext.DecryptRTCP(nil, encrypted, nil)
+	assert.NoError(err)
+	assert.Equal(decrypted, out)
+}
+
+func TestRTCPKeyDerivationRate(t *testing.T) {
+	for caseName, testCase := range rtcpTestCases() {
+		testCase := testCase
+		t.Run(caseName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Derive new session keys every 4 packets
+			encryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo, KeyDerivationRate(2))
+			assert.NoError(err)
+
+			decryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo, KeyDerivationRate(2))
+			assert.NoError(err)
+
+			noKDRContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			assert.NoError(err)
+
+			pkt := testCase.packets[0]
+			for i := 1; i <= 16; i++ {
+				encrypted, err := encryptContext.EncryptRTCP(nil, pkt.decrypted, nil)
+				assert.NoError(err)
+
+				decrypted, err := decryptContext.DecryptRTCP(nil, encrypted, nil)
+				assert.NoError(err)
+				assert.Equal(pkt.decrypted, decrypted)
+
+				// SRTCP index starts from 1, only the first 3 packets use the initial session keys.
+				_, err = noKDRContext.DecryptRTCP(nil, encrypted, nil)
+				if i < 4 {
+					assert.NoError(err, "index=%d", i)
+				} else {
+					assert.ErrorIs(err, ErrFailedToVerifyAuthTag, "index=%d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRTCPMKI(t *testing.T) {
+	mki1, mki2 := []byte{0x01, 0x02, 0x03, 0x04}, []byte{0x02, 0x03, 0x04, 0x05}
+
+	for caseName, testCase := range rtcpTestCases() {
+		testCase := testCase
+		t.Run(caseName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			masterKey2 := make([]byte, len(testCase.masterKey))
+			masterSalt2 := make([]byte, len(testCase.masterSalt))
+
+			encryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo, MasterKeyIndicator(mki1))
+			assert.NoError(err)
+			assert.NoError(encryptContext.AddCipherForMKI(mki2, masterKey2, masterSalt2))
+
+			decryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo, MasterKeyIndicator(mki1))
+			assert.NoError(err)
+			assert.NoError(decryptContext.AddCipherForMKI(mki2, masterKey2, masterSalt2))
+
+			authTagLen, err := encryptContext.keys.cipher.rtcpAuthTagLen()
+			assert.NoError(err)
+
+			pkt := testCase.packets[0]
+			for _, mki := range [][]byte{mki1, mki2, mki1} {
+				assert.NoError(encryptContext.SetSendMKI(mki))
+
+				encrypted, err := encryptContext.EncryptRTCP(nil, pkt.decrypted, nil)
+				assert.NoError(err)
+
+				// The MKI follows the ESRTCP word
+				mkiOffset := len(encrypted) - authTagLen - len(mki)
+				assert.Equal(mki, encrypted[mkiOffset:mkiOffset+len(mki)])
+				assert.Equal(byte(rtcpEncryptionFlag), encrypted[mkiOffset-srtcpIndexSize]&rtcpEncryptionFlag)
+
+				decrypted, err := decryptContext.DecryptRTCP(nil, encrypted, nil)
+				assert.NoError(err)
+				assert.Equal(pkt.decrypted, decrypted)
+			}
+
+			// Packets referencing an unknown MKI are rejected
+			assert.NoError(decryptContext.RemoveMKI(mki2))
+			assert.NoError(encryptContext.SetSendMKI(mki2))
+			encrypted, err := encryptContext.EncryptRTCP(nil, pkt.decrypted, nil)
+			assert.NoError(err)
+			_, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+			assert.ErrorIs(err, ErrMKINotFound)
+		})
+	}
+}
+
 func TestRTCPReplayDetectorSeparation(t *testing.T) {
 	for caseName, testCase := range rtcpTestCases() {
 		testCase := testCase
@@ -332,7 +503,7 @@ func TestRTCPReplayDetectorSeparation(t *testing.T) {
 
 			for i, pkt := range testCase.packets {
 				rtcpPacket := append([]byte{}, pkt.encrypted...)
-				if _, err = decryptContext.DecryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, errDuplicated) {
+				if _, err = decryptContext.DecryptRTCP(nil, rtcpPacket, nil); !errors.Is(err, ErrDuplicated) {
 					t.Error("Was able to decrypt duplicated RTCP packet", i)
 				}
 			}
@@ -560,8 +731,8 @@ func TestRTCPMaxPackets(t *testing.T) {
 
 			// Next packet will exceeds the maximum packet count
 			_, err = decryptContext.DecryptRTCP(nil, testCase.packets[1].encrypted, nil)
-			if !errors.Is(err, errDuplicated) {
-				t.Errorf("Expected error: '%v', got: '%v'", errDuplicated, err)
+			if !errors.Is(err, ErrDuplicated) {
+				t.Errorf("Expected error: '%v', got: '%v'", ErrDuplicated, err)
 			}
 
 			_, err = encryptContext.EncryptRTCP(nil, testCase.packets[1].decrypted, nil)
@@ -594,3 +765,78 @@ func TestRTCPReplayDetectorFactory(t *testing.T) {
 	}
 	assert.Equal(1, cntFactory)
 }
+
+func TestRTCPAllocs(t *testing.T) {
+	for caseName, testCase := range rtcpTestCases() {
+		testCase := testCase
+		t.Run(caseName, func(t *testing.T) {
+			encryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			assert.NoError(t, err)
+			decryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			assert.NoError(t, err)
+
+			header := &rtcp.Header{}
+			encrypted := make([]byte, 0, 1500)
+			decrypted := make([]byte, 0, 1500)
+			allocs := testing.AllocsPerRun(100, func() {
+				if encrypted, err = encryptContext.EncryptRTCP(encrypted[:0], testCase.packets[0].decrypted, header); err != nil {
+					t.Fatal(err)
+				}
+				if decrypted, err = decryptContext.DecryptRTCP(decrypted[:0], encrypted, header); err != nil {
+					t.Fatal(err)
+				}
+			})
+			assert.Equal(t, testCase.packets[0].decrypted, decrypted)
+			assert.Zero(t, allocs, "EncryptRTCP and DecryptRTCP must not allocate")
+		})
+	}
+}
+
+func TestRTCPCompoundPacket(t *testing.T) {
+	compound := rtcp.CompoundPacket{
+		&rtcp.SenderReport{
+			SSRC:        0xcafebabe,
+			NTPTime:     0x0102030405060708,
+			RTPTime:     0x11223344,
+			PacketCount: 10,
+			OctetCount:  1000,
+		},
+		&rtcp.SourceDescription{
+			Chunks: []rtcp.SourceDescriptionChunk{{
+				Source: 0xcafebabe,
+				Items:  []rtcp.SourceDescriptionItem{{Type: rtcp.SDESCNAME, Text: "srtp"}},
+			}},
+		},
+		&rtcp.Goodbye{Sources: []uint32{0xcafebabe}},
+	}
+	decrypted, err := compound.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for caseName, testCase := range rtcpTestCases() {
+		testCase := testCase
+		t.Run(caseName, func(t *testing.T) {
+			assert := assert.New(t)
+			encryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			assert.NoError(err)
+			decryptContext, err := CreateContext(testCase.masterKey, testCase.masterSalt, testCase.algo)
+			assert.NoError(err)
+
+			// The whole compound packet is protected as a single SRTCP packet,
+			// keyed on the SSRC of the first packet.
+			encrypted, err := encryptContext.EncryptRTCP(nil, decrypted, nil)
+			assert.NoError(err)
+			assert.Equal(decrypted[:8], encrypted[:8], "first header and SSRC must be in the clear")
+			assert.NotEqual(decrypted[8:], encrypted[8:len(decrypted)])
+
+			actual, err := decryptContext.DecryptRTCP(nil, encrypted, nil)
+			assert.NoError(err)
+			assert.Equal(decrypted, actual)
+
+			pkts, err := rtcp.Unmarshal(actual)
+			assert.NoError(err)
+			assert.Len(pkts, 3)
+		})
+	}
+}
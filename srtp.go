@@ -5,45 +5,169 @@
 package srtp
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/pion/rtp"
 )
 
 func (c *Context) decryptRTP(dst, ciphertext []byte, header *rtp.Header, headerLen int) ([]byte, error) {
+	dst, _, err := c.decryptRTPWithIndex(dst, ciphertext, header, headerLen)
+	return dst, err
+}
+
+func (c *Context) decryptRTPWithIndex(dst, ciphertext []byte, header *rtp.Header, headerLen int) ([]byte, uint64, error) {
+	if c.closed {
+		return nil, 0, errContextClosed
+	}
+	if c.strictValidation {
+		if err := c.validateRTP(ciphertext, header, headerLen); err != nil {
+			return nil, 0, err
+		}
+	}
+	_, known := c.srtpSSRCStates[header.SSRC]
+
+	var ekt *ektField
+	if c.ektKeys != nil {
+		s := c.getSRTPSSRCState(header.SSRC)
+		var err error
+		if ciphertext, ekt, err = c.processEKTField(ciphertext, s); err != nil {
+			return nil, 0, err
+		}
+		if ekt != nil {
+			// Only kept once a packet has been authenticated with it.
+			defer func() {
+				if s.ektKeys != ekt.keys {
+					ekt.keys.zero()
+				}
+			}()
+		}
+	}
+
+	authTagLen, err := c.keys.cipher.rtpAuthTagLen()
+	if err != nil {
+		return nil, 0, err
+	}
+	aeadAuthTagLen, err := c.keys.cipher.aeadAuthTagLen()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ciphertext) < headerLen+len(c.sendMKI)+authTagLen+aeadAuthTagLen {
+		return nil, 0, fmt.Errorf("%w: %d", ErrTooShortRTP, len(ciphertext))
+	}
+
+	keys, err := c.decryptionKeys(ciphertext, authTagLen)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	s := c.getSRTPSSRCState(header.SSRC)
+	if ekt != nil {
+		keys = ekt.keys
+	} else if s.ektKeys != nil {
+		keys = s.ektKeys
+	}
 
-	roc, diff, _ := s.nextRolloverCount(header.SequenceNumber)
-	markAsValid, ok := s.replayDetector.Check(
-		(uint64(roc) << 16) | uint64(header.SequenceNumber),
-	)
+	roc, diff, _, err := c.rolloverCount(s, header.SequenceNumber)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ekt != nil && !s.rolloverHasProcessed {
+		// The first packet uses the ROC of the EKT field, which is then only
+		// recorded by updateRolloverCount once the packet is authenticated.
+		roc = ekt.roc
+	}
+	index := (uint64(roc) << 16) | uint64(header.SequenceNumber)
+	if !known && c.events != nil {
+		c.events.OnUnknownSSRC(PacketInfo{SSRC: header.SSRC, Index: index})
+	}
+	markAsValid, ok := s.replayDetector.Check(index)
 	if !ok {
-		return nil, &duplicatedError{
+		s.stats.ReplayDrops++
+		if c.events != nil {
+			c.events.OnReplay(PacketInfo{SSRC: header.SSRC, Index: index})
+		}
+		return nil, 0, &duplicatedError{
 			Proto: "srtp", SSRC: header.SSRC, Index: uint32(header.SequenceNumber),
 		}
 	}
 
-	authTagLen, err := c.cipher.rtpAuthTagLen()
+	cipher, derived, err := c.deriveSessionCipher(&s.sessionKeys, keys, index)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if derived {
+		// Only cached once the packet has been authenticated with it.
+		defer func() {
+			if s.sessionKeys.cipher != cipher {
+				cipher.zero()
+			}
+		}()
 	}
-	dst = growBufferSize(dst, len(ciphertext)-authTagLen)
 
-	dst, err = c.cipher.decryptRTP(dst, ciphertext, header, headerLen, roc)
+	dst = growBufferSize(dst, len(ciphertext)-len(c.sendMKI)-authTagLen)
+
+	dst, err = cipher.decryptRTP(dst, ciphertext, header, headerLen, roc)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, ErrFailedToVerifyAuthTag) {
+			s.stats.AuthFailures++
+			if c.events != nil {
+				c.events.OnAuthFailure(PacketInfo{SSRC: header.SSRC, Index: index}, err)
+			}
+		}
+		return nil, 0, err
+	}
+
+	// Refresh the header so that it holds the decrypted header extensions.
+	if len(c.encryptedHeaderExtensionIDs) != 0 || c.cryptex {
+		if _, err = header.Unmarshal(dst); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The padding count is encrypted, so it can only be checked now. The
+	// padding is left in place for the RTP parser of the caller.
+	if header.Padding {
+		if _, err = rtpPaddingLen(dst[headerLen:]); err != nil {
+			return nil, 0, err
+		}
 	}
 
 	markAsValid()
-	s.updateRolloverCount(header.SequenceNumber, diff)
-	return dst, nil
+	if s.replayWindow != nil {
+		s.replayWindow.accept(index)
+	}
+	if derived {
+		c.setSessionCipher(&s.sessionKeys, keys, index, cipher)
+	}
+	if c.updateRolloverCount(s, header.SequenceNumber, roc, diff) && c.events != nil {
+		c.events.OnROCChange(header.SSRC, uint32(s.index>>16))
+	}
+	s.stats.Packets++
+	s.stats.Bytes += uint64(len(ciphertext))
+	if ekt != nil {
+		if s.ektKeys != nil {
+			s.ektKeys.zero()
+		}
+		s.ektKeys = ekt.keys
+		if s.ektEpochs == nil {
+			s.ektEpochs = map[uint16]uint16{}
+		}
+		s.ektEpochs[ekt.spi] = ekt.epoch
+	}
+	return dst, index, nil
 }
 
-// DecryptRTP decrypts a RTP packet with an encrypted payload
+// DecryptRTP decrypts a RTP packet with an encrypted payload.
+// The decrypted packet keeps the header as received, with its CSRCs, header
+// extensions and padding, and only the SRTP fields are removed. dst may be
+// encrypted[:0] to decrypt in place.
 func (c *Context) DecryptRTP(dst, encrypted []byte, header *rtp.Header) ([]byte, error) {
 	if header == nil {
 		header = &rtp.Header{}
 	}
 
-	headerLen, err := header.Unmarshal(encrypted)
+	headerLen, err := unmarshalRTPHeader(encrypted, header)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +175,108 @@ func (c *Context) DecryptRTP(dst, encrypted []byte, header *rtp.Header) ([]byte,
 	return c.decryptRTP(dst, encrypted, header, headerLen)
 }
 
+// DecryptRTPPayload decrypts a RTP packet like DecryptRTP, but only returns
+// its payload, without the RTP padding, written at the start of dst. The
+// decrypted header is returned in header if it is not nil.
+func (c *Context) DecryptRTPPayload(dst, encrypted []byte, header *rtp.Header) ([]byte, error) {
+	if header == nil {
+		header = &rtp.Header{}
+	}
+
+	headerLen, err := unmarshalRTPHeader(encrypted, header)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := c.decryptRTP(dst, encrypted, header, headerLen)
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(decrypted)
+	if header.Padding {
+		paddingLen, _ := rtpPaddingLen(decrypted[headerLen:]) // checked by decryptRTP
+		end -= paddingLen
+	}
+	return decrypted[:copy(decrypted, decrypted[headerLen:end])], nil
+}
+
+// DecryptRTPWithIndex decrypts a RTP packet like DecryptRTP, and also returns
+// the 48-bit SRTP packet index (2^16 * ROC + SEQ) used to decrypt it.
+func (c *Context) DecryptRTPWithIndex(dst, encrypted []byte, header *rtp.Header) ([]byte, uint64, error) {
+	if header == nil {
+		header = &rtp.Header{}
+	}
+
+	headerLen, err := unmarshalRTPHeader(encrypted, header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return c.decryptRTPWithIndex(dst, encrypted, header, headerLen)
+}
+
+// DecryptRTPBatch decrypts a batch of RTP packets, e.g. received in a single
+// datagram. The result is the same as calling DecryptRTP on each packet in
+// order, but the decrypted packets share a single buffer.
+// errs has one entry per packet, decrypted[i] is nil when errs[i] is not nil.
+func (c *Context) DecryptRTPBatch(encrypted [][]byte) (decrypted [][]byte, errs []error) {
+	size := 0
+	for _, pkt := range encrypted {
+		size += len(pkt)
+	}
+	buf := make([]byte, size)
+
+	decrypted = make([][]byte, len(encrypted))
+	errs = make([]error, len(encrypted))
+	header := &rtp.Header{}
+	for i, pkt := range encrypted {
+		dst := buf[:0:len(pkt)]
+		buf = buf[len(pkt):]
+
+		headerLen, err := unmarshalRTPHeader(pkt, header)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		decrypted[i], errs[i] = c.decryptRTP(dst, pkt, header, headerLen)
+	}
+	return decrypted, errs
+}
+
+// EncryptRTPBatch encrypts a batch of RTP packets, e.g. to be sent with a
+// single sendmmsg. The result is the same as calling EncryptRTP on each packet
+// in order, but the encrypted packets share a single buffer.
+// errs has one entry per packet, encrypted[i] is nil when errs[i] is not nil.
+func (c *Context) EncryptRTPBatch(plaintexts [][]byte) (encrypted [][]byte, errs []error) {
+	// Room for the MKI, the longest authentication tag, the extension header
+	// added by cryptex and the padding.
+	overhead := len(c.sendMKI) + 16 + 4 + int(c.rtpPaddingBlockSize)
+
+	size := 0
+	for _, pkt := range plaintexts {
+		size += len(pkt) + overhead
+	}
+	buf := make([]byte, size)
+
+	encrypted = make([][]byte, len(plaintexts))
+	errs = make([]error, len(plaintexts))
+	header := &rtp.Header{}
+	for i, pkt := range plaintexts {
+		n := len(pkt) + overhead
+		dst := buf[:0:n]
+		buf = buf[n:]
+
+		headerLen, err := unmarshalRTPHeader(pkt, header)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		encrypted[i], errs[i] = c.encryptRTP(dst, header, pkt[headerLen:])
+	}
+	return encrypted, errs
+}
+
 // EncryptRTP marshals and encrypts an RTP packet, writing to the dst buffer provided.
 // If the dst buffer does not have the capacity to hold `len(plaintext) + 10` bytes, a new one will be allocated and returned.
 // If a rtp.Header is provided, it will be Unmarshaled using the plaintext.
@@ -59,7 +285,7 @@ func (c *Context) EncryptRTP(dst []byte, plaintext []byte, header *rtp.Header) (
 		header = &rtp.Header{}
 	}
 
-	headerLen, err := header.Unmarshal(plaintext)
+	headerLen, err := unmarshalRTPHeader(plaintext, header)
 	if err != nil {
 		return nil, err
 	}
@@ -71,8 +297,18 @@ func (c *Context) EncryptRTP(dst []byte, plaintext []byte, header *rtp.Header) (
 // If the dst buffer does not have the capacity, a new one will be allocated and returned.
 // Similar to above but faster because it can avoid unmarshaling the header and marshaling the payload.
 func (c *Context) encryptRTP(dst []byte, header *rtp.Header, payload []byte) (ciphertext []byte, err error) {
+	if c.closed {
+		return nil, errContextClosed
+	}
+	if c.rtpPaddingBlockSize > 1 {
+		header, payload = padRTP(header, payload, int(c.rtpPaddingBlockSize))
+	}
+
 	s := c.getSRTPSSRCState(header.SSRC)
-	roc, diff, ovf := s.nextRolloverCount(header.SequenceNumber)
+	roc, diff, ovf, err := c.rolloverCount(s, header.SequenceNumber)
+	if err != nil {
+		return nil, err
+	}
 	if ovf {
 		// ... when 2^48 SRTP packets or 2^31 SRTCP packets have been secured with the same key
 		// (whichever occurs before), the key management MUST be called to provide new master key(s)
@@ -80,7 +316,22 @@ func (c *Context) encryptRTP(dst []byte, header *rtp.Header, payload []byte) (ci
 		// https://www.rfc-editor.org/rfc/rfc3711#section-9.2
 		return nil, errExceededMaxPackets
 	}
-	s.updateRolloverCount(header.SequenceNumber, diff)
 
-	return c.cipher.encryptRTP(dst, header, payload, roc)
+	cipher, err := c.sessionCipher(&s.sessionKeys, c.keys, (uint64(roc)<<16)|uint64(header.SequenceNumber))
+	if err != nil {
+		return nil, err
+	}
+	if err = c.countProtectedPacket(false); err != nil {
+		return nil, err
+	}
+	if c.updateRolloverCount(s, header.SequenceNumber, roc, diff) && c.events != nil {
+		c.events.OnROCChange(header.SSRC, uint32(s.index>>16))
+	}
+
+	if ciphertext, err = cipher.encryptRTP(dst, header, payload, roc); err != nil {
+		return nil, err
+	}
+	s.stats.Packets++
+	s.stats.Bytes += uint64(len(ciphertext))
+	return ciphertext, nil
 }
@@ -22,6 +22,15 @@ type srtpCipher interface {
 
 	decryptRTP([]byte, []byte, *rtp.Header, int, uint32) ([]byte, error)
 	decryptRTCP([]byte, []byte, uint32, uint32) ([]byte, error)
+
+	// zero overwrites the session salts and drops the session keys.
+	// The cipher must not be used afterwards.
+	zero()
+
+	// clone returns a cipher with the same session keys, which can be used
+	// and zeroed independently. The block ciphers and AEADs are shared, as
+	// they are safe for concurrent use.
+	clone() srtpCipher
 }
 
 /*
@@ -7,6 +7,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"fmt"
 
 	"github.com/pion/rtp"
 )
@@ -18,15 +19,25 @@ const (
 type srtpCipherAeadAesGcm struct {
 	ProtectionProfile
 
+	// Written after the ciphertext (and the ESRTCP word for SRTCP)
+	mki []byte
+
 	srtpCipher, srtcpCipher cipher.AEAD
 
 	srtpSessionSalt, srtcpSessionSalt []byte
+
+	// Set if the CSRCs and header extensions are encrypted (RFC 9335)
+	cryptex bool
+
+	// Scratch space reused for each packet
+	iv  [12]byte
+	aad [12]byte
 }
 
-func newSrtpCipherAeadAesGcm(profile ProtectionProfile, masterKey, masterSalt []byte) (*srtpCipherAeadAesGcm, error) {
-	s := &srtpCipherAeadAesGcm{ProtectionProfile: profile}
+func newSrtpCipherAeadAesGcm(profile ProtectionProfile, masterKey, masterSalt, mki []byte, indexOverKdr uint64) (*srtpCipherAeadAesGcm, error) {
+	s := &srtpCipherAeadAesGcm{ProtectionProfile: profile, mki: mki}
 
-	srtpSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, 0, len(masterKey))
+	srtpSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
 	if err != nil {
 		return nil, err
 	}
@@ -35,13 +46,14 @@ func newSrtpCipherAeadAesGcm(profile ProtectionProfile, masterKey, masterSalt []
 	if err != nil {
 		return nil, err
 	}
+	zeroBytes(srtpSessionKey)
 
 	s.srtpCipher, err = cipher.NewGCM(srtpBlock)
 	if err != nil {
 		return nil, err
 	}
 
-	srtcpSessionKey, err := aesCmKeyDerivation(labelSRTCPEncryption, masterKey, masterSalt, 0, len(masterKey))
+	srtcpSessionKey, err := aesCmKeyDerivation(labelSRTCPEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
 	if err != nil {
 		return nil, err
 	}
@@ -50,36 +62,82 @@ func newSrtpCipherAeadAesGcm(profile ProtectionProfile, masterKey, masterSalt []
 	if err != nil {
 		return nil, err
 	}
+	zeroBytes(srtcpSessionKey)
 
 	s.srtcpCipher, err = cipher.NewGCM(srtcpBlock)
 	if err != nil {
 		return nil, err
 	}
 
-	if s.srtpSessionSalt, err = aesCmKeyDerivation(labelSRTPSalt, masterKey, masterSalt, 0, len(masterSalt)); err != nil {
+	if s.srtpSessionSalt, err = aesCmKeyDerivation(labelSRTPSalt, masterKey, masterSalt, indexOverKdr, len(masterSalt)); err != nil {
 		return nil, err
-	} else if s.srtcpSessionSalt, err = aesCmKeyDerivation(labelSRTCPSalt, masterKey, masterSalt, 0, len(masterSalt)); err != nil {
+	} else if s.srtcpSessionSalt, err = aesCmKeyDerivation(labelSRTCPSalt, masterKey, masterSalt, indexOverKdr, len(masterSalt)); err != nil {
 		return nil, err
 	}
 
 	return s, nil
 }
 
+func (s *srtpCipherAeadAesGcm) zero() {
+	zeroBytes(s.srtpSessionSalt)
+	zeroBytes(s.srtcpSessionSalt)
+	s.srtpCipher, s.srtcpCipher = nil, nil
+}
+
+func (s *srtpCipherAeadAesGcm) clone() srtpCipher {
+	return &srtpCipherAeadAesGcm{
+		ProtectionProfile: s.ProtectionProfile,
+		mki:               s.mki,
+		srtpCipher:        s.srtpCipher,
+		srtcpCipher:       s.srtcpCipher,
+		srtpSessionSalt:   append([]byte{}, s.srtpSessionSalt...),
+		srtcpSessionSalt:  append([]byte{}, s.srtcpSessionSalt...),
+		cryptex:           s.cryptex,
+	}
+}
+
 func (s *srtpCipherAeadAesGcm) encryptRTP(dst []byte, header *rtp.Header, payload []byte, roc uint32) (ciphertext []byte, err error) {
 	// Grow the given buffer to fit the output.
 	authTagLen, err := s.aeadAuthTagLen()
 	if err != nil {
 		return nil, err
 	}
-	dst = growBufferSize(dst, header.MarshalSize()+len(payload)+authTagLen)
+	if s.cryptex && cryptexApplies(header) {
+		return s.encryptRTPCryptex(dst, header, payload, roc, authTagLen)
+	}
+	dst = growBufferSize(dst, header.MarshalSize()+len(payload)+authTagLen+len(s.mki))
 
 	n, err := header.MarshalTo(dst)
 	if err != nil {
 		return nil, err
 	}
 
-	iv := s.rtpInitializationVector(header, roc)
-	s.srtpCipher.Seal(dst[n:n], iv[:], payload, dst[:n])
+	s.iv = s.rtpInitializationVector(header, roc)
+	s.srtpCipher.Seal(dst[n:n], s.iv[:], payload, dst[:n])
+	copy(dst[n+len(payload)+authTagLen:], s.mki)
+	return dst, nil
+}
+
+// encryptRTPCryptex encrypts the CSRCs and the header extension data together
+// with the payload, as specified in RFC 9335. The AAD is the fixed header and
+// the extension header.
+func (s *srtpCipherAeadAesGcm) encryptRTPCryptex(dst []byte, header *rtp.Header, payload []byte, roc uint32, authTagLen int) ([]byte, error) {
+	dst = growBufferSize(dst, cryptexMarshalSize(header)+len(payload)+authTagLen+len(s.mki))
+
+	n, err := marshalCryptexHeader(dst, header)
+	if err != nil {
+		return nil, err
+	}
+	n += copy(dst[n:], payload)
+
+	moveCryptexExtensionHeader(dst, true)
+	s.iv = s.rtpInitializationVector(header, roc)
+	s.srtpCipher.Seal(
+		dst[cryptexEncryptedOffset:cryptexEncryptedOffset], s.iv[:], dst[cryptexEncryptedOffset:n], dst[:cryptexEncryptedOffset],
+	)
+	moveCryptexExtensionHeader(dst, false)
+
+	copy(dst[n+authTagLen:], s.mki)
 	return dst, nil
 }
 
@@ -89,19 +147,35 @@ func (s *srtpCipherAeadAesGcm) decryptRTP(dst, ciphertext []byte, header *rtp.He
 	if err != nil {
 		return nil, err
 	}
+	ciphertext = ciphertext[:len(ciphertext)-len(s.mki)]
 	nDst := len(ciphertext) - authTagLen
 	if nDst < 0 {
 		// Size of ciphertext is shorter than AEAD auth tag len.
-		return nil, errFailedToVerifyAuthTag
+		return nil, ErrFailedToVerifyAuthTag
 	}
 	dst = growBufferSize(dst, nDst)
 
-	iv := s.rtpInitializationVector(header, roc)
+	s.iv = s.rtpInitializationVector(header, roc)
+
+	if s.cryptex && isCryptexHeader(ciphertext[:headerLen]) {
+		// Decrypt in place, the buffer must hold the AEAD auth tag too.
+		dst = growBufferSize(dst, len(ciphertext))
+		copy(dst, ciphertext)
+		moveCryptexExtensionHeader(dst, true)
+		if _, err := s.srtpCipher.Open(
+			dst[cryptexEncryptedOffset:cryptexEncryptedOffset], s.iv[:], dst[cryptexEncryptedOffset:], dst[:cryptexEncryptedOffset],
+		); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFailedToVerifyAuthTag, err)
+		}
+		moveCryptexExtensionHeader(dst, false)
+		restoreCryptexProfile(dst)
+		return dst[:nDst], nil
+	}
 
 	if _, err := s.srtpCipher.Open(
-		dst[headerLen:headerLen], iv[:], ciphertext[headerLen:], ciphertext[:headerLen],
+		dst[headerLen:headerLen], s.iv[:], ciphertext[headerLen:], ciphertext[:headerLen],
 	); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrFailedToVerifyAuthTag, err)
 	}
 
 	copy(dst[:headerLen], ciphertext[:headerLen])
@@ -115,19 +189,21 @@ func (s *srtpCipherAeadAesGcm) encryptRTCP(dst, decrypted []byte, srtcpIndex uin
 	}
 	aadPos := len(decrypted) + authTagLen
 	// Grow the given buffer to fit the output.
-	dst = growBufferSize(dst, aadPos+srtcpIndexSize)
+	dst = growBufferSize(dst, aadPos+srtcpIndexSize+len(s.mki))
 
-	iv := s.rtcpInitializationVector(srtcpIndex, ssrc)
-	aad := s.rtcpAdditionalAuthenticatedData(decrypted, srtcpIndex)
+	s.iv = s.rtcpInitializationVector(srtcpIndex, ssrc)
+	s.aad = s.rtcpAdditionalAuthenticatedData(decrypted, srtcpIndex)
 
-	s.srtcpCipher.Seal(dst[8:8], iv[:], decrypted[8:], aad[:])
+	s.srtcpCipher.Seal(dst[8:8], s.iv[:], decrypted[8:], s.aad[:])
 
 	copy(dst[:8], decrypted[:8])
-	copy(dst[aadPos:aadPos+4], aad[8:12])
+	copy(dst[aadPos:aadPos+4], s.aad[8:12])
+	copy(dst[aadPos+4:], s.mki)
 	return dst, nil
 }
 
 func (s *srtpCipherAeadAesGcm) decryptRTCP(dst, encrypted []byte, srtcpIndex, ssrc uint32) ([]byte, error) {
+	encrypted = encrypted[:len(encrypted)-len(s.mki)]
 	aadPos := len(encrypted) - srtcpIndexSize
 	// Grow the given buffer to fit the output.
 	authTagLen, err := s.aeadAuthTagLen()
@@ -137,15 +213,33 @@ func (s *srtpCipherAeadAesGcm) decryptRTCP(dst, encrypted []byte, srtcpIndex, ss
 	nDst := aadPos - authTagLen
 	if nDst < 0 {
 		// Size of ciphertext is shorter than AEAD auth tag len.
-		return nil, errFailedToVerifyAuthTag
+		return nil, ErrFailedToVerifyAuthTag
 	}
 	dst = growBufferSize(dst, nDst)
 
-	iv := s.rtcpInitializationVector(srtcpIndex, ssrc)
-	aad := s.rtcpAdditionalAuthenticatedData(encrypted, srtcpIndex)
+	s.iv = s.rtcpInitializationVector(srtcpIndex, ssrc)
 
-	if _, err := s.srtcpCipher.Open(dst[8:8], iv[:], encrypted[8:aadPos], aad[:]); err != nil {
-		return nil, err
+	if isEncrypted := encrypted[aadPos]&rtcpEncryptionFlag != 0; !isEncrypted {
+		// When the E flag is not set, the whole RTCP packet followed by the
+		// ESRTCP word is used as AAD and the plaintext is empty.
+		//
+		// https://tools.ietf.org/html/rfc7714#section-9.3
+		aad := make([]byte, 0, nDst+srtcpIndexSize)
+		aad = append(aad, encrypted[:nDst]...)
+		aad = append(aad, encrypted[aadPos:]...)
+
+		if _, err := s.srtcpCipher.Open(nil, s.iv[:], encrypted[nDst:aadPos], aad); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFailedToVerifyAuthTag, err)
+		}
+
+		copy(dst, encrypted[:nDst])
+		return dst, nil
+	}
+
+	s.aad = s.rtcpAdditionalAuthenticatedData(encrypted, srtcpIndex)
+
+	if _, err := s.srtcpCipher.Open(dst[8:8], s.iv[:], encrypted[8:aadPos], s.aad[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFailedToVerifyAuthTag, err)
 	}
 
 	copy(dst[:8], encrypted[:8])
@@ -205,5 +299,6 @@ func (s *srtpCipherAeadAesGcm) rtcpAdditionalAuthenticatedData(rtcpPacket []byte
 }
 
 func (s *srtpCipherAeadAesGcm) getRTCPIndex(in []byte) uint32 {
-	return binary.BigEndian.Uint32(in[len(in)-4:]) &^ (rtcpEncryptionFlag << 24)
+	tailOffset := len(in) - (len(s.mki) + srtcpIndexSize)
+	return binary.BigEndian.Uint32(in[tailOffset:]) &^ (rtcpEncryptionFlag << 24)
 }
@@ -6,6 +6,7 @@ package srtp
 import (
 	"testing"
 
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -63,6 +64,17 @@ func TestSrtpCipherAedAes128Gcm(t *testing.T) {
 		})
 	})
 
+	t.Run("Decrypt RTP with invalid tag", func(t *testing.T) {
+		ctx, err := CreateContext(masterKey, masterSalt, ProtectionProfileAeadAes128Gcm)
+		assert.NoError(t, err)
+
+		tampered := append([]byte{}, encryptedRTPPacket...)
+		tampered[len(tampered)-1] ^= 0xff
+
+		_, err = ctx.DecryptRTP(nil, tampered, nil)
+		assert.ErrorIs(t, err, ErrFailedToVerifyAuthTag)
+	})
+
 	t.Run("Encrypt RTCP", func(t *testing.T) {
 		ctx, err := CreateContext(masterKey, masterSalt, ProtectionProfileAeadAes128Gcm)
 		assert.NoError(t, err)
@@ -162,3 +174,19 @@ func TestSrtpCipherAedAes256Gcm(t *testing.T) {
 		})
 	})
 }
+
+func TestSrtpCipherAeadAesGcmInitializationVector(t *testing.T) {
+	// Session salt, SSRC, ROC and SEQ from RFC 7714 section 16.1.1
+	salt := []byte{0x51, 0x75, 0x69, 0x64, 0x20, 0x70, 0x72, 0x6f, 0x20, 0x71, 0x75, 0x6f}
+	s := &srtpCipherAeadAesGcm{srtpSessionSalt: salt, srtcpSessionSalt: salt}
+
+	iv := s.rtpInitializationVector(&rtp.Header{SSRC: 0xcafebabe, SequenceNumber: 0x1234}, 0)
+	assert.Equal(t, [12]byte{0x51, 0x75, 0xa3, 0x9a, 0x9a, 0xce, 0x72, 0x6f, 0x20, 0x71, 0x67, 0x5b}, iv)
+
+	iv = s.rtpInitializationVector(&rtp.Header{SSRC: 0xcafebabe, SequenceNumber: 0x1234}, 0x01020304)
+	assert.Equal(t, [12]byte{0x51, 0x75, 0xa3, 0x9a, 0x9a, 0xce, 0x73, 0x6d, 0x23, 0x75, 0x67, 0x5b}, iv)
+
+	// 2 octets of zeroes, SSRC, 2 octets of zeroes, 0 bit and the 31-bit index
+	iv = s.rtcpInitializationVector(0x000005d4, 0x4d617273)
+	assert.Equal(t, [12]byte{0x51, 0x75, 0x24, 0x05, 0x52, 0x03, 0x72, 0x6f, 0x20, 0x71, 0x70, 0xbb}, iv)
+}
@@ -13,39 +13,70 @@ import ( //nolint:gci
 	"hash"
 
 	"github.com/pion/rtp"
+	"github.com/pion/transport/v3/utils/xor"
 )
 
 type srtpCipherAesCmHmacSha1 struct {
 	ProtectionProfile
 
-	srtpSessionSalt []byte
-	srtpSessionAuth hash.Hash
-	srtpBlock       cipher.Block
+	// Written between the encrypted portion and the auth tag
+	mki []byte
 
-	srtcpSessionSalt []byte
-	srtcpSessionAuth hash.Hash
-	srtcpBlock       cipher.Block
+	// Unset for the NULL profiles, which only authenticate packets
+	encrypted bool
+
+	srtpSessionSalt    []byte
+	srtpSessionAuth    hash.Hash
+	srtpSessionAuthKey []byte
+	srtpBlock          cipher.Block
+
+	srtcpSessionSalt    []byte
+	srtcpSessionAuth    hash.Hash
+	srtcpSessionAuthKey []byte
+	srtcpBlock          cipher.Block
+
+	// Set if some RTP header extensions are encrypted (RFC 6904)
+	headerExtensions *headerExtensionCipher
+
+	// Set if the CSRCs and header extensions are encrypted (RFC 9335)
+	cryptex bool
+
+	// Set if payload keystreams are precomputed
+	keystreams *keystreamPrecomputer
+
+	// Scratch space reused for each packet
+	ctr     ctrBuffers
+	authTag [sha1.Size]byte
+	roc     [4]byte
 }
 
-func newSrtpCipherAesCmHmacSha1(profile ProtectionProfile, masterKey, masterSalt []byte) (*srtpCipherAesCmHmacSha1, error) {
-	s := &srtpCipherAesCmHmacSha1{ProtectionProfile: profile}
-	srtpSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, 0, len(masterKey))
+func newSrtpCipherAesCmHmacSha1(profile ProtectionProfile, masterKey, masterSalt, mki []byte, indexOverKdr uint64) (*srtpCipherAesCmHmacSha1, error) {
+	s := &srtpCipherAesCmHmacSha1{ProtectionProfile: profile, mki: mki}
+	switch profile {
+	case ProtectionProfileNullHmacSha1_32, ProtectionProfileNullHmacSha1_80:
+	default:
+		s.encrypted = true
+	}
+
+	srtpSessionKey, err := aesCmKeyDerivation(labelSRTPEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
 	if err != nil {
 		return nil, err
 	} else if s.srtpBlock, err = aes.NewCipher(srtpSessionKey); err != nil {
 		return nil, err
 	}
+	zeroBytes(srtpSessionKey)
 
-	srtcpSessionKey, err := aesCmKeyDerivation(labelSRTCPEncryption, masterKey, masterSalt, 0, len(masterKey))
+	srtcpSessionKey, err := aesCmKeyDerivation(labelSRTCPEncryption, masterKey, masterSalt, indexOverKdr, len(masterKey))
 	if err != nil {
 		return nil, err
 	} else if s.srtcpBlock, err = aes.NewCipher(srtcpSessionKey); err != nil {
 		return nil, err
 	}
+	zeroBytes(srtcpSessionKey)
 
-	if s.srtpSessionSalt, err = aesCmKeyDerivation(labelSRTPSalt, masterKey, masterSalt, 0, len(masterSalt)); err != nil {
+	if s.srtpSessionSalt, err = aesCmKeyDerivation(labelSRTPSalt, masterKey, masterSalt, indexOverKdr, len(masterSalt)); err != nil {
 		return nil, err
-	} else if s.srtcpSessionSalt, err = aesCmKeyDerivation(labelSRTCPSalt, masterKey, masterSalt, 0, len(masterSalt)); err != nil {
+	} else if s.srtcpSessionSalt, err = aesCmKeyDerivation(labelSRTCPSalt, masterKey, masterSalt, indexOverKdr, len(masterSalt)); err != nil {
 		return nil, err
 	}
 
@@ -54,28 +85,96 @@ func newSrtpCipherAesCmHmacSha1(profile ProtectionProfile, masterKey, masterSalt
 		return nil, err
 	}
 
-	srtpSessionAuthTag, err := aesCmKeyDerivation(labelSRTPAuthenticationTag, masterKey, masterSalt, 0, authKeyLen)
+	srtpSessionAuthTag, err := aesCmKeyDerivation(labelSRTPAuthenticationTag, masterKey, masterSalt, indexOverKdr, authKeyLen)
 	if err != nil {
 		return nil, err
 	}
 
-	srtcpSessionAuthTag, err := aesCmKeyDerivation(labelSRTCPAuthenticationTag, masterKey, masterSalt, 0, authKeyLen)
+	srtcpSessionAuthTag, err := aesCmKeyDerivation(labelSRTCPAuthenticationTag, masterKey, masterSalt, indexOverKdr, authKeyLen)
 	if err != nil {
 		return nil, err
 	}
 
+	// The authentication keys are retained for clone, as the HMAC states
+	// can not be copied.
 	s.srtcpSessionAuth = hmac.New(sha1.New, srtcpSessionAuthTag)
 	s.srtpSessionAuth = hmac.New(sha1.New, srtpSessionAuthTag)
+	s.srtcpSessionAuthKey, s.srtpSessionAuthKey = srtcpSessionAuthTag, srtpSessionAuthTag
 	return s, nil
 }
 
+func (s *srtpCipherAesCmHmacSha1) zero() {
+	zeroBytes(s.srtpSessionSalt)
+	zeroBytes(s.srtcpSessionSalt)
+	zeroBytes(s.srtpSessionAuthKey)
+	zeroBytes(s.srtcpSessionAuthKey)
+	s.srtpBlock, s.srtcpBlock = nil, nil
+	s.srtpSessionAuth, s.srtcpSessionAuth = nil, nil
+	if s.headerExtensions != nil {
+		s.headerExtensions.zero()
+	}
+	if s.keystreams != nil {
+		s.keystreams.stop()
+	}
+}
+
+func (s *srtpCipherAesCmHmacSha1) clone() srtpCipher {
+	c := &srtpCipherAesCmHmacSha1{
+		ProtectionProfile:   s.ProtectionProfile,
+		mki:                 s.mki,
+		encrypted:           s.encrypted,
+		srtpSessionSalt:     append([]byte{}, s.srtpSessionSalt...),
+		srtpSessionAuthKey:  append([]byte{}, s.srtpSessionAuthKey...),
+		srtpBlock:           s.srtpBlock,
+		srtcpSessionSalt:    append([]byte{}, s.srtcpSessionSalt...),
+		srtcpSessionAuthKey: append([]byte{}, s.srtcpSessionAuthKey...),
+		srtcpBlock:          s.srtcpBlock,
+		cryptex:             s.cryptex,
+	}
+	c.ctr.pipelinedSize = s.ctr.pipelinedSize
+	c.srtpSessionAuth = hmac.New(sha1.New, c.srtpSessionAuthKey)
+	c.srtcpSessionAuth = hmac.New(sha1.New, c.srtcpSessionAuthKey)
+	if s.headerExtensions != nil {
+		c.headerExtensions = s.headerExtensions.clone()
+	}
+	if s.keystreams != nil {
+		c.keystreams = newKeystreamPrecomputer(c.srtpBlock, c.srtpSessionSalt, s.keystreams.packets, s.keystreams.size)
+	}
+	return c
+}
+
+// xorRTPPayload encrypts or decrypts the payload of an SRTP packet, using the
+// precomputed keystream of the packet if there is one.
+func (s *srtpCipherAesCmHmacSha1) xorRTPPayload(dst, src []byte, header *rtp.Header, roc uint32) error {
+	if s.keystreams == nil {
+		counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
+		return s.ctr.xorBytesCTR(s.srtpBlock, counter[:], dst, src)
+	}
+
+	index := uint64(roc)<<16 | uint64(header.SequenceNumber)
+	if keystream := s.keystreams.take(header.SSRC, index, len(src)); keystream != nil {
+		xor.XorBytes(dst, src, keystream[:len(src)])
+		s.keystreams.release(keystream)
+	} else {
+		counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
+		if err := s.ctr.xorBytesCTR(s.srtpBlock, counter[:], dst, src); err != nil {
+			return err
+		}
+	}
+	s.keystreams.processed(header.SSRC, index)
+	return nil
+}
+
 func (s *srtpCipherAesCmHmacSha1) encryptRTP(dst []byte, header *rtp.Header, payload []byte, roc uint32) (ciphertext []byte, err error) {
 	// Grow the given buffer to fit the output.
 	authTagLen, err := s.rtpAuthTagLen()
 	if err != nil {
 		return nil, err
 	}
-	dst = growBufferSize(dst, header.MarshalSize()+len(payload)+authTagLen)
+	if s.cryptex && cryptexApplies(header) {
+		return s.encryptRTPCryptex(dst, header, payload, roc, authTagLen)
+	}
+	dst = growBufferSize(dst, header.MarshalSize()+len(payload)+len(s.mki)+authTagLen)
 
 	// Copy the header unencrypted.
 	n, err := header.MarshalTo(dst)
@@ -83,20 +182,58 @@ func (s *srtpCipherAesCmHmacSha1) encryptRTP(dst []byte, header *rtp.Header, pay
 		return nil, err
 	}
 
+	// Encrypt the selected header extensions in place.
+	if s.headerExtensions != nil {
+		if err = s.headerExtensions.xorHeaderExtensions(dst[:n], header.SSRC, header.SequenceNumber, roc); err != nil {
+			return nil, err
+		}
+	}
+
 	// Encrypt the payload
-	counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
-	if err = xorBytesCTR(s.srtpBlock, counter[:], dst[n:], payload); err != nil {
-		return nil, err
+	if s.encrypted {
+		if err = s.xorRTPPayload(dst[n:], payload, header, roc); err != nil {
+			return nil, err
+		}
+	} else {
+		copy(dst[n:], payload)
 	}
 	n += len(payload)
 
-	// Generate the auth tag.
+	return s.appendSrtpAuthTag(dst, n, roc)
+}
+
+// encryptRTPCryptex encrypts the CSRCs and the header extension data together
+// with the payload, as specified in RFC 9335.
+func (s *srtpCipherAesCmHmacSha1) encryptRTPCryptex(dst []byte, header *rtp.Header, payload []byte, roc uint32, authTagLen int) ([]byte, error) {
+	dst = growBufferSize(dst, cryptexMarshalSize(header)+len(payload)+len(s.mki)+authTagLen)
+
+	n, err := marshalCryptexHeader(dst, header)
+	if err != nil {
+		return nil, err
+	}
+	n += copy(dst[n:], payload)
+
+	if s.encrypted {
+		moveCryptexExtensionHeader(dst, true)
+		counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
+		if err = s.ctr.xorBytesCTR(s.srtpBlock, counter[:], dst[cryptexEncryptedOffset:n], dst[cryptexEncryptedOffset:n]); err != nil {
+			return nil, err
+		}
+		moveCryptexExtensionHeader(dst, false)
+	}
+
+	return s.appendSrtpAuthTag(dst, n, roc)
+}
+
+// appendSrtpAuthTag writes the MKI and the auth tag of the first n bytes of
+// dst after them.
+func (s *srtpCipherAesCmHmacSha1) appendSrtpAuthTag(dst []byte, n int, roc uint32) ([]byte, error) {
 	authTag, err := s.generateSrtpAuthTag(dst[:n], roc)
 	if err != nil {
 		return nil, err
 	}
 
-	// Write the auth tag to the dest.
+	n += copy(dst[n:], s.mki)
 	copy(dst[n:], authTag)
 
 	return dst, nil
@@ -109,7 +246,7 @@ func (s *srtpCipherAesCmHmacSha1) decryptRTP(dst, ciphertext []byte, header *rtp
 		return nil, err
 	}
 	actualTag := ciphertext[len(ciphertext)-authTagLen:]
-	ciphertext = ciphertext[:len(ciphertext)-authTagLen]
+	ciphertext = ciphertext[:len(ciphertext)-len(s.mki)-authTagLen]
 
 	// Generate the auth tag we expect to see from the ciphertext.
 	expectedTag, err := s.generateSrtpAuthTag(ciphertext, roc)
@@ -120,17 +257,39 @@ func (s *srtpCipherAesCmHmacSha1) decryptRTP(dst, ciphertext []byte, header *rtp
 	// See if the auth tag actually matches.
 	// We use a constant time comparison to prevent timing attacks.
 	if subtle.ConstantTimeCompare(actualTag, expectedTag) != 1 {
-		return nil, errFailedToVerifyAuthTag
+		return nil, ErrFailedToVerifyAuthTag
+	}
+
+	if s.cryptex && isCryptexHeader(ciphertext[:headerLen]) {
+		copy(dst, ciphertext)
+		if s.encrypted {
+			moveCryptexExtensionHeader(dst, true)
+			counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
+			if err = s.ctr.xorBytesCTR(s.srtpBlock, counter[:], dst[cryptexEncryptedOffset:], dst[cryptexEncryptedOffset:]); err != nil {
+				return nil, err
+			}
+			moveCryptexExtensionHeader(dst, false)
+		}
+		restoreCryptexProfile(dst)
+		return dst, nil
 	}
 
 	// Write the plaintext header to the destination buffer.
 	copy(dst, ciphertext[:headerLen])
 
+	// Decrypt the selected header extensions in place.
+	if s.headerExtensions != nil {
+		if err = s.headerExtensions.xorHeaderExtensions(dst[:headerLen], header.SSRC, header.SequenceNumber, roc); err != nil {
+			return nil, err
+		}
+	}
+
 	// Decrypt the ciphertext for the payload.
-	counter := generateCounter(header.SequenceNumber, roc, header.SSRC, s.srtpSessionSalt)
-	err = xorBytesCTR(
-		s.srtpBlock, counter[:], dst[headerLen:], ciphertext[headerLen:],
-	)
+	if !s.encrypted {
+		copy(dst[headerLen:], ciphertext[headerLen:])
+		return dst, nil
+	}
+	err = s.xorRTPPayload(dst[headerLen:], ciphertext[headerLen:], header, roc)
 	return dst, err
 }
 
@@ -138,20 +297,25 @@ func (s *srtpCipherAesCmHmacSha1) encryptRTCP(dst, decrypted []byte, srtcpIndex
 	dst = allocateIfMismatch(dst, decrypted)
 
 	// Encrypt everything after header
-	counter := generateCounter(uint16(srtcpIndex&0xffff), srtcpIndex>>16, ssrc, s.srtcpSessionSalt)
-	if err := xorBytesCTR(s.srtcpBlock, counter[:], dst[8:], dst[8:]); err != nil {
-		return nil, err
+	if s.encrypted {
+		counter := generateCounter(uint16(srtcpIndex&0xffff), srtcpIndex>>16, ssrc, s.srtcpSessionSalt)
+		if err := s.ctr.xorBytesCTR(s.srtcpBlock, counter[:], dst[8:], dst[8:]); err != nil {
+			return nil, err
+		}
 	}
 
 	// Add SRTCP Index and set Encryption bit
-	dst = append(dst, make([]byte, 4)...)
+	dst = growBufferSize(dst, len(dst)+srtcpIndexSize)
 	binary.BigEndian.PutUint32(dst[len(dst)-4:], srtcpIndex)
-	dst[len(dst)-4] |= 0x80
+	if s.encrypted {
+		dst[len(dst)-4] |= rtcpEncryptionFlag
+	}
 
 	authTag, err := s.generateSrtcpAuthTag(dst)
 	if err != nil {
 		return nil, err
 	}
+	dst = append(dst, s.mki...)
 	return append(dst, authTag...), nil
 }
 
@@ -160,21 +324,26 @@ func (s *srtpCipherAesCmHmacSha1) decryptRTCP(out, encrypted []byte, index, ssrc
 	if err != nil {
 		return nil, err
 	}
-	tailOffset := len(encrypted) - (authTagLen + srtcpIndexSize)
+	tailOffset := len(encrypted) - (authTagLen + len(s.mki) + srtcpIndexSize)
 	out = out[0:tailOffset]
 
-	expectedTag, err := s.generateSrtcpAuthTag(encrypted[:len(encrypted)-authTagLen])
+	expectedTag, err := s.generateSrtcpAuthTag(encrypted[:tailOffset+srtcpIndexSize])
 	if err != nil {
 		return nil, err
 	}
 
 	actualTag := encrypted[len(encrypted)-authTagLen:]
 	if subtle.ConstantTimeCompare(actualTag, expectedTag) != 1 {
-		return nil, errFailedToVerifyAuthTag
+		return nil, ErrFailedToVerifyAuthTag
+	}
+
+	// The payload is only authenticated if the E flag is not set.
+	if isEncrypted := encrypted[tailOffset]&rtcpEncryptionFlag != 0; !isEncrypted || !s.encrypted {
+		return out, nil
 	}
 
 	counter := generateCounter(uint16(index&0xffff), index>>16, ssrc, s.srtcpSessionSalt)
-	err = xorBytesCTR(s.srtcpBlock, counter[:], out[8:], out[8:])
+	err = s.ctr.xorBytesCTR(s.srtcpBlock, counter[:], out[8:], out[8:])
 
 	return out, err
 }
@@ -201,10 +370,9 @@ func (s *srtpCipherAesCmHmacSha1) generateSrtpAuthTag(buf []byte, roc uint32) ([
 	}
 
 	// For SRTP only, we need to hash the rollover counter as well.
-	rocRaw := [4]byte{}
-	binary.BigEndian.PutUint32(rocRaw[:], roc)
+	binary.BigEndian.PutUint32(s.roc[:], roc)
 
-	_, err := s.srtpSessionAuth.Write(rocRaw[:])
+	_, err := s.srtpSessionAuth.Write(s.roc[:])
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +382,7 @@ func (s *srtpCipherAesCmHmacSha1) generateSrtpAuthTag(buf []byte, roc uint32) ([
 	if err != nil {
 		return nil, err
 	}
-	return s.srtpSessionAuth.Sum(nil)[0:authTagLen], nil
+	return s.srtpSessionAuth.Sum(s.authTag[:0])[0:authTagLen], nil
 }
 
 func (s *srtpCipherAesCmHmacSha1) generateSrtcpAuthTag(buf []byte) ([]byte, error) {
@@ -239,12 +407,12 @@ func (s *srtpCipherAesCmHmacSha1) generateSrtcpAuthTag(buf []byte) ([]byte, erro
 		return nil, err
 	}
 
-	return s.srtcpSessionAuth.Sum(nil)[0:authTagLen], nil
+	return s.srtcpSessionAuth.Sum(s.authTag[:0])[0:authTagLen], nil
 }
 
 func (s *srtpCipherAesCmHmacSha1) getRTCPIndex(in []byte) uint32 {
 	authTagLen, _ := s.rtcpAuthTagLen()
-	tailOffset := len(in) - (authTagLen + srtcpIndexSize)
+	tailOffset := len(in) - (authTagLen + len(s.mki) + srtcpIndexSize)
 	srtcpIndexBuffer := in[tailOffset : tailOffset+srtcpIndexSize]
 	return binary.BigEndian.Uint32(srtcpIndexBuffer) &^ (1 << 31)
 }
@@ -6,6 +6,7 @@ package srtp
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/pion/rtp"
@@ -59,6 +60,8 @@ func testKeyLen(t *testing.T, profile ProtectionProfile) {
 func TestKeyLen(t *testing.T) {
 	t.Run("CTR", func(t *testing.T) { testKeyLen(t, profileCTR) })
 	t.Run("GCM", func(t *testing.T) { testKeyLen(t, profileGCM) })
+	t.Run("CTR-256", func(t *testing.T) { testKeyLen(t, ProtectionProfileAes256CmHmacSha1_80) })
+	t.Run("GCM-256", func(t *testing.T) { testKeyLen(t, ProtectionProfileAeadAes256Gcm) })
 }
 
 func TestValidPacketCounter(t *testing.T) {
@@ -168,6 +171,143 @@ func TestRolloverCount(t *testing.T) {
 	}
 }
 
+func TestRolloverCountMaxDisorder(t *testing.T) {
+	for name, tc := range map[string]struct {
+		index       uint64
+		seq         uint16
+		maxDisorder uint16
+		roc         uint32
+		diff        int32
+		ovf         bool
+	}{
+		"AppendixABehind":  {index: 1<<16 | 40000, seq: 7233, roc: 1, diff: -32767},
+		"AppendixAAhead":   {index: 1<<16 | 40000, seq: 7231, roc: 2, diff: 32767},
+		"Behind":           {index: 1<<16 | 40000, seq: 100, maxDisorder: 60000, roc: 1, diff: -39900},
+		"BehindRollover":   {index: 1<<16 | 100, seq: 40000, maxDisorder: 60000, roc: 0, diff: -25636},
+		"BehindFirstROC":   {index: 100, seq: 40000, maxDisorder: 60000, roc: 0, diff: 39900},
+		"Ahead":            {index: 1<<16 | 40000, seq: 50000, maxDisorder: 100, roc: 1, diff: 10000},
+		"AheadRollover":    {index: 1<<16 | 40000, seq: 39800, maxDisorder: 100, roc: 2, diff: 65336},
+		"Same":             {index: 1<<16 | 40000, seq: 40000, maxDisorder: 100, roc: 1, diff: 0},
+		"OverflowRollover": {index: maxSRTPIndex, seq: 1, maxDisorder: 100, roc: 0, diff: 2, ovf: true},
+	} {
+		s := &srtpSSRCState{ssrc: defaultSsrc, rolloverHasProcessed: true, index: tc.index, maxDisorder: tc.maxDisorder}
+		roc, diff, ovf := s.nextRolloverCount(tc.seq)
+		if roc != tc.roc || diff != tc.diff || ovf != tc.ovf {
+			t.Errorf("%s: expected (%d, %d, %v), got (%d, %d, %v)", name, tc.roc, tc.diff, tc.ovf, roc, diff, ovf)
+		}
+	}
+}
+
+func TestRTPMaxROCDisorder(t *testing.T) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypt := func(seq uint16) []byte {
+		raw, errMarshal := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, SSRC: 1, SequenceNumber: seq},
+			Payload: rtpTestCaseDecrypted(),
+		}).Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+	delayed := encrypt(30000)
+	encryptContext.SetROC(1, 1)
+	latest := encrypt(5000)
+
+	// A packet delayed by 40536 packets across a rollover is only decrypted
+	// with the ROC it was sent with if the disorder is allowed.
+	for _, maxDisorder := range []uint16{0, 60000} {
+		decryptContext, errCtx := buildTestContext(profileCTR, SRTPMaxROCDisorder(maxDisorder))
+		if errCtx != nil {
+			t.Fatal(errCtx)
+		}
+		decryptContext.SetROC(1, 1)
+		_, index, errDec := decryptContext.DecryptRTPWithIndex(nil, latest, nil)
+		assert.NoError(errDec)
+		assert.Equal(uint64(1<<16|5000), index)
+
+		_, index, errDec = decryptContext.DecryptRTPWithIndex(nil, delayed, nil)
+		if maxDisorder == 0 {
+			assert.ErrorIs(errDec, ErrFailedToVerifyAuthTag)
+			continue
+		}
+		assert.NoError(errDec)
+		assert.Equal(uint64(30000), index)
+	}
+}
+
+func testRTPRolloverGap(t *testing.T, profile ProtectionProfile, sent, received []int) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profile, SRTPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := map[int][]byte{}
+	for _, i := range sent {
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: uint16(i)}}
+		raw, err := pkt.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encrypted[i], err = encryptContext.EncryptRTP(nil, raw, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, i := range received {
+		_, index, err := decryptContext.DecryptRTPWithIndex(nil, encrypted[i], nil)
+		if err != nil {
+			t.Fatalf("index=%d: %v", i, err)
+		}
+		assert.Equal(uint64(i), index)
+	}
+}
+
+func TestRTPRolloverGap(t *testing.T) {
+	packetRange := func(from, to int) (r []int) {
+		for i := from; i < to; i++ {
+			r = append(r, i)
+		}
+		return r
+	}
+
+	for name, profile := range map[string]ProtectionProfile{
+		"CTR": profileCTR,
+		"GCM": profileGCM,
+	} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			t.Run("FirstPacketBeforeWrap", func(t *testing.T) {
+				// The stream starts close to the end of the sequence number space
+				// and wraps right away.
+				packets := []int{65500, 1<<16 | 5}
+				testRTPRolloverGap(t, profile, packets, packets)
+			})
+			t.Run("LossAcrossWrap", func(t *testing.T) {
+				// 300 consecutive packets straddling the wrap are lost.
+				sent := packetRange(65300, 1<<16+200)
+				received := append(packetRange(65300, 65400), packetRange(1<<16+164, 1<<16+200)...)
+				testRTPRolloverGap(t, profile, sent, received)
+			})
+		})
+	}
+}
+
 func TestRolloverCountOverflow(t *testing.T) {
 	s := &srtpSSRCState{
 		ssrc:  defaultSsrc,
@@ -190,7 +330,10 @@ func buildTestContext(profile ProtectionProfile, opts ...ContextOption) (*Contex
 		return nil, err
 	}
 
-	masterKey := []byte{0x0d, 0xcd, 0x21, 0x3e, 0x4c, 0xbc, 0xf2, 0x8f, 0x01, 0x7f, 0x69, 0x94, 0x40, 0x1e, 0x28, 0x89}
+	masterKey := []byte{
+		0x0d, 0xcd, 0x21, 0x3e, 0x4c, 0xbc, 0xf2, 0x8f, 0x01, 0x7f, 0x69, 0x94, 0x40, 0x1e, 0x28, 0x89,
+		0x5b, 0xa1, 0x06, 0x4e, 0x30, 0xec, 0x51, 0x61, 0x3c, 0xad, 0x92, 0x6c, 0x5a, 0x28, 0xef, 0x73,
+	}
 	masterKey = masterKey[:keyLen]
 	masterSalt := []byte{0x62, 0x77, 0x60, 0x38, 0xc0, 0x6d, 0xc9, 0x41, 0x9f, 0x6d, 0xd9, 0x43, 0x3e, 0x7c}
 	masterSalt = masterSalt[:saltLen]
@@ -198,6 +341,258 @@ func buildTestContext(profile ProtectionProfile, opts ...ContextOption) (*Contex
 	return CreateContext(masterKey, masterSalt, profile, opts...)
 }
 
+func testRTPMultiSSRCRollover(t *testing.T, profile ProtectionProfile) {
+	assert := assert.New(t)
+
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptContext, err := buildTestContext(profile, SRTPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SSRC 1 wraps after a few packets, SSRC 2 wraps much later.
+	seqs := map[uint32]uint16{1: 65530, 2: 65500}
+	encryptContext.SetROC(2, 7)
+	for i := 0; i < 50; i++ {
+		for _, ssrc := range []uint32{1, 2} {
+			pkt := &rtp.Packet{
+				Payload: rtpTestCaseDecrypted(),
+				Header:  rtp.Header{SSRC: ssrc, SequenceNumber: seqs[ssrc]},
+			}
+			seqs[ssrc]++
+
+			raw, err := pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ssrc == 2 && i == 0 {
+				// The receiver learns the ROC of SSRC 2 out of band.
+				decryptContext.SetROC(2, 7)
+			}
+			decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+			if err != nil {
+				t.Fatalf("ssrc=%d seq=%d: %v", ssrc, pkt.SequenceNumber, err)
+			}
+			assert.Equal(raw, decrypted)
+		}
+	}
+
+	for ssrc, expected := range map[uint32]uint32{1: 1, 2: 8} {
+		roc, ok := encryptContext.ROC(ssrc)
+		assert.True(ok)
+		assert.Equal(expected, roc, "encrypt ROC for SSRC %d", ssrc)
+
+		roc, ok = decryptContext.ROC(ssrc)
+		assert.True(ok)
+		assert.Equal(expected, roc, "decrypt ROC for SSRC %d", ssrc)
+	}
+}
+
+func TestRTPMultiSSRCRollover(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPMultiSSRCRollover(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPMultiSSRCRollover(t, profileGCM) })
+}
+
+func testRTPKeyDerivationRate(t *testing.T, profile ProtectionProfile) {
+	assert := assert.New(t)
+
+	// Derive new session keys every 16 packets
+	encryptContext, err := buildTestContext(profile, KeyDerivationRate(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptContext, err := buildTestContext(profile, KeyDerivationRate(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noKDRContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	salts := map[uint64][]byte{}
+	for seq := 0; seq < 64; seq++ {
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: uint16(seq)}}
+		raw, err := pkt.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+		if err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+		assert.Equal(raw, decrypted)
+
+		// Only the first 16 packets use the session keys of (index DIV kdr) = 0
+		_, err = noKDRContext.DecryptRTP(nil, encrypted, nil)
+		if seq < 16 {
+			assert.NoError(err, "seq=%d", seq)
+		} else {
+			assert.ErrorIs(err, ErrFailedToVerifyAuthTag, "seq=%d", seq)
+		}
+
+		keys := encryptContext.srtpSSRCStates[1].sessionKeys
+		switch cipher := keys.cipher.(type) {
+		case *srtpCipherAesCmHmacSha1:
+			salts[keys.indexOverKdr] = append([]byte{}, cipher.srtpSessionSalt...)
+		case *srtpCipherAeadAesGcm:
+			salts[keys.indexOverKdr] = append([]byte{}, cipher.srtpSessionSalt...)
+		}
+	}
+
+	assert.Equal(4, len(salts))
+	for i := uint64(1); i < 4; i++ {
+		assert.NotEqual(salts[i-1], salts[i], "session salt must change at each KDR boundary")
+	}
+}
+
+func TestRTPKeyDerivationRate(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPKeyDerivationRate(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPKeyDerivationRate(t, profileGCM) })
+}
+
+func TestInvalidKeyDerivationRate(t *testing.T) {
+	if _, err := buildTestContext(profileCTR, KeyDerivationRate(25)); !errors.Is(err, errInvalidKDR) {
+		t.Errorf("Expected error '%v', got '%v'", errInvalidKDR, err)
+	}
+}
+
+func TestRTPKeyDerivationRateForgedIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	// Derive new session keys every 16 packets
+	encryptContext, err := buildTestContext(profileCTR, KeyDerivationRate(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, KeyDerivationRate(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt := func(seq uint16) []byte {
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: seq}}
+		raw, errMarshal := pkt.Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		return encrypted
+	}
+
+	_, err = decryptContext.DecryptRTP(nil, encrypt(0), nil)
+	assert.NoError(err)
+	keys := decryptContext.srtpSSRCStates[1].sessionKeys
+
+	// A forged packet from another (index DIV kdr) does not replace the
+	// cached session keys.
+	forged := encrypt(100)
+	forged[len(forged)-1] ^= 0x01
+	_, err = decryptContext.DecryptRTP(nil, forged, nil)
+	assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+	assert.Equal(keys, decryptContext.srtpSSRCStates[1].sessionKeys)
+
+	// An authenticated one does.
+	_, err = decryptContext.DecryptRTP(nil, encrypt(101), nil)
+	assert.NoError(err)
+	assert.Equal(uint64(6), decryptContext.srtpSSRCStates[1].sessionKeys.indexOverKdr)
+	assert.NotSame(keys.cipher, decryptContext.srtpSSRCStates[1].sessionKeys.cipher)
+}
+
+func testRTPMKI(t *testing.T, profile ProtectionProfile, opts ...ContextOption) {
+	assert := assert.New(t)
+
+	mki1, mki2 := []byte{0x01, 0x02, 0x03, 0x04}, []byte{0x02, 0x03, 0x04, 0x05}
+	keyLen, err := profile.keyLen()
+	assert.NoError(err)
+	saltLen, err := profile.saltLen()
+	assert.NoError(err)
+	masterKey2, masterSalt2 := make([]byte, keyLen), make([]byte, saltLen)
+
+	encryptContext, err := buildTestContext(profile, append(opts, MasterKeyIndicator(mki1))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(encryptContext.AddCipherForMKI(mki2, masterKey2, masterSalt2))
+
+	decryptContext, err := buildTestContext(profile, append(opts, MasterKeyIndicator(mki1))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(decryptContext.AddCipherForMKI(mki2, masterKey2, masterSalt2))
+
+	// A receiver which does not know the second master key
+	singleKeyContext, err := buildTestContext(profile, append(opts, MasterKeyIndicator(mki1))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authTagLen, err := encryptContext.keys.cipher.rtpAuthTagLen()
+	assert.NoError(err)
+
+	for seq := 0; seq < 64; seq++ {
+		mki := mki1
+		if seq/8%2 == 1 {
+			mki = mki2
+		}
+		assert.NoError(encryptContext.SetSendMKI(mki))
+
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: uint16(seq)}}
+		raw, err := pkt.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The MKI is placed right before the auth tag
+		mkiOffset := len(encrypted) - authTagLen - len(mki)
+		assert.Equal(mki, encrypted[mkiOffset:mkiOffset+len(mki)])
+
+		decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+		if err != nil {
+			t.Fatalf("seq=%d: %v", seq, err)
+		}
+		assert.Equal(raw, decrypted)
+
+		_, err = singleKeyContext.DecryptRTP(nil, encrypted, nil)
+		if bytes.Equal(mki, mki1) {
+			assert.NoError(err, "seq=%d", seq)
+		} else {
+			assert.ErrorIs(err, ErrMKINotFound, "seq=%d", seq)
+		}
+	}
+}
+
+func TestRTPMKI(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPMKI(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPMKI(t, profileGCM) })
+	t.Run("KDR", func(t *testing.T) { testRTPMKI(t, profileCTR, KeyDerivationRate(2)) })
+}
+
 func TestRTPInvalidAuth(t *testing.T) {
 	masterKey := []byte{0x0d, 0xcd, 0x21, 0x3e, 0x4c, 0xbc, 0xf2, 0x8f, 0x01, 0x7f, 0x69, 0x94, 0x40, 0x1e, 0x28, 0x89}
 	invalidSalt := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
@@ -267,6 +662,52 @@ func rtpTestCases() []rtpTestCase {
 	}
 }
 
+func testRTPTamperedPacket(t *testing.T, profile ProtectionProfile) {
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: 5000}}
+	pktRaw, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := encryptContext.EncryptRTP(nil, pktRaw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every byte of the header, the payload and the tag is authenticated.
+	for i := range encrypted {
+		decryptContext, err := buildTestContext(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+		if _, err = decryptContext.DecryptRTP(nil, tampered, nil); err == nil {
+			t.Errorf("Managed to decrypt packet with byte %d modified", i)
+		}
+	}
+
+	// The ROC is authenticated as well.
+	decryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext.SetROC(1, 1)
+	_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+	assert.ErrorIs(t, err, ErrFailedToVerifyAuthTag)
+}
+
+func TestRTPTamperedPacket(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPTamperedPacket(t, profileCTR) })
+	t.Run("CTR-32", func(t *testing.T) { testRTPTamperedPacket(t, ProtectionProfileAes128CmHmacSha1_32) })
+	t.Run("GCM", func(t *testing.T) { testRTPTamperedPacket(t, profileGCM) })
+}
+
 func testRTPLifecyleNewAlloc(t *testing.T, profile ProtectionProfile) {
 	assert := assert.New(t)
 
@@ -313,6 +754,57 @@ func testRTPLifecyleNewAlloc(t *testing.T, profile ProtectionProfile) {
 	}
 }
 
+func testRTPRawHeaderFields(t *testing.T, profile ProtectionProfile) {
+	assert := assert.New(t)
+
+	pkt := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Padding:        true,
+			Marker:         true,
+			PayloadType:    96,
+			SequenceNumber: 5000,
+			Timestamp:      0xdeadbeef,
+			SSRC:           0xcafebabe,
+			CSRC:           []uint32{1, 2, 3},
+		},
+		Payload:     rtpTestCaseDecrypted(),
+		PaddingSize: 4,
+	}
+	if err := pkt.Header.SetExtension(1, []byte{0xaa, 0xbb}); err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerLen := pkt.Header.MarshalSize()
+
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Raw packets are parsed without the caller providing a header.
+	encrypted, err := encryptContext.EncryptRTP(nil, decrypted, nil)
+	assert.NoError(err)
+	assert.Equal(decrypted[:headerLen], encrypted[:headerLen], "header must be sent in the clear")
+	assert.NotEqual(decrypted[headerLen:], encrypted[headerLen:len(decrypted)], "payload and padding must be encrypted")
+
+	actual, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+	assert.NoError(err)
+	assert.Equal(decrypted, actual)
+}
+
+func TestRTPRawHeaderFields(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPRawHeaderFields(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPRawHeaderFields(t, profileGCM) })
+}
+
 func TestRTPLifecycleNewAlloc(t *testing.T) {
 	t.Run("CTR", func(t *testing.T) { testRTPLifecyleNewAlloc(t, profileCTR) })
 	t.Run("GCM", func(t *testing.T) { testRTPLifecyleNewAlloc(t, profileGCM) })
@@ -452,8 +944,8 @@ func testRTPReplayProtection(t *testing.T, profile ProtectionProfile) {
 		assert.Equalf(actualDecrypted, decryptedRaw, "RTP packet with SeqNum invalid decryption: %d", testCase.sequenceNumber)
 
 		_, errReplay := decryptContext.DecryptRTP(decryptInput, decryptInput, decryptHeader)
-		if !errors.Is(errReplay, errDuplicated) {
-			t.Errorf("Replayed packet must be errored with %v, got %v", errDuplicated, errReplay)
+		if !errors.Is(errReplay, ErrDuplicated) {
+			t.Errorf("Replayed packet must be errored with %v, got %v", ErrDuplicated, errReplay)
 		}
 	}
 }
@@ -463,33 +955,192 @@ func TestRTPReplayProtection(t *testing.T) {
 	t.Run("GCM", func(t *testing.T) { testRTPReplayProtection(t, profileGCM) })
 }
 
-func TestRTPReplayDetectorFactory(t *testing.T) {
-	assert := assert.New(t)
-	profile := profileCTR
-	data := rtpTestCases()[0]
-
-	var cntFactory int
-	decryptContext, err := buildTestContext(
-		profile, SRTPReplayDetectorFactory(func() replaydetector.ReplayDetector {
-			cntFactory++
-			return &nopReplayDetector{}
-		}),
-	)
+func testRTPReplayProtectionRollover(t *testing.T, profile ProtectionProfile) {
+	encryptContext, err := buildTestContext(profile)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	pkt := &rtp.Packet{
-		Payload: data.encrypted(profile),
-		Header:  rtp.Header{SequenceNumber: data.sequenceNumber},
-	}
-	in, err := pkt.Marshal()
+	decryptContext, err := buildTestContext(
+		profile, SRTPReplayProtection(64),
+	)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if _, err := decryptContext.DecryptRTP(nil, in, nil); err != nil {
-		t.Fatal(err)
+	// Encrypt a run of packets that crosses the sequence number rollover.
+	encrypted := map[uint16][]byte{}
+	for i := 65400; i < 65536+10; i++ {
+		seq := uint16(i)
+		pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: seq}}
+		raw, errMarshal := pkt.Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		enc, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		encrypted[seq] = enc
+	}
+
+	for _, step := range []struct {
+		seq       uint16
+		duplicate bool
+	}{
+		{seq: 65530},
+		{seq: 65534},
+		{seq: 1},     // ROC 1
+		{seq: 65535}, // Late packet from ROC 0
+		{seq: 0},     // Late packet from ROC 1
+		{seq: 65535, duplicate: true},
+		{seq: 1, duplicate: true},
+		{seq: 65531}, // Still within the window
+		{seq: 9},
+		{seq: 65470, duplicate: true}, // Too old for the window
+		{seq: 65530, duplicate: true},
+	} {
+		in := append([]byte{}, encrypted[step.seq]...)
+		_, errDec := decryptContext.DecryptRTP(nil, in, nil)
+		if step.duplicate {
+			if !errors.Is(errDec, ErrDuplicated) {
+				t.Errorf("seq=%d: expected error '%v', got '%v'", step.seq, ErrDuplicated, errDec)
+			}
+		} else if errDec != nil {
+			t.Errorf("seq=%d: %v", step.seq, errDec)
+		}
+	}
+
+	roc, ok := decryptContext.ROC(1)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), roc)
+}
+
+func TestRTPReplayProtectionRollover(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPReplayProtectionRollover(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPReplayProtectionRollover(t, profileGCM) })
+}
+
+func TestRTPReplayProtectionWindowSize(t *testing.T) {
+	for _, windowSize := range []uint{16, 64, 128} {
+		windowSize := windowSize
+		t.Run(fmt.Sprint(windowSize), func(t *testing.T) {
+			encryptContext, err := buildTestContext(profileCTR)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptContext, err := buildTestContext(profileCTR, SRTPReplayProtection(windowSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encrypted := map[uint16][]byte{}
+			for seq := uint16(0); seq <= uint16(windowSize)+1; seq++ {
+				pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: 1000 + seq}}
+				raw, errMarshal := pkt.Marshal()
+				if errMarshal != nil {
+					t.Fatal(errMarshal)
+				}
+				if encrypted[seq], err = encryptContext.EncryptRTP(nil, raw, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Only receive the newest packet, then the late ones at the edge of the window.
+			latest := uint16(windowSize) + 1
+			_, err = decryptContext.DecryptRTP(nil, encrypted[latest], nil)
+			assert.NoError(t, err)
+
+			_, err = decryptContext.DecryptRTP(nil, encrypted[latest-uint16(windowSize)+1], nil)
+			assert.NoError(t, err, "packet within the window must be accepted")
+
+			_, err = decryptContext.DecryptRTP(nil, encrypted[latest-uint16(windowSize)], nil)
+			assert.ErrorIs(t, err, ErrDuplicated, "packet outside the window must be rejected")
+		})
+	}
+}
+
+func testRTPReplayDetectorSeparation(t *testing.T, profile ProtectionProfile) {
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptContext, err := buildTestContext(
+		profile, SRTPReplayProtection(64),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Several streams sharing one master key use the same sequence numbers,
+	// each SSRC has its own ROC and replay window.
+	ssrcs := []uint32{1, 2, 3}
+	encrypted := map[uint32][][]byte{}
+	for _, ssrc := range ssrcs {
+		for seq := uint16(100); seq < 110; seq++ {
+			pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: ssrc, SequenceNumber: seq}}
+			raw, errMarshal := pkt.Marshal()
+			if errMarshal != nil {
+				t.Fatal(errMarshal)
+			}
+			enc, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+			if errEnc != nil {
+				t.Fatal(errEnc)
+			}
+			encrypted[ssrc] = append(encrypted[ssrc], enc)
+		}
+	}
+
+	for _, ssrc := range ssrcs {
+		for i, pkt := range encrypted[ssrc] {
+			if _, errDec := decryptContext.DecryptRTP(nil, append([]byte{}, pkt...), nil); errDec != nil {
+				t.Errorf("ssrc=%d packet=%d: %v", ssrc, i, errDec)
+			}
+		}
+	}
+
+	for _, ssrc := range ssrcs {
+		for i, pkt := range encrypted[ssrc] {
+			if _, errDec := decryptContext.DecryptRTP(nil, append([]byte{}, pkt...), nil); !errors.Is(errDec, ErrDuplicated) {
+				t.Errorf("ssrc=%d packet=%d: expected error '%v', got '%v'", ssrc, i, ErrDuplicated, errDec)
+			}
+		}
+	}
+}
+
+func TestRTPReplayDetectorSeparation(t *testing.T) {
+	t.Run("CTR", func(t *testing.T) { testRTPReplayDetectorSeparation(t, profileCTR) })
+	t.Run("GCM", func(t *testing.T) { testRTPReplayDetectorSeparation(t, profileGCM) })
+}
+
+func TestRTPReplayDetectorFactory(t *testing.T) {
+	assert := assert.New(t)
+	profile := profileCTR
+	data := rtpTestCases()[0]
+
+	var cntFactory int
+	decryptContext, err := buildTestContext(
+		profile, SRTPReplayDetectorFactory(func() replaydetector.ReplayDetector {
+			cntFactory++
+			return &nopReplayDetector{}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := &rtp.Packet{
+		Payload: data.encrypted(profile),
+		Header:  rtp.Header{SequenceNumber: data.sequenceNumber},
+	}
+	in, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decryptContext.DecryptRTP(nil, in, nil); err != nil {
+		t.Fatal(err)
 	}
 	assert.Equal(1, cntFactory)
 }
@@ -517,6 +1168,104 @@ func benchmarkEncryptRTP(b *testing.B, profile ProtectionProfile, size int) {
 	}
 }
 
+func benchmarkDecryptRTPBatch(b *testing.B, profile ProtectionProfile, batch bool) {
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	packets := make([][]byte, 16)
+	size := 0
+	for i := range packets {
+		pkt := &rtp.Packet{Payload: make([]byte, 1000), Header: rtp.Header{SequenceNumber: uint16(i)}}
+		raw, err := pkt.Marshal()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if packets[i], err = encryptContext.EncryptRTP(nil, raw, nil); err != nil {
+			b.Fatal(err)
+		}
+		size += len(packets[i])
+	}
+
+	context, err := buildTestContext(profile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_, errs := context.DecryptRTPBatch(packets)
+			for _, err := range errs {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			continue
+		}
+		for _, pkt := range packets {
+			if _, err := context.DecryptRTP(nil, pkt, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecryptRTPBatch(b *testing.B) {
+	b.Run("CTR/Loop", func(b *testing.B) { benchmarkDecryptRTPBatch(b, profileCTR, false) })
+	b.Run("CTR/Batch", func(b *testing.B) { benchmarkDecryptRTPBatch(b, profileCTR, true) })
+	b.Run("GCM/Loop", func(b *testing.B) { benchmarkDecryptRTPBatch(b, profileGCM, false) })
+	b.Run("GCM/Batch", func(b *testing.B) { benchmarkDecryptRTPBatch(b, profileGCM, true) })
+}
+
+func benchmarkEncryptRTPBatch(b *testing.B, profile ProtectionProfile, batch bool) {
+	packets := make([][]byte, 16)
+	size := 0
+	for i := range packets {
+		pkt := &rtp.Packet{Payload: make([]byte, 1000), Header: rtp.Header{SequenceNumber: uint16(i)}}
+		var err error
+		if packets[i], err = pkt.Marshal(); err != nil {
+			b.Fatal(err)
+		}
+		size += len(packets[i])
+	}
+
+	context, err := buildTestContext(profile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_, errs := context.EncryptRTPBatch(packets)
+			for _, err := range errs {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			continue
+		}
+		for _, pkt := range packets {
+			if _, err := context.EncryptRTP(nil, pkt, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkEncryptRTPBatch(b *testing.B) {
+	b.Run("CTR/Loop", func(b *testing.B) { benchmarkEncryptRTPBatch(b, profileCTR, false) })
+	b.Run("CTR/Batch", func(b *testing.B) { benchmarkEncryptRTPBatch(b, profileCTR, true) })
+	b.Run("GCM/Loop", func(b *testing.B) { benchmarkEncryptRTPBatch(b, profileGCM, false) })
+	b.Run("GCM/Batch", func(b *testing.B) { benchmarkEncryptRTPBatch(b, profileGCM, true) })
+}
+
 func BenchmarkEncryptRTP(b *testing.B) {
 	b.Run("CTR-100", func(b *testing.B) {
 		benchmarkEncryptRTP(b, profileCTR, 100)
@@ -546,6 +1295,7 @@ func benchmarkEncryptRTPInPlace(b *testing.B, profile ProtectionProfile, size in
 
 	buf := make([]byte, 0, len(pktRaw)+10)
 
+	b.ReportAllocs()
 	b.SetBytes(int64(len(pktRaw)))
 	b.ResetTimer()
 
@@ -609,6 +1359,150 @@ func BenchmarkDecryptRTP(b *testing.B) {
 	b.Run("GCM", func(b *testing.B) { benchmarkDecryptRTP(b, profileGCM) })
 }
 
+func benchmarkDecryptRTPInPlace(b *testing.B, profile ProtectionProfile, size int) {
+	encryptContext, err := buildTestContext(profile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	pkt := &rtp.Packet{Payload: make([]byte, size), Header: rtp.Header{SequenceNumber: 5000}}
+	pktRaw, err := pkt.Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	encrypted, err := encryptContext.EncryptRTP(nil, pktRaw, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	context, err := buildTestContext(profile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	header := &rtp.Header{}
+	buf := make([]byte, 0, len(encrypted))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(encrypted)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf, err = context.DecryptRTP(buf[:0], encrypted, header)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecryptRTPInPlace(b *testing.B) {
+	b.Run("CTR-100", func(b *testing.B) { benchmarkDecryptRTPInPlace(b, profileCTR, 100) })
+	b.Run("CTR-1000", func(b *testing.B) { benchmarkDecryptRTPInPlace(b, profileCTR, 1000) })
+	b.Run("GCM-100", func(b *testing.B) { benchmarkDecryptRTPInPlace(b, profileGCM, 100) })
+	b.Run("GCM-1000", func(b *testing.B) { benchmarkDecryptRTPInPlace(b, profileGCM, 1000) })
+}
+
+func TestRTPPipelinedCTR(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, profile := range []ProtectionProfile{profileCTR, ProtectionProfileNullHmacSha1_80} {
+		encryptContext, err := buildTestContext(profile)
+		assert.NoError(err)
+		pipelinedContext, err := buildTestContext(profile, SRTPPipelinedCTR(100))
+		assert.NoError(err)
+		clonedContext, err := pipelinedContext.Clone()
+		assert.NoError(err)
+
+		for i, size := range []int{60, 100, 1200} {
+			raw, err := (&rtp.Packet{
+				Header:  rtp.Header{Version: 2, SSRC: 1, SequenceNumber: uint16(i)},
+				Payload: bytes.Repeat([]byte{0xab}, size),
+			}).Marshal()
+			assert.NoError(err)
+
+			expected, err := encryptContext.EncryptRTP(nil, raw, nil)
+			assert.NoError(err)
+			encrypted, err := pipelinedContext.EncryptRTP(nil, raw, nil)
+			assert.NoError(err)
+			assert.Equal(expected, encrypted, "%s payload of %d bytes", profile, size)
+			decrypted, err := clonedContext.DecryptRTP(nil, encrypted, nil)
+			assert.NoError(err)
+			assert.Equal(raw, decrypted, "%s payload of %d bytes", profile, size)
+
+			rtcpRaw := append([]byte{0x80, 0xc9, 0x00, byte(size / 4), 0, 0, 0, 1}, make([]byte, size)...)
+			expected, err = encryptContext.EncryptRTCP(nil, rtcpRaw, nil)
+			assert.NoError(err)
+			encrypted, err = pipelinedContext.EncryptRTCP(nil, rtcpRaw, nil)
+			assert.NoError(err)
+			assert.Equal(expected, encrypted, "%s RTCP payload of %d bytes", profile, size)
+		}
+	}
+}
+
+// BenchmarkRTPPayloadSizes encrypts and decrypts in place packets with the
+// payload sizes of audio and video streams, e.g. to choose the minimum size
+// of SRTPPipelinedCTR for a platform.
+func BenchmarkRTPPayloadSizes(b *testing.B) {
+	for _, tc := range []struct {
+		name    string
+		profile ProtectionProfile
+		opts    []ContextOption
+	}{
+		{"CTR", profileCTR, nil},
+		{"CTR-Pipelined", profileCTR, []ContextOption{SRTPPipelinedCTR(1)}},
+		{"GCM", profileGCM, nil},
+		{"NullHmacSha1", ProtectionProfileNullHmacSha1_80, nil},
+	} {
+		for _, size := range benchmarkPayloadSizes {
+			tc, size := tc, size
+			b.Run(fmt.Sprintf("%s/Encrypt-%d", tc.name, size), func(b *testing.B) {
+				benchmarkRTPPayloadSize(b, tc.profile, size, false, tc.opts...)
+			})
+			b.Run(fmt.Sprintf("%s/Decrypt-%d", tc.name, size), func(b *testing.B) {
+				benchmarkRTPPayloadSize(b, tc.profile, size, true, tc.opts...)
+			})
+		}
+	}
+}
+
+func benchmarkRTPPayloadSize(b *testing.B, profile ProtectionProfile, size int, decrypt bool, opts ...ContextOption) {
+	encryptContext, err := buildTestContext(profile, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profile, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	header := &rtp.Header{Version: 2, SSRC: 5000, SequenceNumber: 5000}
+	payload := make([]byte, size)
+	buf := make([]byte, 0, 1600)
+	encrypted, err := encryptContext.encryptRTP(nil, header, payload)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if decrypt {
+			// Without replay protection, the same packet can be decrypted
+			// again and again.
+			if buf, err = decryptContext.DecryptRTP(buf[:0], encrypted, header); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		header.SequenceNumber = uint16(i)
+		if buf, err = encryptContext.encryptRTP(buf[:0], header, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestRolloverCount2(t *testing.T) {
 	s := &srtpSSRCState{ssrc: defaultSsrc}
 
@@ -731,6 +1625,139 @@ func TestProtectionProfileAes128CmHmacSha1_32(t *testing.T) {
 	}
 }
 
+func TestProtectionProfileHmacSha1_32Tag(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx80, err := buildTestContext(ProtectionProfileAes128CmHmacSha1_80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx32, err := buildTestContext(ProtectionProfileAes128CmHmacSha1_32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SequenceNumber: 5000}}
+	pktRaw, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 32-bit RTP tag is the 80-bit tag truncated, with the same keys.
+	out80, err := ctx80.EncryptRTP(nil, pktRaw, nil)
+	assert.NoError(err)
+	out32, err := ctx32.EncryptRTP(nil, pktRaw, nil)
+	assert.NoError(err)
+	assert.Equal(out80[:len(pktRaw)+4], out32)
+
+	// SRTCP always uses the 80-bit tag.
+	rtcpRaw := []byte{0x80, 0xc8, 0x00, 0x01, 0xca, 0xfe, 0xba, 0xbe}
+	rtcp80, err := ctx80.EncryptRTCP(nil, rtcpRaw, nil)
+	assert.NoError(err)
+	rtcp32, err := ctx32.EncryptRTCP(nil, rtcpRaw, nil)
+	assert.NoError(err)
+	assert.Equal(rtcp80, rtcp32)
+}
+
+func TestProtectionProfileAes256CmHmacSha1(t *testing.T) {
+	for name, profile := range map[string]ProtectionProfile{
+		"80": ProtectionProfileAes256CmHmacSha1_80,
+		"32": ProtectionProfileAes256CmHmacSha1_32,
+	} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			encryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authTagLen, err := profile.rtpAuthTagLen()
+			assert.NoError(t, err)
+
+			pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SequenceNumber: 5000}}
+			pktRaw, err := pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := encryptContext.EncryptRTP(nil, pktRaw, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, len(pktRaw)+authTagLen, len(out))
+
+			decrypted, err := decryptContext.DecryptRTP(nil, out, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, pktRaw, decrypted)
+		})
+	}
+}
+
+func TestProtectionProfileNullHmacSha1(t *testing.T) {
+	for name, profile := range map[string]ProtectionProfile{
+		"80": ProtectionProfileNullHmacSha1_80,
+		"32": ProtectionProfileNullHmacSha1_32,
+	} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			encryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authTagLen, err := profile.rtpAuthTagLen()
+			assert.NoError(err)
+
+			// Cross a rollover to check that the ROC is still maintained.
+			for seq := 65530; seq < 65542; seq++ {
+				pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SequenceNumber: uint16(seq)}}
+				pktRaw, err := pkt.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				out, err := encryptContext.EncryptRTP(nil, pktRaw, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// The packet is sent in the clear, followed by the auth tag.
+				assert.Equal(len(pktRaw)+authTagLen, len(out))
+				assert.Equal(pktRaw, out[:len(pktRaw)])
+
+				tampered := append([]byte{}, out...)
+				tampered[len(pktRaw)-1] ^= 0xff
+				_, err = decryptContext.DecryptRTP(nil, tampered, nil)
+				assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+
+				decrypted, err := decryptContext.DecryptRTP(nil, out, nil)
+				if err != nil {
+					t.Fatalf("seq=%d: %v", seq, err)
+				}
+				assert.Equal(pktRaw, decrypted)
+			}
+
+			roc, ok := decryptContext.ROC(0)
+			assert.True(ok)
+			assert.Equal(uint32(1), roc)
+		})
+	}
+}
+
 func TestRTPDecryptShotenedPacket(t *testing.T) {
 	profiles := map[string]ProtectionProfile{
 		"CTR": profileCTR,
@@ -762,6 +1789,44 @@ func TestRTPDecryptShotenedPacket(t *testing.T) {
 	}
 }
 
+func TestRTPDecryptTooShortPacket(t *testing.T) {
+	profiles := map[string]ProtectionProfile{
+		"CTR": profileCTR,
+		"GCM": profileGCM,
+	}
+	for name, profile := range profiles {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			decryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authTagLen, err := profile.rtpAuthTagLen()
+			assert.NoError(t, err)
+			aeadAuthTagLen, err := profile.aeadAuthTagLen()
+			assert.NoError(t, err)
+
+			pkt := &rtp.Packet{Header: rtp.Header{SequenceNumber: 5000}}
+			headerRaw, err := pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < authTagLen+aeadAuthTagLen; i++ {
+				// Copy into an exactly sized buffer so that reads past the end can not succeed.
+				packet := append([]byte{}, headerRaw...)
+				packet = append(packet, make([]byte, i)...)
+
+				_, err := decryptContext.DecryptRTP(nil, packet, nil)
+				if !errors.Is(err, ErrTooShortRTP) {
+					t.Errorf("Expected error '%v' for %d trailing bytes, got '%v'", ErrTooShortRTP, i, err)
+				}
+			}
+		})
+	}
+}
+
 func TestRTPMaxPackets(t *testing.T) {
 	profiles := map[string]ProtectionProfile{
 		"CTR": profileCTR,
@@ -884,3 +1949,282 @@ func TestRTPBurstLossWithSetROC(t *testing.T) {
 		})
 	}
 }
+
+func TestRTPLateJoinWithSetROC(t *testing.T) {
+	profiles := map[string]ProtectionProfile{
+		"CTR": profileCTR,
+		"GCM": profileGCM,
+	}
+	for name, profile := range profiles {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			encryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The sender has already wrapped the sequence number many times.
+			encryptContext.SetROC(1, 0x12345)
+			pkt := &rtp.Packet{
+				Payload: rtpTestCaseDecrypted(),
+				Header:  rtp.Header{SSRC: 1, SequenceNumber: 1000},
+			}
+			raw, err := pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			encrypted, err := encryptContext.EncryptRTP(nil, raw, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A receiver joining mid-stream guesses a ROC of zero.
+			decryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = decryptContext.DecryptRTP(nil, encrypted, nil); !errors.Is(err, ErrFailedToVerifyAuthTag) {
+				t.Fatalf("Expected error '%v', got '%v'", ErrFailedToVerifyAuthTag, err)
+			}
+
+			// Once the ROC is learned out of band the packet decrypts.
+			decryptContext, err = buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptContext.SetROC(1, 0x12345)
+			decrypted, err := decryptContext.DecryptRTP(nil, encrypted, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(raw, decrypted)
+
+			roc, ok := decryptContext.ROC(1)
+			assert.True(ok)
+			assert.Equal(uint32(0x12345), roc)
+		})
+	}
+}
+
+func TestRTPDecryptWithIndex(t *testing.T) {
+	for name, profile := range map[string]ProtectionProfile{
+		"CTR": profileCTR,
+		"GCM": profileGCM,
+	} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			encryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encryptContext.SetROC(1, 2)
+			encrypted := map[uint16][]byte{}
+			for _, seq := range []uint16{65534, 65535, 0, 1} {
+				pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: seq}}
+				raw, err := pkt.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if encrypted[seq], err = encryptContext.EncryptRTP(nil, raw, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			decryptContext.SetROC(1, 2)
+			// The last packet before the rollover arrives late, it must be
+			// reported with the ROC it was decrypted with.
+			for _, expected := range []uint64{2<<16 | 65534, 3<<16 | 0, 2<<16 | 65535, 3<<16 | 1} {
+				_, index, err := decryptContext.DecryptRTPWithIndex(nil, encrypted[uint16(expected)], nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(expected, index)
+			}
+
+			roc, ok := decryptContext.ROC(1)
+			assert.True(ok)
+			assert.Equal(uint32(3), roc)
+		})
+	}
+}
+
+func TestRTPDecryptBatch(t *testing.T) {
+	for name, profile := range map[string]ProtectionProfile{
+		"CTR": profileCTR,
+		"GCM": profileGCM,
+	} {
+		profile := profile
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			encryptContext, err := buildTestContext(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var raw, encrypted [][]byte
+			for _, seq := range []uint16{65534, 65535, 0, 1} {
+				pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: seq}}
+				r, err := pkt.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				e, err := encryptContext.EncryptRTP(nil, r, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw, encrypted = append(raw, r), append(encrypted, e)
+			}
+
+			// Replayed, tampered and truncated packets fail individually.
+			tampered := append([]byte{}, encrypted[3]...)
+			tampered[len(tampered)-1] ^= 0xff
+			encrypted = append(encrypted, encrypted[0], tampered, []byte{0x80})
+
+			batchContext, err := buildTestContext(profile, SRTPReplayProtection(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+			loopContext, err := buildTestContext(profile, SRTPReplayProtection(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decrypted, errs := batchContext.DecryptRTPBatch(encrypted)
+			assert.Len(decrypted, len(encrypted))
+			assert.Len(errs, len(encrypted))
+			for i, pkt := range encrypted {
+				expected, err := loopContext.DecryptRTP(nil, pkt, nil)
+				assert.Equal(err, errs[i], "packet %d", i)
+				assert.Equal(expected, decrypted[i], "packet %d", i)
+				if i < len(raw) {
+					assert.NoError(errs[i])
+					assert.Equal(raw[i], decrypted[i])
+				} else {
+					assert.Error(errs[i])
+				}
+			}
+
+			roc, _ := loopContext.ROC(1)
+			batchROC, _ := batchContext.ROC(1)
+			assert.Equal(uint32(1), batchROC)
+			assert.Equal(roc, batchROC)
+		})
+	}
+}
+
+func TestRTPEncryptBatch(t *testing.T) {
+	for name, opts := range map[string][]ContextOption{
+		"CTR":     nil,
+		"Cryptex": {SRTPCryptex()},
+		"Padding": {SRTPPadding(16)},
+		"MKI":     {MasterKeyIndicator([]byte{0x01, 0x02, 0x03, 0x04})},
+	} {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var raw [][]byte
+			for _, seq := range []uint16{65534, 65535, 0, 1} {
+				pkt := &rtp.Packet{Payload: rtpTestCaseDecrypted(), Header: rtp.Header{SSRC: 1, SequenceNumber: seq, CSRC: []uint32{2}}}
+				r, err := pkt.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw = append(raw, r)
+			}
+			raw = append(raw, []byte{0x80})
+
+			batchContext, err := buildTestContext(profileCTR, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loopContext, err := buildTestContext(profileCTR, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encrypted, errs := batchContext.EncryptRTPBatch(raw)
+			assert.Len(encrypted, len(raw))
+			assert.Len(errs, len(raw))
+			for i, pkt := range raw {
+				expected, err := loopContext.EncryptRTP(nil, pkt, nil)
+				assert.Equal(err, errs[i], "packet %d", i)
+				assert.Equal(expected, encrypted[i], "packet %d", i)
+			}
+
+			// The packets do not overlap in the shared buffer.
+			for i := 0; i+2 < len(encrypted); i++ {
+				next := append([]byte{}, encrypted[i+1]...)
+				spare := encrypted[i][len(encrypted[i]):cap(encrypted[i])]
+				for j := range spare {
+					spare[j] = 0xff
+				}
+				assert.Equal(next, encrypted[i+1], "packet %d", i+1)
+			}
+		})
+	}
+}
+
+func TestRTPAllocs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		profile ProtectionProfile
+		opts    []ContextOption
+	}{
+		"CTR":                 {profile: profileCTR},
+		"GCM":                 {profile: profileGCM},
+		"MKI":                 {profile: profileCTR, opts: []ContextOption{MasterKeyIndicator([]byte{0x01, 0x02})}},
+		"HeaderExtensions":    {profile: profileCTR, opts: []ContextOption{SRTPEncryptedHeaderExtensions(1)}},
+		"CryptexCTR":          {profile: profileCTR, opts: []ContextOption{SRTPCryptex()}},
+		"CryptexGCM":          {profile: profileGCM, opts: []ContextOption{SRTPCryptex()}},
+		"NullHmacSha1":        {profile: ProtectionProfileNullHmacSha1_80},
+		"KeyDerivationRate24": {profile: profileCTR, opts: []ContextOption{KeyDerivationRate(24)}},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			encryptContext, err := buildTestContext(tc.profile, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptContext, err := buildTestContext(tc.profile, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkt := &rtp.Packet{Payload: make([]byte, 1000), Header: rtp.Header{SSRC: 1}}
+			if err = pkt.Header.SetExtension(1, []byte{0x01, 0x02, 0x03, 0x04}); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header := &rtp.Header{}
+			encrypted := make([]byte, 0, 1500)
+			decrypted := make([]byte, 0, 1500)
+			seq := uint16(0)
+			allocs := testing.AllocsPerRun(100, func() {
+				seq++
+				raw[2], raw[3] = byte(seq>>8), byte(seq)
+				if encrypted, err = encryptContext.EncryptRTP(encrypted[:0], raw, header); err != nil {
+					t.Fatal(err)
+				}
+				if decrypted, err = decryptContext.DecryptRTP(decrypted[:0], encrypted, header); err != nil {
+					t.Fatal(err)
+				}
+			})
+			assert.Equal(t, raw, decrypted)
+			assert.Zero(t, allocs, "EncryptRTP and DecryptRTP must not allocate")
+		})
+	}
+}
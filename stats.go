@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+// StreamStats holds the counters of a single SSRC of a Context.
+type StreamStats struct {
+	// Packets is the number of packets encrypted, or decrypted and
+	// authenticated, and Bytes is the total size of these SRTP or SRTCP
+	// packets.
+	Packets, Bytes uint64
+
+	// AuthFailures is the number of received packets which failed to
+	// authenticate.
+	AuthFailures uint64
+
+	// ReplayDrops is the number of received packets rejected by the replay
+	// detector.
+	ReplayDrops uint64
+
+	// ROCIncrements is the number of times the rollover counter of an SRTP
+	// stream has been incremented. Always 0 for SRTCP.
+	ROCIncrements uint64
+}
+
+// Stats is a snapshot of the counters of a Context, by SSRC. Counters are
+// not part of the state saved by MarshalBinary.
+type Stats struct {
+	SRTP, SRTCP map[uint32]StreamStats
+}
+
+// Stats returns a snapshot of the counters of every SSRC the Context has
+// processed.
+func (c *Context) Stats() Stats {
+	stats := Stats{
+		SRTP:  make(map[uint32]StreamStats, len(c.srtpSSRCStates)),
+		SRTCP: make(map[uint32]StreamStats, len(c.srtcpSSRCStates)),
+	}
+	for ssrc, s := range c.srtpSSRCStates {
+		stats.SRTP[ssrc] = s.stats
+	}
+	for ssrc, s := range c.srtcpSSRCStates {
+		stats.SRTCP[ssrc] = s.stats
+	}
+	return stats
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package srtp
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextStats(t *testing.T) {
+	assert := assert.New(t)
+	const ssrc = 0x11223344
+
+	encryptContext, err := buildTestContext(profileCTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptContext, err := buildTestContext(profileCTR, SRTPReplayProtection(64), SRTCPReplayProtection(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent, received uint64
+	for _, seq := range []uint16{65534, 65535, 0, 1} {
+		pkt := &rtp.Packet{Header: rtp.Header{Version: 2, SSRC: ssrc, SequenceNumber: seq}, Payload: rtpTestCaseDecrypted()}
+		raw, errMarshal := pkt.Marshal()
+		if errMarshal != nil {
+			t.Fatal(errMarshal)
+		}
+		encrypted, errEnc := encryptContext.EncryptRTP(nil, raw, nil)
+		if errEnc != nil {
+			t.Fatal(errEnc)
+		}
+		sent += uint64(len(encrypted))
+
+		if seq == 0 {
+			// Rejected by the replay detector once decrypted.
+			_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+			assert.NoError(err)
+			received += uint64(len(encrypted))
+			_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+			assert.ErrorIs(err, ErrDuplicated)
+			continue
+		}
+		if seq == 1 {
+			tampered := append([]byte{}, encrypted...)
+			tampered[len(tampered)-1] ^= 0x01
+			_, err = decryptContext.DecryptRTP(nil, tampered, nil)
+			assert.ErrorIs(err, ErrFailedToVerifyAuthTag)
+		}
+		_, err = decryptContext.DecryptRTP(nil, encrypted, nil)
+		assert.NoError(err)
+		received += uint64(len(encrypted))
+	}
+
+	rtcpPacket := []byte{0x80, 0xc8, 0x00, 0x01, 0x11, 0x22, 0x33, 0x44}
+	encrypted, err := encryptContext.EncryptRTCP(nil, rtcpPacket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+	assert.NoError(err)
+	_, err = decryptContext.DecryptRTCP(nil, encrypted, nil)
+	assert.ErrorIs(err, ErrDuplicated)
+
+	encryptStats := encryptContext.Stats()
+	assert.Equal(map[uint32]StreamStats{ssrc: {Packets: 4, Bytes: sent, ROCIncrements: 1}}, encryptStats.SRTP)
+	assert.Equal(map[uint32]StreamStats{ssrc: {Packets: 1, Bytes: uint64(len(encrypted))}}, encryptStats.SRTCP)
+
+	decryptStats := decryptContext.Stats()
+	assert.Equal(map[uint32]StreamStats{
+		ssrc: {Packets: 4, Bytes: received, AuthFailures: 1, ReplayDrops: 1, ROCIncrements: 1},
+	}, decryptStats.SRTP)
+	assert.Equal(map[uint32]StreamStats{ssrc: {Packets: 1, Bytes: uint64(len(encrypted)), ReplayDrops: 1}}, decryptStats.SRTCP)
+
+	// The snapshot is not changed by later packets.
+	_, err = encryptContext.EncryptRTCP(nil, rtcpPacket, nil)
+	assert.NoError(err)
+	assert.Equal(uint64(1), encryptStats.SRTCP[ssrc].Packets)
+}
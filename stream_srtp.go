@@ -7,6 +7,7 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/rtp"
@@ -140,7 +141,8 @@ type WriteStreamSRTP struct {
 	session *SessionSRTP
 }
 
-// WriteRTP encrypts a RTP packet and writes to the connection
+// WriteRTP encrypts a RTP packet and writes to the connection.
+// If header.Padding is set, payload must end with the padding.
 func (w *WriteStreamSRTP) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
 	return w.session.writeRTP(header, payload)
 }
@@ -155,3 +157,79 @@ func (w *WriteStreamSRTP) Write(b []byte) (int, error) {
 func (w *WriteStreamSRTP) SetWriteDeadline(t time.Time) error {
 	return w.session.setWriteDeadline(t)
 }
+
+// OutboundStreamSRTP originates the RTP stream of a single SSRC: the SSRC and
+// the sequence numbers of the packets written to it are set by the stream,
+// and their ROC is maintained by the local Context.
+type OutboundStreamSRTP struct {
+	session *SessionSRTP
+	ssrc    uint32
+
+	mu             sync.Mutex
+	sequenceNumber uint16
+	isClosed       bool
+
+	// Set by the reading goroutine, which must not wait for WriteRTP.
+	collided atomic.Bool
+}
+
+// WriteRTP encrypts a RTP packet with the SSRC of the stream and the next
+// sequence number, and writes it to the connection. header is not modified.
+func (o *OutboundStreamSRTP) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.isClosed {
+		return 0, errStreamAlreadyClosed
+	}
+
+	h := *header
+	h.SSRC = o.ssrc
+	h.SequenceNumber = o.sequenceNumber
+	n, err := o.session.writeRTP(&h, payload)
+	if err != nil {
+		return n, err
+	}
+	o.sequenceNumber++
+	return n, nil
+}
+
+// GetSSRC returns the SSRC of the stream
+func (o *OutboundStreamSRTP) GetSSRC() uint32 {
+	return o.ssrc
+}
+
+// SequenceNumber returns the sequence number of the next packet
+func (o *OutboundStreamSRTP) SequenceNumber() uint16 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sequenceNumber
+}
+
+// ROC returns the rollover counter of the last packet written, false if no
+// packet has been written yet.
+func (o *OutboundStreamSRTP) ROC() (uint32, bool) {
+	o.session.session.localContextMutex.Lock()
+	defer o.session.session.localContextMutex.Unlock()
+	return o.session.localContext.ROC(o.ssrc)
+}
+
+// Collided reports whether packets of the SSRC of the stream have been
+// received from the remote side. As specified in RFC 3550 section 8.2, the
+// application should then stop using the stream and open another one.
+func (o *OutboundStreamSRTP) Collided() bool {
+	return o.collided.Load()
+}
+
+// Close removes the stream from the session, so that its SSRC can be reused
+func (o *OutboundStreamSRTP) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.isClosed {
+		return errStreamAlreadyClosed
+	}
+	o.isClosed = true
+	o.session.removeOutboundStream(o.ssrc)
+	return nil
+}